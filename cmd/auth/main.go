@@ -4,6 +4,5 @@ import "github.com/fire9900/auth/internal/app"
 
 func main() {
 	app.LoggerRun()
-	go app.Run()
-	go app.StartGRPCServer()
+	app.Run()
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/fire9900/auth/internal/config"
+	"github.com/fire9900/auth/internal/repository"
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/fire9900/auth/pkg/database"
+	"github.com/fire9900/auth/pkg/logger"
+	"go.uber.org/zap"
+	"os"
+	"time"
+)
+
+// Ротация ключей подписи: генерирует новый активный ключ в хранилище,
+// прежний ключ остается только для проверки на время -retire-after.
+// По умолчанию это наибольший срок жизни токенов из конфигурации сервиса
+// и настроек зарегистрированных клиентов, чтобы ротация не ломала уже
+// выданные refresh токены. После ротации запущенному сервису нужно отправить SIGHUP.
+func main() {
+	keystore := flag.String("keystore", os.Getenv("AUTH_JWT_KEYSTORE"), "путь к хранилищу ключей")
+	alg := flag.String("alg", os.Getenv("AUTH_JWT_ALG"), "алгоритм нового ключа: HS256, RS256, ES256 или EdDSA")
+	retireAfter := flag.Duration("retire-after", 0, "сколько прежний ключ принимается при проверке; по умолчанию - наибольший срок жизни токенов")
+	flag.Parse()
+
+	if flag.Arg(0) != "rotate" {
+		fmt.Fprintln(os.Stderr, "использование: keys [флаги] rotate")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	if *keystore == "" {
+		fmt.Fprintln(os.Stderr, "не задан путь к хранилищу ключей")
+		os.Exit(2)
+	}
+
+	if *retireAfter <= 0 {
+		longest, err := longestLifetime()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "не удалось определить срок жизни токенов, задайте -retire-after:", err)
+			os.Exit(1)
+		}
+		*retireAfter = longest
+	}
+
+	kid, err := auth.RotateKeystore(*keystore, *alg, *retireAfter)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ошибка ротации ключей:", err)
+		os.Exit(1)
+	}
+	fmt.Println("новый активный ключ:", kid)
+	fmt.Println("прежний ключ принимается еще", *retireAfter)
+}
+
+// longestLifetime - наибольший срок жизни токенов с учетом переопределений
+// ролей и клиентов, сроков клиентов в базе и допустимого расхождения часов.
+func longestLifetime() (time.Duration, error) {
+	tokens, lifetimes, err := config.LoadTokens()
+	if err != nil {
+		return 0, err
+	}
+	longest := lifetimes.Longest()
+
+	logger.Logger = zap.NewNop()
+	db, err := database.NewSQLiteConnection()
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	clients, err := repository.NewClientRepository(db).GetAll()
+	if err != nil {
+		return 0, err
+	}
+	for _, client := range clients {
+		longest = max(longest, time.Duration(max(client.AccessTokenTTL, client.RefreshTokenTTL, client.RememberMeTTL))*time.Second)
+	}
+	return longest + tokens.Leeway, nil
+}
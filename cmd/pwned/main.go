@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/fire9900/auth/pkg/password"
+	"os"
+)
+
+// Импорт базы Pwned Passwords в bloom-фильтр: источник - каталог файлов
+// диапазонов или отсортированный файл HASH:COUNT. В фильтр попадают только
+// хеши, встречавшиеся не меньше -min-count раз. Путь к фильтру затем
+// задается в AUTH_PWNED_PASSWORDS_PATH.
+func main() {
+	source := flag.String("source", "", "каталог диапазонов или отсортированный файл HASH:COUNT")
+	out := flag.String("out", "pwned.bloom", "путь к создаваемому фильтру")
+	minCount := flag.Int("min-count", 1, "минимальное число вхождений пароля в утечки")
+	fpr := flag.Float64("fpr", 0.001, "допустимая вероятность ложного срабатывания")
+	flag.Parse()
+
+	if flag.Arg(0) != "import" {
+		fmt.Fprintln(os.Stderr, "использование: pwned [флаги] import")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	if *source == "" {
+		fmt.Fprintln(os.Stderr, "не задан источник базы утекших паролей")
+		os.Exit(2)
+	}
+	if *minCount < 1 || *fpr <= 0 || *fpr >= 1 {
+		fmt.Fprintln(os.Stderr, "-min-count должен быть не меньше 1, -fpr - от 0 до 1")
+		os.Exit(2)
+	}
+
+	filter, err := password.BuildBloomFilter(*source, *minCount, *fpr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ошибка чтения базы:", err)
+		os.Exit(1)
+	}
+	if err := save(filter, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "ошибка сохранения фильтра:", err)
+		os.Exit(1)
+	}
+	fmt.Println("фильтр сохранен:", *out)
+}
+
+// save пишет фильтр во временный файл и переименовывает его, чтобы
+// запущенный сервис никогда не прочитал недописанный фильтр.
+func save(filter *password.BloomFilter, path string) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	if _, err := filter.WriteTo(w); err != nil {
+		file.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -21,6 +21,7 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	AuthService_ValidateToken_FullMethodName = "/auth.AuthService/ValidateToken"
 	AuthService_GetUserID_FullMethodName     = "/auth.AuthService/GetUserID"
+	AuthService_GetClaims_FullMethodName     = "/auth.AuthService/GetClaims"
 )
 
 // AuthServiceClient is the client API for AuthService service.
@@ -29,6 +30,7 @@ const (
 type AuthServiceClient interface {
 	ValidateToken(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenResponse, error)
 	GetUserID(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*UserIDResponse, error)
+	GetClaims(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*ClaimsResponse, error)
 }
 
 type authServiceClient struct {
@@ -59,12 +61,23 @@ func (c *authServiceClient) GetUserID(ctx context.Context, in *TokenRequest, opt
 	return out, nil
 }
 
+func (c *authServiceClient) GetClaims(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*ClaimsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClaimsResponse)
+	err := c.cc.Invoke(ctx, AuthService_GetClaims_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AuthServiceServer is the server API for AuthService service.
 // All implementations must embed UnimplementedAuthServiceServer
 // for forward compatibility.
 type AuthServiceServer interface {
 	ValidateToken(context.Context, *TokenRequest) (*TokenResponse, error)
 	GetUserID(context.Context, *TokenRequest) (*UserIDResponse, error)
+	GetClaims(context.Context, *TokenRequest) (*ClaimsResponse, error)
 	mustEmbedUnimplementedAuthServiceServer()
 }
 
@@ -81,6 +94,9 @@ func (UnimplementedAuthServiceServer) ValidateToken(context.Context, *TokenReque
 func (UnimplementedAuthServiceServer) GetUserID(context.Context, *TokenRequest) (*UserIDResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUserID not implemented")
 }
+func (UnimplementedAuthServiceServer) GetClaims(context.Context, *TokenRequest) (*ClaimsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetClaims not implemented")
+}
 func (UnimplementedAuthServiceServer) mustEmbedUnimplementedAuthServiceServer() {}
 func (UnimplementedAuthServiceServer) testEmbeddedByValue()                     {}
 
@@ -138,6 +154,24 @@ func _AuthService_GetUserID_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _AuthService_GetClaims_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServiceServer).GetClaims(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthService_GetClaims_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServiceServer).GetClaims(ctx, req.(*TokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // AuthService_ServiceDesc is the grpc.ServiceDesc for AuthService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -153,6 +187,10 @@ var AuthService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetUserID",
 			Handler:    _AuthService_GetUserID_Handler,
 		},
+		{
+			MethodName: "GetClaims",
+			Handler:    _AuthService_GetClaims_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "internal/api/auth.proto",
@@ -1,27 +1,105 @@
 package app
 
 import (
+	"github.com/fire9900/auth/internal/config"
+	"github.com/fire9900/auth/internal/models"
 	"github.com/fire9900/auth/internal/repository"
 	"github.com/fire9900/auth/internal/transport/gin"
 	"github.com/fire9900/auth/internal/usecase"
+	"github.com/fire9900/auth/pkg/auth"
 	"github.com/fire9900/auth/pkg/database"
 	"github.com/fire9900/auth/pkg/logger"
+	"github.com/fire9900/auth/pkg/password"
 	_ "github.com/lib/pq"
 	"go.uber.org/zap"
 	"log"
+	"time"
 )
 
 func Run() {
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Logger.Fatal("Ошибка загрузки конфигурации",
+			zap.Error(err),
+			zap.String("app", "auth"))
+	}
+
+	keys, err := auth.LoadKeyRing(cfg.Keys)
+	if err != nil {
+		logger.Logger.Fatal("Не удалось загрузить ключ подписи токенов",
+			zap.Error(err),
+			zap.String("app", "auth"))
+	}
+	go watchKeyReload(cfg.Keys, keys)
+	signer := auth.NewSigner(keys, cfg.Tokens)
+	verifier := auth.NewVerifier(keys, cfg.Tokens)
+	if !verifier.IDTokensSupported() {
+		logger.Logger.Warn("OpenID Connect отключен: ID токены подписываются только асимметричным ключом, задайте AUTH_JWT_ALG",
+			zap.String("alg", verifier.SigningAlgorithm()))
+	}
+
+	if cfg.TokenFormat == auth.TokenFormatPasetoPublic || cfg.TokenFormat == auth.TokenFormatPasetoLocal {
+		usePaseto(cfg, signer, verifier)
+	}
+
+	models.PasswordHasher = password.NewHashers(password.NewArgon2id(cfg.Argon2), password.Bcrypt{})
+	if cfg.PasswordPolicy.Breaches != nil {
+		defer cfg.PasswordPolicy.Breaches.Close()
+	}
+
 	db, err := database.NewSQLiteConnection()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
+	if err := database.Migrate(db); err != nil {
+		logger.Logger.Fatal("Ошибка миграции базы данных",
+			zap.Error(err),
+			zap.String("app", "database"))
+	}
+
 	userRepo := repository.NewUserRepository(db)
-	userUseCase := usecase.NewUserUseCase(userRepo)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	revocations, err := usecase.NewRevocationStore(repository.NewRevokedTokenRepository(db))
+	if err != nil {
+		logger.Logger.Fatal("Ошибка загрузки отозванных токенов",
+			zap.Error(err),
+			zap.String("app", "database"))
+	}
+	go cleanupRevocations(revocations)
+	tokenVersions := usecase.NewTokenVersionStore(userRepo, cfg.TokenVersionCacheTTL)
+	go cleanupTokenVersions(tokenVersions)
+
+	var opaqueTokens *usecase.OpaqueTokenStore
+	if cfg.TokenFormat == auth.TokenFormatOpaque {
+		opaqueTokens = usecase.NewOpaqueTokenStore(repository.NewOpaqueTokenRepository(db), cfg.OpaqueCacheTTL)
+		signer.UseTokenStore(opaqueTokens)
+		verifier.UseTokenStore(opaqueTokens)
+		go cleanupOpaqueTokens(opaqueTokens)
+		logger.Logger.Info("Выпускаются непрозрачные токены",
+			zap.Duration("cache_ttl", cfg.OpaqueCacheTTL))
+	}
 
-	router := gin.SetupRouter(userUseCase)
+	clientRepo := repository.NewClientRepository(db)
+	clientUseCase := usecase.NewClientUseCase(clientRepo)
+	if err := clientUseCase.SeedClients(cfg.OAuthClients); err != nil {
+		logger.Logger.Fatal("Ошибка регистрации клиентов из конфигурации",
+			zap.Error(err),
+			zap.String("app", "database"))
+	}
+
+	sessionRepo := repository.NewSessionRepository(db)
+	tokenUseCase := usecase.NewTokenUseCase(userRepo, refreshTokenRepo, clientRepo, sessionRepo, revocations,
+		tokenVersions, opaqueTokens, signer, verifier, cfg.Lifetimes)
+	userUseCase := usecase.NewUserUseCase(userRepo, sessionRepo, tokenUseCase, cfg.PasswordPolicy)
+	oauthUseCase := usecase.NewOAuthUseCase(clientUseCase, repository.NewAuthorizationCodeRepository(db),
+		repository.NewDeviceCodeRepository(db), userRepo, tokenUseCase)
+
+	proofs := auth.NewDPoPVerifier(auth.NewMemoryReplayCache(), cfg.DPoPWindow)
+	go StartGRPCServer(tokenUseCase, proofs)
+
+	router := gin.SetupRouter(userUseCase, oauthUseCase, clientUseCase, tokenUseCase, verifier, proofs, cfg.SecureCookies)
 
 	if err := router.Run(":8080"); err != nil {
 		logger.Logger.Fatal("Ошибка запуска сервера на порту :8080",
@@ -30,3 +108,50 @@ func Run() {
 	}
 	logger.Logger.Info("Микросервис стартует на порту :8080")
 }
+
+// usePaseto переключает выпуск и проверку access и refresh токенов на PASETO.
+// Ключи PASETO загружаются отдельно от ключей JWT, которыми подписываются
+// ID токены, и так же перечитываются по SIGHUP.
+func usePaseto(cfg config.Config, signer *auth.Signer, verifier *auth.Verifier) {
+	keys, err := auth.LoadKeyRing(cfg.PasetoKeys)
+	if err != nil {
+		logger.Logger.Fatal("Не удалось загрузить ключ PASETO",
+			zap.Error(err),
+			zap.String("app", "auth"))
+	}
+	codec, err := auth.NewPasetoCodec(cfg.TokenFormat, keys)
+	if err != nil {
+		logger.Logger.Fatal("Ключ не подходит для формата PASETO",
+			zap.Error(err),
+			zap.String("format", cfg.TokenFormat))
+	}
+	go watchKeyReload(cfg.PasetoKeys, keys)
+	signer.UseCodec(codec)
+	verifier.UseCodec(codec)
+	logger.Logger.Info("Выпускаются токены PASETO",
+		zap.String("format", cfg.TokenFormat))
+}
+
+func cleanupRevocations(revocations *usecase.RevocationStore) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		revocations.Cleanup()
+	}
+}
+
+func cleanupTokenVersions(versions *usecase.TokenVersionStore) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		versions.Cleanup()
+	}
+}
+
+func cleanupOpaqueTokens(store *usecase.OpaqueTokenStore) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		store.Cleanup()
+	}
+}
@@ -0,0 +1,27 @@
+package app
+
+import (
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/fire9900/auth/pkg/logger"
+	"go.uber.org/zap"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchKeyReload перечитывает ключи подписи по SIGHUP, чтобы ротация
+// ключей в хранилище применялась без перезапуска сервиса.
+func watchKeyReload(cfg auth.KeyConfig, keys *auth.KeyRing) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		next, err := auth.LoadKeyRing(cfg)
+		if err != nil {
+			logger.Logger.Error("Ошибка перечитывания ключей подписи", zap.Error(err))
+			continue
+		}
+		keys.Replace(next)
+		logger.Logger.Info("Ключи подписи перечитаны",
+			zap.String("kid", keys.Active().ID))
+	}
+}
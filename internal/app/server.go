@@ -2,6 +2,7 @@ package app
 
 import (
 	auth "github.com/fire9900/auth/pkg/api/g_rpc"
+	jwt "github.com/fire9900/auth/pkg/auth"
 	"github.com/fire9900/auth/pkg/logger"
 	"github.com/fire9900/auth/pkg/server"
 	"go.uber.org/zap"
@@ -9,14 +10,14 @@ import (
 	"net"
 )
 
-func StartGRPCServer() {
+func StartGRPCServer(validator server.TokenValidator, proofs *jwt.DPoPVerifier) {
 	lis, err := net.Listen("tcp", ":50051")
 	if err != nil {
 		logger.Logger.Fatal("Ошибка создания подключения для gRPC", zap.Error(err))
 	}
 
 	s := grpc.NewServer()
-	auth.RegisterAuthServiceServer(s, &server.AuthServer{})
+	auth.RegisterAuthServiceServer(s, server.NewAuthServer(validator, proofs))
 
 	logger.Logger.Debug("gRPC сервер стартует")
 	if err := s.Serve(lis); err != nil {
@@ -0,0 +1,262 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/fire9900/auth/pkg/password"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TokenFormat - формат выпускаемых access и refresh токенов: auth.TokenFormatJWT,
+// auth.TokenFormatOpaque, auth.TokenFormatPasetoPublic или auth.TokenFormatPasetoLocal.
+// OpaqueCacheTTL - время жизни кеша claims непрозрачных токенов.
+// TokenVersionCacheTTL - время жизни кеша версий токенов пользователей.
+// PasetoKeys - отдельные ключи PASETO: Ed25519 для v4.public, секрет для v4.local.
+// DPoPWindow - допустимое расхождение iat DPoP доказательства с часами сервиса.
+// PasswordPolicy - требования к паролям при создании пользователя и смене пароля.
+// Самостоятельного сброса пароля в сервисе нет.
+// Argon2 - параметры Argon2id для новых хешей паролей.
+// SecureCookies выставляет флаг Secure cookie браузерной сессии; по умолчанию
+// включен, если адрес сервиса (issuer) начинается с https://.
+type Config struct {
+	Keys                 auth.KeyConfig
+	PasetoKeys           auth.KeyConfig
+	Tokens               auth.TokenConfig
+	Lifetimes            auth.LifetimeConfig
+	TokenFormat          string
+	OpaqueCacheTTL       time.Duration
+	TokenVersionCacheTTL time.Duration
+	DPoPWindow           time.Duration
+	OAuthClients         map[string]string
+	PasswordPolicy       password.Policy
+	Argon2               password.Argon2Params
+	SecureCookies        bool
+}
+
+var tokenFormats = []string{
+	auth.TokenFormatJWT,
+	auth.TokenFormatOpaque,
+	auth.TokenFormatPasetoPublic,
+	auth.TokenFormatPasetoLocal,
+}
+
+func Load() (Config, error) {
+	cfg := Config{
+		Keys: auth.KeyConfig{
+			Algorithm:      os.Getenv("AUTH_JWT_ALG"),
+			Secret:         os.Getenv("AUTH_JWT_SECRET"),
+			SecretFile:     os.Getenv("AUTH_JWT_SECRET_FILE"),
+			PrivateKeyFile: os.Getenv("AUTH_JWT_PRIVATE_KEY_FILE"),
+			KeystoreFile:   os.Getenv("AUTH_JWT_KEYSTORE"),
+		},
+	}
+
+	var err error
+	if cfg.Tokens, cfg.Lifetimes, err = LoadTokens(); err != nil {
+		return Config{}, err
+	}
+
+	cfg.TokenFormat = stringEnv("AUTH_TOKEN_FORMAT", auth.TokenFormatJWT)
+	if !slices.Contains(tokenFormats, cfg.TokenFormat) {
+		return Config{}, fmt.Errorf("неизвестный формат токенов AUTH_TOKEN_FORMAT: %q", cfg.TokenFormat)
+	}
+	cfg.PasetoKeys = auth.KeyConfig{
+		Algorithm:      auth.PasetoAlgorithm(cfg.TokenFormat),
+		Secret:         os.Getenv("AUTH_PASETO_SECRET"),
+		SecretFile:     os.Getenv("AUTH_PASETO_SECRET_FILE"),
+		PrivateKeyFile: os.Getenv("AUTH_PASETO_PRIVATE_KEY_FILE"),
+		KeystoreFile:   os.Getenv("AUTH_PASETO_KEYSTORE"),
+	}
+	var env envReader
+	cfg.OpaqueCacheTTL = env.duration("AUTH_OPAQUE_CACHE_TTL", 30*time.Second)
+	cfg.TokenVersionCacheTTL = env.duration("AUTH_TOKEN_VERSION_CACHE_TTL", 30*time.Second)
+	cfg.DPoPWindow = env.duration("AUTH_DPOP_WINDOW", auth.DefaultDPoPWindow)
+	cfg.OAuthClients = clientsEnv("AUTH_OAUTH_CLIENTS")
+	cfg.SecureCookies = env.boolean("AUTH_SECURE_COOKIES", strings.HasPrefix(cfg.Tokens.Issuer, "https://"))
+
+	memory := env.integer("AUTH_ARGON2_MEMORY_KIB", int(password.DefaultArgon2Params.Memory))
+	iterations := env.integer("AUTH_ARGON2_ITERATIONS", int(password.DefaultArgon2Params.Iterations))
+	parallelism := env.integer("AUTH_ARGON2_PARALLELISM", int(password.DefaultArgon2Params.Parallelism))
+	if env.err != nil {
+		return Config{}, env.err
+	}
+	if parallelism < 1 || parallelism > 255 || iterations < 1 || memory < 8*parallelism || memory > math.MaxUint32 {
+		return Config{}, fmt.Errorf("недопустимые параметры Argon2id: m=%d, t=%d, p=%d", memory, iterations, parallelism)
+	}
+	cfg.Argon2 = password.DefaultArgon2Params
+	cfg.Argon2.Memory = uint32(memory)
+	cfg.Argon2.Iterations = uint32(iterations)
+	cfg.Argon2.Parallelism = uint8(parallelism)
+
+	policy, err := passwordPolicy()
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.PasswordPolicy = policy
+	return cfg, nil
+}
+
+// LoadTokens читает настройки проверки токенов и сроки их жизни вместе
+// с переопределениями из AUTH_TOKEN_LIFETIMES_FILE. Утилита ротации ключей
+// вызывает ее отдельно от Load, чтобы знать, сколько живут выданные токены.
+func LoadTokens() (auth.TokenConfig, auth.LifetimeConfig, error) {
+	var env envReader
+	tokens := auth.TokenConfig{
+		Issuer:   stringEnv("AUTH_JWT_ISSUER", "http://localhost:8080"),
+		Audience: stringEnv("AUTH_JWT_AUDIENCE", "api"),
+		Leeway:   env.duration("AUTH_JWT_LEEWAY", 30*time.Second),
+	}
+	lifetimes := auth.LifetimeConfig{
+		Default: auth.Lifetimes{
+			Access:     env.duration("AUTH_ACCESS_TTL", auth.DefaultAccessTTL),
+			Refresh:    env.duration("AUTH_REFRESH_TTL", auth.DefaultRefreshTTL),
+			RememberMe: env.duration("AUTH_REMEMBER_ME_TTL", auth.DefaultRememberMeTTL),
+		},
+	}
+	if env.err != nil {
+		return auth.TokenConfig{}, auth.LifetimeConfig{}, env.err
+	}
+
+	if path := os.Getenv("AUTH_TOKEN_LIFETIMES_FILE"); path != "" {
+		if err := auth.LoadLifetimeOverrides(path, &lifetimes); err != nil {
+			return auth.TokenConfig{}, auth.LifetimeConfig{}, err
+		}
+	}
+	return tokens, lifetimes, nil
+}
+
+// passwordPolicy дополняет политику паролей по умолчанию настройками
+// из окружения. Слова из AUTH_PASSWORD_BANNED_WORDS_FILE добавляются
+// к встроенному списку. AUTH_PWNED_PASSWORDS_PATH включает проверку
+// по локальной базе утекших паролей (см. password.OpenBreachDataset).
+func passwordPolicy() (password.Policy, error) {
+	var env envReader
+	policy := password.DefaultPolicy()
+	policy.MinLength = env.integer("AUTH_PASSWORD_MIN_LENGTH", policy.MinLength)
+	policy.MaxLength = env.integer("AUTH_PASSWORD_MAX_LENGTH", policy.MaxLength)
+	policy.MinScore = env.integer("AUTH_PASSWORD_MIN_SCORE", policy.MinScore)
+	policy.RequireLowercase = env.boolean("AUTH_PASSWORD_REQUIRE_LOWERCASE", policy.RequireLowercase)
+	policy.RequireUppercase = env.boolean("AUTH_PASSWORD_REQUIRE_UPPERCASE", policy.RequireUppercase)
+	policy.RequireDigit = env.boolean("AUTH_PASSWORD_REQUIRE_DIGIT", policy.RequireDigit)
+	policy.RequireSymbol = env.boolean("AUTH_PASSWORD_REQUIRE_SYMBOL", policy.RequireSymbol)
+	policy.RejectSimilar = env.boolean("AUTH_PASSWORD_REJECT_SIMILAR", policy.RejectSimilar)
+	threshold := env.integer("AUTH_PWNED_THRESHOLD", 1)
+	if env.err != nil {
+		return password.Policy{}, env.err
+	}
+
+	if policy.MinLength < 1 || policy.MaxLength < policy.MinLength {
+		return password.Policy{}, fmt.Errorf("недопустимые границы длины пароля: от %d до %d", policy.MinLength, policy.MaxLength)
+	}
+	if policy.MinScore < 0 || policy.MinScore > 4 {
+		return password.Policy{}, fmt.Errorf("AUTH_PASSWORD_MIN_SCORE должен быть от 0 до 4: %d", policy.MinScore)
+	}
+
+	if path := os.Getenv("AUTH_PASSWORD_BANNED_WORDS_FILE"); path != "" {
+		words, err := password.LoadBannedWords(path)
+		if err != nil {
+			return password.Policy{}, err
+		}
+		policy.BannedWords = append(slices.Clone(policy.BannedWords), words...)
+	}
+
+	if path := os.Getenv("AUTH_PWNED_PASSWORDS_PATH"); path != "" {
+		breaches, err := password.OpenBreachDataset(path)
+		if err != nil {
+			return password.Policy{}, err
+		}
+		policy.BreachThreshold = max(threshold, 1)
+		// Фильтр не хранит число вхождений, поэтому порог проверки не может
+		// быть выше порога, с которым фильтр был построен.
+		if filter, ok := breaches.(*password.BloomFilter); ok && filter.MinCount() < policy.BreachThreshold {
+			breaches.Close()
+			return password.Policy{}, fmt.Errorf("bloom-фильтр построен с порогом %d, меньше AUTH_PWNED_THRESHOLD=%d: постройте фильтр заново с -min-count %d",
+				filter.MinCount(), policy.BreachThreshold, policy.BreachThreshold)
+		}
+		policy.RejectBreached = true
+		policy.Breaches = breaches
+	}
+	return policy, nil
+}
+
+func stringEnv(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// envReader разбирает типизированные переменные окружения. Пустая
+// переменная означает значение по умолчанию, а неразобранное значение
+// запоминается как ошибка: опечатка в настройке безопасности должна
+// остановить запуск, а не молча заменяться значением по умолчанию.
+type envReader struct {
+	err error
+}
+
+func (e *envReader) duration(name string, fallback time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback
+	}
+	value, err := time.ParseDuration(raw)
+	if err == nil && value < 0 {
+		err = errors.New("отрицательная длительность")
+	}
+	if err != nil {
+		e.fail(name, raw, err)
+		return fallback
+	}
+	return value
+}
+
+func (e *envReader) integer(name string, fallback int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		e.fail(name, raw, err)
+		return fallback
+	}
+	return value
+}
+
+func (e *envReader) boolean(name string, fallback bool) bool {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		e.fail(name, raw, err)
+		return fallback
+	}
+	return value
+}
+
+func (e *envReader) fail(name, raw string, err error) {
+	if e.err == nil {
+		e.err = fmt.Errorf("неверное значение %s=%q: %w", name, raw, err)
+	}
+}
+
+// clientsEnv разбирает список клиентов вида "id1:secret1,id2:secret2".
+// Эти клиенты заводятся в базе при старте, если их там еще нет.
+func clientsEnv(name string) map[string]string {
+	clients := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(name), ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if ok && id != "" && secret != "" {
+			clients[id] = secret
+		}
+	}
+	return clients
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_InvalidValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{name: "AUTH_JWT_LEEWAY", value: "5x", wantErr: "AUTH_JWT_LEEWAY"},
+		{name: "AUTH_ACCESS_TTL", value: "-1m", wantErr: "AUTH_ACCESS_TTL"},
+		{name: "AUTH_PASSWORD_MIN_LENGTH", value: "eight", wantErr: "AUTH_PASSWORD_MIN_LENGTH"},
+		{name: "AUTH_PASSWORD_REJECT_SIMILAR", value: "yes please", wantErr: "AUTH_PASSWORD_REJECT_SIMILAR"},
+		{name: "AUTH_ARGON2_PARALLELISM", value: "300", wantErr: "Argon2id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.name, tt.value)
+			_, err := Load()
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	t.Setenv("AUTH_JWT_LEEWAY", "")
+	t.Setenv("AUTH_ACCESS_TTL", "10m")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, cfg.Tokens.Leeway)
+	assert.Equal(t, 10*time.Minute, cfg.Lifetimes.Default.Access)
+}
+
+func TestLoad_SecureCookies(t *testing.T) {
+	t.Setenv("AUTH_JWT_ISSUER", "https://auth.example.com")
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.SecureCookies, "для https адреса Secure включен по умолчанию")
+
+	t.Setenv("AUTH_JWT_ISSUER", "http://localhost:8080")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.SecureCookies)
+
+	t.Setenv("AUTH_SECURE_COOKIES", "true")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.SecureCookies, "Secure включается явно, например за TLS прокси")
+}
+
+func TestLoad_SigningKey(t *testing.T) {
+	dir := t.TempDir()
+	malformed := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(malformed, []byte("not a pem"), 0o600))
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr error
+	}{
+		{name: "Secret", env: map[string]string{"AUTH_JWT_SECRET": "0123456789abcdef0123456789abcdef"}},
+		{name: "No key", env: map[string]string{}, wantErr: auth.ErrorNoSigningKey},
+		{name: "Short secret", env: map[string]string{"AUTH_JWT_SECRET": "short"}, wantErr: auth.ErrorWeakSigningKey},
+		{name: "Missing secret file", env: map[string]string{"AUTH_JWT_SECRET_FILE": filepath.Join(dir, "missing")}, wantErr: os.ErrNotExist},
+		{name: "Malformed PEM", env: map[string]string{"AUTH_JWT_ALG": "RS256", "AUTH_JWT_PRIVATE_KEY_FILE": malformed}, wantErr: jwt.ErrKeyMustBePEMEncoded},
+		{name: "Unknown algorithm", env: map[string]string{"AUTH_JWT_ALG": "HS512", "AUTH_JWT_SECRET": "0123456789abcdef0123456789abcdef"}, wantErr: auth.ErrorUnsupportedAlg},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"AUTH_JWT_ALG", "AUTH_JWT_SECRET", "AUTH_JWT_SECRET_FILE", "AUTH_JWT_PRIVATE_KEY_FILE", "AUTH_JWT_KEYSTORE"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			cfg, err := Load()
+			require.NoError(t, err)
+			_, err = auth.LoadKeyRing(cfg.Keys)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
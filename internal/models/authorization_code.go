@@ -0,0 +1,26 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrorAuthorizationCodeNotFound = errors.New("Код авторизации не найден")
+
+// AuthorizationCode - одноразовый код авторизации (RFC 6749, 4.1).
+// Хранится только хеш кода. FamilyID заранее задает семейство refresh
+// токенов, чтобы при повторном предъявлении кода отозвать выданные по нему токены.
+type AuthorizationCode struct {
+	CodeHash      string     `json:"-"`
+	ClientID      string     `json:"client_id"`
+	UserID        int        `json:"user_id"`
+	RedirectURI   string     `json:"redirect_uri"`
+	Scope         string     `json:"scope"`
+	CodeChallenge string     `json:"-"`
+	Nonce         string     `json:"-"`
+	FamilyID      string     `json:"family_id"`
+	AuthTime      time.Time  `json:"auth_time"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	UsedAt        *time.Time `json:"used_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
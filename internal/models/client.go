@@ -0,0 +1,64 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	ErrorInvalidClient  = errors.New("Неверные учетные данные клиента")
+	ErrorClientNotFound = errors.New("Клиент не найден")
+	ErrorClientExists   = errors.New("Клиент с таким client_id уже существует")
+
+	ErrorInvalidClientMetadata = errors.New("Недопустимые параметры клиента")
+)
+
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+	GrantDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+	GrantTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+)
+
+// Client - зарегистрированное OAuth приложение. Хранится только хеш секрета;
+// у публичного клиента (Public) секрета нет, и он получает токены только
+// по коду авторизации с PKCE. Нулевые сроки жизни токенов (в секундах)
+// означают сроки по умолчанию. ExchangeAudiences - аудитории, для которых
+// клиент может получать токены обменом (RFC 8693), ExchangeSubjectAudiences -
+// аудитории токенов входа по паролю, которые он может обменивать. Собственное приложение
+// (FirstParty) получает код авторизации без экрана согласия.
+type Client struct {
+	ID                       string    `json:"client_id"`
+	SecretHash               string    `json:"-"`
+	Name                     string    `json:"name"`
+	Public                   bool      `json:"public"`
+	FirstParty               bool      `json:"first_party"`
+	GrantTypes               []string  `json:"grant_types"`
+	RedirectURIs             []string  `json:"redirect_uris"`
+	Scopes                   []string  `json:"scopes"`
+	ExchangeAudiences        []string  `json:"exchange_audiences"`
+	ExchangeSubjectAudiences []string  `json:"exchange_subject_audiences"`
+	AccessTokenTTL           int64     `json:"access_token_ttl"`
+	RefreshTokenTTL          int64     `json:"refresh_token_ttl"`
+	RememberMeTTL            int64     `json:"remember_me_ttl"`
+	CreatedAt                time.Time `json:"created_at"`
+	UpdatedAt                time.Time `json:"updated_at"`
+}
+
+func (c *Client) AllowsGrant(grantType string) bool {
+	return slices.Contains(c.GrantTypes, grantType)
+}
+
+func (c *Client) AllowsScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+func (c *Client) AllowsAudience(audience string) bool {
+	return slices.Contains(c.ExchangeAudiences, audience)
+}
+
+func (c *Client) AllowsSubjectAudience(audience string) bool {
+	return slices.Contains(c.ExchangeSubjectAudiences, audience)
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrorDeviceCodeNotFound   = errors.New("Код устройства не найден")
+	ErrorUserCodeExists       = errors.New("Пользовательский код уже выдан")
+	ErrorUserCodeInvalid      = errors.New("Неверный или истекший код устройства")
+	ErrorAuthorizationPending = errors.New("Пользователь еще не подтвердил вход")
+	ErrorSlowDown             = errors.New("Слишком частый опрос")
+	ErrorAccessDenied         = errors.New("Пользователь отклонил запрос")
+	ErrorExpiredToken         = errors.New("Срок действия кода устройства истек")
+)
+
+// Состояния запроса авторизации устройства.
+const (
+	DeviceStatusPending  = "pending"
+	DeviceStatusApproved = "approved"
+	DeviceStatusDenied   = "denied"
+	DeviceStatusUsed     = "used"
+)
+
+// DeviceCode - запрос авторизации устройства (RFC 8628). Устройство опрашивает
+// эндпоинт токенов по device_code, хранящемуся в виде хеша, а пользователь
+// подтверждает запрос на странице /device по короткому UserCode.
+// Interval - минимальный интервал опроса в секундах, растущий при slow_down.
+type DeviceCode struct {
+	DeviceCodeHash string     `json:"-"`
+	UserCode       string     `json:"user_code"`
+	ClientID       string     `json:"client_id"`
+	Scope          string     `json:"scope"`
+	Status         string     `json:"status"`
+	UserID         int        `json:"user_id"`
+	Interval       int        `json:"interval"`
+	LastPolledAt   *time.Time `json:"last_polled_at,omitempty"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
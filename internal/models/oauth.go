@@ -0,0 +1,17 @@
+package models
+
+import "errors"
+
+// Ошибки протокола OAuth 2.0. Транспортный слой сопоставляет их с кодами
+// ошибок RFC 6749.
+var (
+	ErrorInvalidRedirectURI      = errors.New("Недопустимый redirect_uri")
+	ErrorUnsupportedResponseType = errors.New("Неподдерживаемый response_type")
+	ErrorUnsupportedGrantType    = errors.New("Неподдерживаемый grant_type")
+	ErrorPKCERequired            = errors.New("Требуется PKCE с методом S256")
+	ErrorInvalidGrant            = errors.New("Недействительный грант")
+	ErrorUnauthorizedClient      = errors.New("Клиенту не разрешен этот грант")
+	ErrorInvalidScope            = errors.New("Запрошена недопустимая область доступа")
+	ErrorInvalidRequest          = errors.New("Некорректный запрос")
+	ErrorInvalidTarget           = errors.New("Недопустимая аудитория токена")
+)
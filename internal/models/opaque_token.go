@@ -0,0 +1,23 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrorOpaqueTokenNotFound = errors.New("Непрозрачный токен не найден")
+
+// OpaqueToken - непрозрачный токен, выданный в режиме AUTH_TOKEN_FORMAT=opaque.
+// Сам токен не хранится, только его хеш. Claims - те же claims, что попали бы
+// в JWT, в виде JSON; остальные поля дублируют их для поиска и удаления.
+type OpaqueToken struct {
+	TokenHash string    `json:"-"`
+	JTI       string    `json:"jti"`
+	TokenType string    `json:"token_type"`
+	UserID    int       `json:"user_id"`
+	ClientID  string    `json:"client_id"`
+	SessionID string    `json:"session_id"`
+	Claims    string    `json:"claims"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
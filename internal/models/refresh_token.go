@@ -0,0 +1,30 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrorRefreshTokenInvalid  = errors.New("Невалидный refresh токен")
+	ErrorRefreshTokenNotFound = errors.New("Refresh токен не найден")
+	ErrorRefreshTokenReused   = errors.New("Повторное использование refresh токена")
+	ErrorRefreshTokenRevoked  = errors.New("Refresh токен отозван")
+)
+
+// RefreshToken - выданный refresh токен. Токены, полученные друг из друга
+// при обновлении, образуют семейство FamilyID и отзываются вместе.
+// Scope - области доступа, выданные семейству; пустой Scope - ни одной.
+type RefreshToken struct {
+	ID         int        `json:"id"`
+	FamilyID   string     `json:"family_id"`
+	TokenHash  string     `json:"-"`
+	UserID     int        `json:"user_id"`
+	ClientID   string     `json:"client_id"`
+	RememberMe bool       `json:"remember_me"`
+	Scope      string     `json:"scope"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	UsedAt     *time.Time `json:"used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
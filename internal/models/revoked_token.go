@@ -0,0 +1,19 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrorTokenRevoked        = errors.New("Токен отозван")
+	ErrorTokenClientMismatch = errors.New("Токен выпущен другому клиенту")
+)
+
+// RevokedToken - отозванный до истечения срока токен. Запись нужна только
+// до ExpiresAt: после этого токен отклоняется и без нее.
+type RevokedToken struct {
+	JTI       string    `json:"jti"`
+	ExpiresAt time.Time `json:"expires_at"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrorSessionNotFound = errors.New("Сессия не найдена")
+
+// Session - вход пользователя с устройства. ID совпадает с семейством
+// refresh токенов (claim sid), поэтому отзыв сессии отзывает ее токены.
+// LastSeenAt обновляется при каждом обновлении токенов, ExpiresAt - срок
+// жизни последнего refresh токена сессии.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int        `json:"-"`
+	ClientID   string     `json:"client_id,omitempty"`
+	DeviceName string     `json:"device_name"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+}
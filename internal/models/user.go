@@ -2,36 +2,78 @@ package models
 
 import (
 	"errors"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/fire9900/auth/pkg/password"
 )
 
 var (
 	ErrorUserNotFound  = errors.New("Пользователь не найден")
 	ErrorWrongPassword = errors.New("Неверный пароль")
+	ErrorUserSuspended = errors.New("Пользователь заблокирован")
+	ErrorForbidden     = errors.New("Недостаточно прав")
 )
 
+// User - учетная запись. TokenVersion попадает в claim ver всех токенов
+// пользователя: увеличение версии при смене пароля, блокировке или выходе
+// со всех устройств делает выпущенные ранее токены недействительными.
 type User struct {
-	ID       int    `json:"id"`
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password" binding:"required"`
-	Role     string `json:"role"`
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	Password     string `json:"password" binding:"required"`
+	Role         string `json:"role"`
+	TokenVersion int    `json:"-"`
+	Suspended    bool   `json:"suspended"`
 }
 
+// PasswordHasher хеширует пароли пользователей. По умолчанию новые хеши
+// создаются Argon2id, а хеши bcrypt, созданные раньше, только проверяются.
+var PasswordHasher password.Hasher = password.DefaultHashers()
+
 func (u *User) HashPassword() error {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+	hashed, err := PasswordHasher.Hash(u.Password)
 	if err != nil {
 		return err
 	}
-	u.Password = string(hashed)
+	u.Password = hashed
 	return nil
 }
 
 func (u *User) CheckPassword(password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
+	ok, err := PasswordHasher.Verify(password, u.Password)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrorWrongPassword
+	}
+	return nil
 }
 
 func (u *User) VerifyPassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
-	return err == nil
+	return u.CheckPassword(password) == nil
+}
+
+// NeedsRehash сообщает, что хеш пароля создан устаревшим алгоритмом
+// или с устаревшими параметрами.
+func (u *User) NeedsRehash() bool {
+	return PasswordHasher.NeedsRehash(u.Password)
+}
+
+// RoleScopes - области доступа, которые получает access токен пользователя
+// с данной ролью при входе по паролю. openid, profile и email - области
+// OpenID Connect, открывающие соответствующие claims в /userinfo.
+var RoleScopes = map[string][]string{
+	"user":  {"openid", "profile", "email"},
+	"admin": {"openid", "profile", "email", "users:read", "users:write", "clients:read", "clients:write"},
+}
+
+func (u *User) Roles() []string {
+	if u.Role == "" {
+		return nil
+	}
+	return []string{u.Role}
+}
+
+func (u *User) Scopes() []string {
+	return RoleScopes[u.Role]
 }
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/logger"
+	"go.uber.org/zap"
+	"time"
+)
+
+type AuthorizationCodeRepository interface {
+	Create(code models.AuthorizationCode) error
+	GetByHash(hash string) (models.AuthorizationCode, error)
+	MarkUsed(hash string, usedAt time.Time) (bool, error)
+}
+
+type authorizationCodeRepository struct {
+	db *sql.DB
+}
+
+func NewAuthorizationCodeRepository(db *sql.DB) AuthorizationCodeRepository {
+	return &authorizationCodeRepository{db: db}
+}
+
+func (r *authorizationCodeRepository) Create(code models.AuthorizationCode) error {
+	query := `INSERT INTO authorization_codes (code_hash, client_id, user_id, redirect_uri, scope, code_challenge, nonce, family_id, auth_time, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	_, err := r.db.Exec(
+		query,
+		code.CodeHash,
+		code.ClientID,
+		code.UserID,
+		code.RedirectURI,
+		code.Scope,
+		code.CodeChallenge,
+		code.Nonce,
+		code.FamilyID,
+		code.AuthTime,
+		code.ExpiresAt,
+		code.CreatedAt,
+	)
+	if err != nil {
+		logger.Logger.Error("Ошибка при сохранении кода авторизации",
+			zap.Error(err),
+			zap.String("client_id", code.ClientID),
+			zap.String("метод", "Create"))
+		return fmt.Errorf("ошибка при сохранении кода авторизации: %w", err)
+	}
+	return nil
+}
+
+func (r *authorizationCodeRepository) GetByHash(hash string) (models.AuthorizationCode, error) {
+	query := `SELECT code_hash, client_id, user_id, redirect_uri, scope, code_challenge, nonce, family_id, auth_time, expires_at, used_at, created_at
+		 FROM authorization_codes WHERE code_hash = $1`
+
+	var code models.AuthorizationCode
+	var usedAt sql.NullTime
+	err := r.db.QueryRow(query, hash).Scan(
+		&code.CodeHash,
+		&code.ClientID,
+		&code.UserID,
+		&code.RedirectURI,
+		&code.Scope,
+		&code.CodeChallenge,
+		&code.Nonce,
+		&code.FamilyID,
+		&code.AuthTime,
+		&code.ExpiresAt,
+		&usedAt,
+		&code.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.AuthorizationCode{}, models.ErrorAuthorizationCodeNotFound
+		}
+		logger.Logger.Error("Ошибка при получении кода авторизации",
+			zap.Error(err),
+			zap.String("метод", "GetByHash"))
+		return models.AuthorizationCode{}, fmt.Errorf("ошибка при получении кода авторизации: %w", err)
+	}
+
+	if usedAt.Valid {
+		code.UsedAt = &usedAt.Time
+	}
+	return code, nil
+}
+
+// MarkUsed помечает код использованным. Возвращает false, если код уже
+// был использован другим запросом.
+func (r *authorizationCodeRepository) MarkUsed(hash string, usedAt time.Time) (bool, error) {
+	query := `UPDATE authorization_codes SET used_at = $1 WHERE code_hash = $2 AND used_at IS NULL`
+	result, err := r.db.Exec(query, usedAt, hash)
+	if err != nil {
+		logger.Logger.Error("Ошибка при использовании кода авторизации",
+			zap.Error(err),
+			zap.String("метод", "MarkUsed"))
+		return false, fmt.Errorf("ошибка при использовании кода авторизации: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("ошибка при получении количества обновленных строк: %w", err)
+	}
+	return rowsAffected == 1, nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAuthorizationCodeRepository_GetByHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger.Logger = zap.NewNop()
+	repo := NewAuthorizationCodeRepository(db)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	columns := []string{"code_hash", "client_id", "user_id", "redirect_uri", "scope", "code_challenge", "nonce", "family_id", "auth_time", "expires_at", "used_at", "created_at"}
+
+	t.Run("Unused code", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM authorization_codes WHERE code_hash =").
+			WithArgs("hash").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("hash", "spa", 7, "https://spa.test/cb", "openid", "challenge", "n", "family", now, now, nil, now))
+
+		got, err := repo.GetByHash("hash")
+		require.NoError(t, err)
+		assert.Equal(t, "spa", got.ClientID)
+		assert.Equal(t, "https://spa.test/cb", got.RedirectURI)
+		assert.Equal(t, "family", got.FamilyID)
+		assert.Nil(t, got.UsedAt)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM authorization_codes WHERE code_hash =").
+			WithArgs("missing").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetByHash("missing")
+		assert.Equal(t, models.ErrorAuthorizationCodeNotFound, err)
+	})
+}
+
+func TestAuthorizationCodeRepository_MarkUsed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger.Logger = zap.NewNop()
+	repo := NewAuthorizationCodeRepository(db)
+	now := time.Now()
+
+	mock.ExpectExec("UPDATE authorization_codes SET used_at").
+		WithArgs(now, "hash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE authorization_codes SET used_at").
+		WithArgs(now, "hash").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	first, err := repo.MarkUsed("hash", now)
+	require.NoError(t, err)
+	assert.True(t, first)
+
+	second, err := repo.MarkUsed("hash", now)
+	require.NoError(t, err)
+	assert.False(t, second)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/logger"
+	"go.uber.org/zap"
+	"strings"
+)
+
+type ClientRepository interface {
+	GetAll() ([]models.Client, error)
+	GetByID(id string) (models.Client, error)
+	Create(client models.Client) (models.Client, error)
+	Update(client models.Client) (models.Client, error)
+	UpdateSecret(id, secretHash string) error
+	Delete(id string) error
+}
+
+type clientRepository struct {
+	db *sql.DB
+}
+
+func NewClientRepository(db *sql.DB) ClientRepository {
+	return &clientRepository{db: db}
+}
+
+const clientColumns = `id, secret_hash, name, public, first_party, grant_types, redirect_uris, scopes, exchange_audiences,
+		exchange_subject_audiences, access_token_ttl, refresh_token_ttl, remember_me_ttl, created_at, updated_at`
+
+// Списки хранятся через пробел: ни grant_type, ни scope, ни URI, ни аудитория
+// не содержат пробелов.
+func joinList(values []string) string {
+	return strings.Join(values, " ")
+}
+
+func splitList(value string) []string {
+	return strings.Fields(value)
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanClient(row rowScanner) (models.Client, error) {
+	var client models.Client
+	var grantTypes, redirectURIs, scopes, audiences, subjectAudiences string
+	err := row.Scan(
+		&client.ID,
+		&client.SecretHash,
+		&client.Name,
+		&client.Public,
+		&client.FirstParty,
+		&grantTypes,
+		&redirectURIs,
+		&scopes,
+		&audiences,
+		&subjectAudiences,
+		&client.AccessTokenTTL,
+		&client.RefreshTokenTTL,
+		&client.RememberMeTTL,
+		&client.CreatedAt,
+		&client.UpdatedAt,
+	)
+	client.GrantTypes = splitList(grantTypes)
+	client.RedirectURIs = splitList(redirectURIs)
+	client.Scopes = splitList(scopes)
+	client.ExchangeAudiences = splitList(audiences)
+	client.ExchangeSubjectAudiences = splitList(subjectAudiences)
+	return client, err
+}
+
+func (r *clientRepository) GetAll() ([]models.Client, error) {
+	query := `SELECT ` + clientColumns + ` FROM clients ORDER BY id`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		logger.Logger.Error("Ошибка при запросе всех клиентов",
+			zap.Error(err),
+			zap.String("метод", "GetAll"))
+		return nil, fmt.Errorf("ошибка при запросе всех клиентов: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Logger.Warn("Ошибка при закрытии rows",
+				zap.Error(err),
+				zap.String("метод", "GetAll"))
+		}
+	}()
+
+	clients := []models.Client{}
+	for rows.Next() {
+		client, err := scanClient(rows)
+		if err != nil {
+			logger.Logger.Error("Ошибка при сканировании данных клиента",
+				zap.Error(err),
+				zap.String("метод", "GetAll"))
+			return nil, fmt.Errorf("ошибка при сканировании данных клиента: %w", err)
+		}
+		clients = append(clients, client)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.Logger.Error("Ошибка при итерации по результатам запроса",
+			zap.Error(err),
+			zap.String("метод", "GetAll"))
+		return nil, fmt.Errorf("ошибка при итерации по результатам запроса: %w", err)
+	}
+	return clients, nil
+}
+
+func (r *clientRepository) GetByID(id string) (models.Client, error) {
+	query := `SELECT ` + clientColumns + ` FROM clients WHERE id = $1`
+	client, err := scanClient(r.db.QueryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Client{}, models.ErrorClientNotFound
+		}
+		logger.Logger.Error("Ошибка при получении клиента",
+			zap.Error(err),
+			zap.String("client_id", id),
+			zap.String("метод", "GetByID"))
+		return models.Client{}, fmt.Errorf("ошибка при получении клиента: %w", err)
+	}
+	return client, nil
+}
+
+func (r *clientRepository) Create(client models.Client) (models.Client, error) {
+	query := `INSERT INTO clients (` + clientColumns + `)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		 ON CONFLICT (id) DO NOTHING`
+
+	result, err := r.db.Exec(
+		query,
+		client.ID,
+		client.SecretHash,
+		client.Name,
+		client.Public,
+		client.FirstParty,
+		joinList(client.GrantTypes),
+		joinList(client.RedirectURIs),
+		joinList(client.Scopes),
+		joinList(client.ExchangeAudiences),
+		joinList(client.ExchangeSubjectAudiences),
+		client.AccessTokenTTL,
+		client.RefreshTokenTTL,
+		client.RememberMeTTL,
+		client.CreatedAt,
+		client.UpdatedAt,
+	)
+	if err != nil {
+		logger.Logger.Error("Ошибка при создании клиента",
+			zap.Error(err),
+			zap.String("client_id", client.ID),
+			zap.String("метод", "Create"))
+		return models.Client{}, fmt.Errorf("ошибка при создании клиента: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return models.Client{}, fmt.Errorf("ошибка при получении количества созданных строк: %w", err)
+	}
+	if rowsAffected == 0 {
+		return models.Client{}, models.ErrorClientExists
+	}
+
+	logger.Logger.Info("Клиент успешно создан",
+		zap.String("client_id", client.ID))
+	return client, nil
+}
+
+// Update меняет все поля клиента, кроме секрета и времени создания.
+func (r *clientRepository) Update(client models.Client) (models.Client, error) {
+	query := `UPDATE clients SET name = $1, public = $2, first_party = $3, grant_types = $4, redirect_uris = $5, scopes = $6,
+		 exchange_audiences = $7, exchange_subject_audiences = $8, access_token_ttl = $9, refresh_token_ttl = $10,
+		 remember_me_ttl = $11, updated_at = $12
+		 WHERE id = $13`
+
+	result, err := r.db.Exec(
+		query,
+		client.Name,
+		client.Public,
+		client.FirstParty,
+		joinList(client.GrantTypes),
+		joinList(client.RedirectURIs),
+		joinList(client.Scopes),
+		joinList(client.ExchangeAudiences),
+		joinList(client.ExchangeSubjectAudiences),
+		client.AccessTokenTTL,
+		client.RefreshTokenTTL,
+		client.RememberMeTTL,
+		client.UpdatedAt,
+		client.ID,
+	)
+	if err != nil {
+		logger.Logger.Error("Ошибка при обновлении клиента",
+			zap.Error(err),
+			zap.String("client_id", client.ID),
+			zap.String("метод", "Update"))
+		return models.Client{}, fmt.Errorf("ошибка при обновлении клиента: %w", err)
+	}
+	if err := requireAffected(result, models.ErrorClientNotFound); err != nil {
+		return models.Client{}, err
+	}
+	return r.GetByID(client.ID)
+}
+
+func (r *clientRepository) UpdateSecret(id, secretHash string) error {
+	query := `UPDATE clients SET secret_hash = $1 WHERE id = $2`
+	result, err := r.db.Exec(query, secretHash, id)
+	if err != nil {
+		logger.Logger.Error("Ошибка при смене секрета клиента",
+			zap.Error(err),
+			zap.String("client_id", id),
+			zap.String("метод", "UpdateSecret"))
+		return fmt.Errorf("ошибка при смене секрета клиента: %w", err)
+	}
+	return requireAffected(result, models.ErrorClientNotFound)
+}
+
+func (r *clientRepository) Delete(id string) error {
+	query := `DELETE FROM clients WHERE id = $1`
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		logger.Logger.Error("Ошибка при удалении клиента",
+			zap.Error(err),
+			zap.String("client_id", id),
+			zap.String("метод", "Delete"))
+		return fmt.Errorf("ошибка при удалении клиента: %w", err)
+	}
+	if err := requireAffected(result, models.ErrorClientNotFound); err != nil {
+		return err
+	}
+
+	logger.Logger.Info("Клиент успешно удален",
+		zap.String("client_id", id))
+	return nil
+}
+
+// requireAffected возвращает notFound, если запрос не затронул ни одной строки.
+func requireAffected(result sql.Result, notFound error) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка при получении количества обновленных строк: %w", err)
+	}
+	if rowsAffected == 0 {
+		return notFound
+	}
+	return nil
+}
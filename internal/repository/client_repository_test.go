@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestClientRepository_GetByID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger.Logger = zap.NewNop()
+	repo := NewClientRepository(db)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	columns := []string{"id", "secret_hash", "name", "public", "first_party", "grant_types", "redirect_uris", "scopes", "exchange_audiences",
+		"exchange_subject_audiences", "access_token_ttl", "refresh_token_ttl", "remember_me_ttl", "created_at", "updated_at"}
+
+	t.Run("Existing client", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM clients WHERE id =").
+			WithArgs("spa").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("spa", "", "SPA", true, true,
+				"authorization_code refresh_token", "https://spa.test/cb https://spa.test/silent", "openid profile", "orders billing",
+				"api", 300, 0, 0, now, now))
+
+		got, err := repo.GetByID("spa")
+		require.NoError(t, err)
+		assert.True(t, got.Public)
+		assert.True(t, got.FirstParty)
+		assert.Equal(t, []string{"authorization_code", "refresh_token"}, got.GrantTypes)
+		assert.Equal(t, []string{"https://spa.test/cb", "https://spa.test/silent"}, got.RedirectURIs)
+		assert.Equal(t, []string{"openid", "profile"}, got.Scopes)
+		assert.Equal(t, []string{"orders", "billing"}, got.ExchangeAudiences)
+		assert.Equal(t, []string{"api"}, got.ExchangeSubjectAudiences)
+		assert.Equal(t, int64(300), got.AccessTokenTTL)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM clients WHERE id =").
+			WithArgs("missing").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetByID("missing")
+		assert.Equal(t, models.ErrorClientNotFound, err)
+	})
+}
+
+func TestClientRepository_Create(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger.Logger = zap.NewNop()
+	repo := NewClientRepository(db)
+	client := models.Client{ID: "spa", Name: "SPA", GrantTypes: []string{"authorization_code"}}
+
+	t.Run("Created", func(t *testing.T) {
+		mock.ExpectExec("INSERT INTO clients").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		got, err := repo.Create(client)
+		require.NoError(t, err)
+		assert.Equal(t, "spa", got.ID)
+	})
+
+	t.Run("Duplicate client_id", func(t *testing.T) {
+		mock.ExpectExec("INSERT INTO clients").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		_, err := repo.Create(client)
+		assert.Equal(t, models.ErrorClientExists, err)
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/logger"
+	"go.uber.org/zap"
+	"time"
+)
+
+type DeviceCodeRepository interface {
+	Create(code models.DeviceCode) error
+	GetByDeviceCodeHash(hash string) (models.DeviceCode, error)
+	GetByUserCode(userCode string) (models.DeviceCode, error)
+	RecordPoll(hash string, polledAt time.Time, interval int) error
+	SetStatus(hash, from, to string, userID int) (bool, error)
+}
+
+type deviceCodeRepository struct {
+	db *sql.DB
+}
+
+func NewDeviceCodeRepository(db *sql.DB) DeviceCodeRepository {
+	return &deviceCodeRepository{db: db}
+}
+
+const deviceCodeColumns = `device_code_hash, user_code, client_id, scope, status, user_id, interval, last_polled_at, expires_at, created_at`
+
+// Create сохраняет запрос. Совпадение user_code с уже выданным
+// возвращает models.ErrorUserCodeExists, чтобы вызывающий сгенерировал другой код.
+func (r *deviceCodeRepository) Create(code models.DeviceCode) error {
+	query := `INSERT INTO device_codes (` + deviceCodeColumns + `)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 ON CONFLICT DO NOTHING`
+
+	result, err := r.db.Exec(
+		query,
+		code.DeviceCodeHash,
+		code.UserCode,
+		code.ClientID,
+		code.Scope,
+		code.Status,
+		code.UserID,
+		code.Interval,
+		code.LastPolledAt,
+		code.ExpiresAt,
+		code.CreatedAt,
+	)
+	if err != nil {
+		logger.Logger.Error("Ошибка при сохранении кода устройства",
+			zap.Error(err),
+			zap.String("client_id", code.ClientID),
+			zap.String("метод", "Create"))
+		return fmt.Errorf("ошибка при сохранении кода устройства: %w", err)
+	}
+	return requireAffected(result, models.ErrorUserCodeExists)
+}
+
+func (r *deviceCodeRepository) GetByDeviceCodeHash(hash string) (models.DeviceCode, error) {
+	query := `SELECT ` + deviceCodeColumns + ` FROM device_codes WHERE device_code_hash = $1`
+	return r.get(query, hash, "GetByDeviceCodeHash")
+}
+
+func (r *deviceCodeRepository) GetByUserCode(userCode string) (models.DeviceCode, error) {
+	query := `SELECT ` + deviceCodeColumns + ` FROM device_codes WHERE user_code = $1`
+	return r.get(query, userCode, "GetByUserCode")
+}
+
+func (r *deviceCodeRepository) get(query, arg, method string) (models.DeviceCode, error) {
+	var code models.DeviceCode
+	var lastPolledAt sql.NullTime
+	err := r.db.QueryRow(query, arg).Scan(
+		&code.DeviceCodeHash,
+		&code.UserCode,
+		&code.ClientID,
+		&code.Scope,
+		&code.Status,
+		&code.UserID,
+		&code.Interval,
+		&lastPolledAt,
+		&code.ExpiresAt,
+		&code.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.DeviceCode{}, models.ErrorDeviceCodeNotFound
+		}
+		logger.Logger.Error("Ошибка при получении кода устройства",
+			zap.Error(err),
+			zap.String("метод", method))
+		return models.DeviceCode{}, fmt.Errorf("ошибка при получении кода устройства: %w", err)
+	}
+
+	if lastPolledAt.Valid {
+		code.LastPolledAt = &lastPolledAt.Time
+	}
+	return code, nil
+}
+
+// RecordPoll запоминает время опроса и текущий интервал опроса.
+func (r *deviceCodeRepository) RecordPoll(hash string, polledAt time.Time, interval int) error {
+	query := `UPDATE device_codes SET last_polled_at = $1, interval = $2 WHERE device_code_hash = $3`
+	if _, err := r.db.Exec(query, polledAt, interval, hash); err != nil {
+		logger.Logger.Error("Ошибка при учете опроса кода устройства",
+			zap.Error(err),
+			zap.String("метод", "RecordPoll"))
+		return fmt.Errorf("ошибка при учете опроса кода устройства: %w", err)
+	}
+	return nil
+}
+
+// SetStatus переводит запрос из состояния from в to. Возвращает false, если
+// запрос уже был в другом состоянии: так подтверждение и выдача токенов
+// происходят ровно один раз. userID - пользователь, принявший решение.
+func (r *deviceCodeRepository) SetStatus(hash, from, to string, userID int) (bool, error) {
+	query := `UPDATE device_codes SET status = $1, user_id = $2 WHERE device_code_hash = $3 AND status = $4`
+	result, err := r.db.Exec(query, to, userID, hash, from)
+	if err != nil {
+		logger.Logger.Error("Ошибка при смене состояния кода устройства",
+			zap.Error(err),
+			zap.String("status", to),
+			zap.String("метод", "SetStatus"))
+		return false, fmt.Errorf("ошибка при смене состояния кода устройства: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("ошибка при получении количества обновленных строк: %w", err)
+	}
+	return rowsAffected == 1, nil
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDeviceCodeRepository_Create(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger.Logger = zap.NewNop()
+	repo := NewDeviceCodeRepository(db)
+
+	now := time.Now()
+	code := models.DeviceCode{
+		DeviceCodeHash: "hash",
+		UserCode:       "BCDFGHJK",
+		ClientID:       "tv",
+		Scope:          "openid",
+		Status:         models.DeviceStatusPending,
+		Interval:       5,
+		ExpiresAt:      now.Add(10 * time.Minute),
+		CreatedAt:      now,
+	}
+
+	mock.ExpectExec("INSERT INTO device_codes").
+		WithArgs("hash", "BCDFGHJK", "tv", "openid", models.DeviceStatusPending, 0, 5, nil, code.ExpiresAt, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO device_codes").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.NoError(t, repo.Create(code))
+	assert.Equal(t, models.ErrorUserCodeExists, repo.Create(code))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeviceCodeRepository_GetByUserCode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger.Logger = zap.NewNop()
+	repo := NewDeviceCodeRepository(db)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	columns := []string{"device_code_hash", "user_code", "client_id", "scope", "status", "user_id", "interval", "last_polled_at", "expires_at", "created_at"}
+
+	t.Run("Polled code", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM device_codes WHERE user_code =").
+			WithArgs("BCDFGHJK").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("hash", "BCDFGHJK", "tv", "openid", "approved", 7, 10, now, now, now))
+
+		got, err := repo.GetByUserCode("BCDFGHJK")
+		require.NoError(t, err)
+		assert.Equal(t, "tv", got.ClientID)
+		assert.Equal(t, 7, got.UserID)
+		assert.Equal(t, 10, got.Interval)
+		require.NotNil(t, got.LastPolledAt)
+		assert.Equal(t, now, *got.LastPolledAt)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM device_codes WHERE user_code =").
+			WithArgs("MISSING").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetByUserCode("MISSING")
+		assert.Equal(t, models.ErrorDeviceCodeNotFound, err)
+	})
+}
+
+func TestDeviceCodeRepository_SetStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger.Logger = zap.NewNop()
+	repo := NewDeviceCodeRepository(db)
+
+	mock.ExpectExec("UPDATE device_codes SET status").
+		WithArgs(models.DeviceStatusApproved, 7, "hash", models.DeviceStatusPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE device_codes SET status").
+		WithArgs(models.DeviceStatusApproved, 7, "hash", models.DeviceStatusPending).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	first, err := repo.SetStatus("hash", models.DeviceStatusPending, models.DeviceStatusApproved, 7)
+	require.NoError(t, err)
+	assert.True(t, first)
+
+	second, err := repo.SetStatus("hash", models.DeviceStatusPending, models.DeviceStatusApproved, 7)
+	require.NoError(t, err)
+	assert.False(t, second)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/logger"
+	"go.uber.org/zap"
+	"time"
+)
+
+type OpaqueTokenRepository interface {
+	Create(token models.OpaqueToken) error
+	GetByHash(hash string) (models.OpaqueToken, error)
+	DeleteBySession(sessionID string) (int64, error)
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+type opaqueTokenRepository struct {
+	db *sql.DB
+}
+
+func NewOpaqueTokenRepository(db *sql.DB) OpaqueTokenRepository {
+	return &opaqueTokenRepository{db: db}
+}
+
+func (r *opaqueTokenRepository) Create(token models.OpaqueToken) error {
+	query := `INSERT INTO opaque_tokens (token_hash, jti, token_type, user_id, client_id, session_id, claims, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := r.db.Exec(
+		query,
+		token.TokenHash,
+		token.JTI,
+		token.TokenType,
+		token.UserID,
+		token.ClientID,
+		token.SessionID,
+		token.Claims,
+		token.ExpiresAt,
+		token.CreatedAt,
+	)
+	if err != nil {
+		logger.Logger.Error("Ошибка при сохранении непрозрачного токена",
+			zap.Error(err),
+			zap.String("jti", token.JTI),
+			zap.String("метод", "Create"))
+		return fmt.Errorf("ошибка при сохранении непрозрачного токена: %w", err)
+	}
+	return nil
+}
+
+func (r *opaqueTokenRepository) GetByHash(hash string) (models.OpaqueToken, error) {
+	query := `SELECT token_hash, jti, token_type, user_id, client_id, session_id, claims, expires_at, created_at
+		 FROM opaque_tokens WHERE token_hash = $1`
+
+	var token models.OpaqueToken
+	err := r.db.QueryRow(query, hash).Scan(
+		&token.TokenHash,
+		&token.JTI,
+		&token.TokenType,
+		&token.UserID,
+		&token.ClientID,
+		&token.SessionID,
+		&token.Claims,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OpaqueToken{}, models.ErrorOpaqueTokenNotFound
+		}
+		logger.Logger.Error("Ошибка при получении непрозрачного токена",
+			zap.Error(err),
+			zap.String("метод", "GetByHash"))
+		return models.OpaqueToken{}, fmt.Errorf("ошибка при получении непрозрачного токена: %w", err)
+	}
+	return token, nil
+}
+
+// DeleteBySession удаляет все токены сессии: после этого они перестают
+// приниматься без ожидания истечения срока.
+func (r *opaqueTokenRepository) DeleteBySession(sessionID string) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM opaque_tokens WHERE session_id = $1`, sessionID)
+	if err != nil {
+		logger.Logger.Error("Ошибка при удалении токенов сессии",
+			zap.Error(err),
+			zap.String("session_id", sessionID),
+			zap.String("метод", "DeleteBySession"))
+		return 0, fmt.Errorf("ошибка при удалении токенов сессии: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *opaqueTokenRepository) DeleteExpired(now time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM opaque_tokens WHERE expires_at <= $1`, now)
+	if err != nil {
+		logger.Logger.Error("Ошибка при удалении истекших непрозрачных токенов",
+			zap.Error(err),
+			zap.String("метод", "DeleteExpired"))
+		return 0, fmt.Errorf("ошибка при удалении истекших непрозрачных токенов: %w", err)
+	}
+	return result.RowsAffected()
+}
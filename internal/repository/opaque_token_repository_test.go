@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestOpaqueTokenRepository_GetByHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger.Logger = zap.NewNop()
+	repo := NewOpaqueTokenRepository(db)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	columns := []string{"token_hash", "jti", "token_type", "user_id", "client_id", "session_id", "claims", "expires_at", "created_at"}
+
+	t.Run("Existing token", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM opaque_tokens WHERE token_hash =").
+			WithArgs("hash").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("hash", "jti", "access", 7, "spa", "family", `{"user_id":7}`, now, now))
+
+		got, err := repo.GetByHash("hash")
+		require.NoError(t, err)
+		assert.Equal(t, 7, got.UserID)
+		assert.Equal(t, "family", got.SessionID)
+		assert.Equal(t, `{"user_id":7}`, got.Claims)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM opaque_tokens WHERE token_hash =").
+			WithArgs("missing").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetByHash("missing")
+		assert.Equal(t, models.ErrorOpaqueTokenNotFound, err)
+	})
+}
+
+func TestOpaqueTokenRepository_DeleteBySession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger.Logger = zap.NewNop()
+	repo := NewOpaqueTokenRepository(db)
+
+	mock.ExpectExec("DELETE FROM opaque_tokens WHERE session_id =").
+		WithArgs("family").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	deleted, err := repo.DeleteBySession("family")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/logger"
+	"go.uber.org/zap"
+	"time"
+)
+
+type RefreshTokenRepository interface {
+	Create(token models.RefreshToken) (models.RefreshToken, error)
+	GetByHash(hash string) (models.RefreshToken, error)
+	MarkUsed(id int, usedAt time.Time) (bool, error)
+	RevokeFamily(familyID string, revokedAt time.Time) error
+}
+
+type refreshTokenRepository struct {
+	db *sql.DB
+}
+
+func NewRefreshTokenRepository(db *sql.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+func (r *refreshTokenRepository) Create(token models.RefreshToken) (models.RefreshToken, error) {
+	query := `INSERT INTO refresh_tokens (family_id, token_hash, user_id, client_id, remember_me, scope, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING id`
+
+	err := r.db.QueryRow(
+		query,
+		token.FamilyID,
+		token.TokenHash,
+		token.UserID,
+		token.ClientID,
+		token.RememberMe,
+		token.Scope,
+		token.ExpiresAt,
+		token.CreatedAt,
+	).Scan(&token.ID)
+
+	if err != nil {
+		logger.Logger.Error("Ошибка при сохранении refresh токена",
+			zap.Error(err),
+			zap.Int("user_id", token.UserID),
+			zap.String("метод", "Create"))
+		return models.RefreshToken{}, fmt.Errorf("ошибка при сохранении refresh токена: %w", err)
+	}
+	return token, nil
+}
+
+func (r *refreshTokenRepository) GetByHash(hash string) (models.RefreshToken, error) {
+	query := `SELECT id, family_id, token_hash, user_id, client_id, remember_me, scope, expires_at, used_at, revoked_at, created_at
+		 FROM refresh_tokens WHERE token_hash = $1`
+
+	var token models.RefreshToken
+	var usedAt, revokedAt sql.NullTime
+	err := r.db.QueryRow(query, hash).Scan(
+		&token.ID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&token.UserID,
+		&token.ClientID,
+		&token.RememberMe,
+		&token.Scope,
+		&token.ExpiresAt,
+		&usedAt,
+		&revokedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.RefreshToken{}, models.ErrorRefreshTokenNotFound
+		}
+		logger.Logger.Error("Ошибка при получении refresh токена",
+			zap.Error(err),
+			zap.String("метод", "GetByHash"))
+		return models.RefreshToken{}, fmt.Errorf("ошибка при получении refresh токена: %w", err)
+	}
+
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return token, nil
+}
+
+// MarkUsed помечает токен использованным. Возвращает false, если токен уже
+// был использован: так одновременные запросы с одним токеном не пройдут оба.
+func (r *refreshTokenRepository) MarkUsed(id int, usedAt time.Time) (bool, error) {
+	query := `UPDATE refresh_tokens SET used_at = $1 WHERE id = $2 AND used_at IS NULL`
+	result, err := r.db.Exec(query, usedAt, id)
+	if err != nil {
+		logger.Logger.Error("Ошибка при использовании refresh токена",
+			zap.Error(err),
+			zap.Int("id", id),
+			zap.String("метод", "MarkUsed"))
+		return false, fmt.Errorf("ошибка при использовании refresh токена: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("ошибка при получении количества обновленных строк: %w", err)
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(familyID string, revokedAt time.Time) error {
+	query := `UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL`
+	if _, err := r.db.Exec(query, revokedAt, familyID); err != nil {
+		logger.Logger.Error("Ошибка при отзыве семейства refresh токенов",
+			zap.Error(err),
+			zap.String("family_id", familyID),
+			zap.String("метод", "RevokeFamily"))
+		return fmt.Errorf("ошибка при отзыве семейства refresh токенов: %w", err)
+	}
+
+	logger.Logger.Warn("Семейство refresh токенов отозвано",
+		zap.String("family_id", familyID))
+	return nil
+}
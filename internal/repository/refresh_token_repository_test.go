@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRefreshTokenRepository_GetByHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger.Logger = zap.NewNop()
+	repo := NewRefreshTokenRepository(db)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	columns := []string{"id", "family_id", "token_hash", "user_id", "client_id", "remember_me", "scope", "expires_at", "used_at", "revoked_at", "created_at"}
+
+	t.Run("Used token", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM refresh_tokens WHERE token_hash =").
+			WithArgs("hash").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "family", "hash", 7, "console", true, "profile", now, now, nil, now))
+
+		got, err := repo.GetByHash("hash")
+		require.NoError(t, err)
+		assert.Equal(t, "family", got.FamilyID)
+		assert.Equal(t, "console", got.ClientID)
+		assert.True(t, got.RememberMe)
+		assert.Equal(t, "profile", got.Scope)
+		require.NotNil(t, got.UsedAt)
+		assert.Nil(t, got.RevokedAt)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM refresh_tokens WHERE token_hash =").
+			WithArgs("missing").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetByHash("missing")
+		assert.Equal(t, models.ErrorRefreshTokenNotFound, err)
+	})
+}
+
+func TestRefreshTokenRepository_MarkUsed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger.Logger = zap.NewNop()
+	repo := NewRefreshTokenRepository(db)
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		mock    func()
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "First use",
+			mock: func() {
+				mock.ExpectExec("UPDATE refresh_tokens SET used_at").
+					WithArgs(now, 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			want: true,
+		},
+		{
+			name: "Already used",
+			mock: func() {
+				mock.ExpectExec("UPDATE refresh_tokens SET used_at").
+					WithArgs(now, 1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			want: false,
+		},
+		{
+			name: "Database error",
+			mock: func() {
+				mock.ExpectExec("UPDATE refresh_tokens SET used_at").
+					WithArgs(now, 1).
+					WillReturnError(errors.New("db error"))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.MarkUsed(1, now)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
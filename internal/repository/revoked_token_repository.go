@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/logger"
+	"go.uber.org/zap"
+	"time"
+)
+
+type RevokedTokenRepository interface {
+	Revoke(token models.RevokedToken) error
+	IsRevoked(jti string) (bool, error)
+	GetActive(now time.Time) ([]models.RevokedToken, error)
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+type revokedTokenRepository struct {
+	db *sql.DB
+}
+
+func NewRevokedTokenRepository(db *sql.DB) RevokedTokenRepository {
+	return &revokedTokenRepository{db: db}
+}
+
+func (r *revokedTokenRepository) Revoke(token models.RevokedToken) error {
+	query := `INSERT INTO revoked_tokens (jti, expires_at, revoked_at)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (jti) DO NOTHING`
+
+	if _, err := r.db.Exec(query, token.JTI, token.ExpiresAt, token.RevokedAt); err != nil {
+		logger.Logger.Error("Ошибка при отзыве токена",
+			zap.Error(err),
+			zap.String("jti", token.JTI),
+			zap.String("метод", "Revoke"))
+		return fmt.Errorf("ошибка при отзыве токена: %w", err)
+	}
+	return nil
+}
+
+func (r *revokedTokenRepository) IsRevoked(jti string) (bool, error) {
+	query := `SELECT 1 FROM revoked_tokens WHERE jti = $1`
+
+	var found int
+	err := r.db.QueryRow(query, jti).Scan(&found)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		logger.Logger.Error("Ошибка при проверке отзыва токена",
+			zap.Error(err),
+			zap.String("jti", jti),
+			zap.String("метод", "IsRevoked"))
+		return false, fmt.Errorf("ошибка при проверке отзыва токена: %w", err)
+	}
+	return true, nil
+}
+
+func (r *revokedTokenRepository) GetActive(now time.Time) ([]models.RevokedToken, error) {
+	query := `SELECT jti, expires_at, revoked_at FROM revoked_tokens WHERE expires_at > $1`
+	rows, err := r.db.Query(query, now)
+	if err != nil {
+		logger.Logger.Error("Ошибка при получении отозванных токенов",
+			zap.Error(err),
+			zap.String("метод", "GetActive"))
+		return nil, fmt.Errorf("ошибка при получении отозванных токенов: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []models.RevokedToken
+	for rows.Next() {
+		var token models.RevokedToken
+		if err := rows.Scan(&token.JTI, &token.ExpiresAt, &token.RevokedAt); err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании отозванного токена: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по отозванным токенам: %w", err)
+	}
+	return tokens, nil
+}
+
+func (r *revokedTokenRepository) DeleteExpired(now time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM revoked_tokens WHERE expires_at <= $1`, now)
+	if err != nil {
+		logger.Logger.Error("Ошибка при удалении истекших отозванных токенов",
+			zap.Error(err),
+			zap.String("метод", "DeleteExpired"))
+		return 0, fmt.Errorf("ошибка при удалении истекших отозванных токенов: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fire9900/auth/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRevokedTokenRepository_IsRevoked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger.Logger = zap.NewNop()
+	repo := NewRevokedTokenRepository(db)
+
+	tests := []struct {
+		name    string
+		mock    func()
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "Revoked",
+			mock: func() {
+				mock.ExpectQuery("SELECT 1 FROM revoked_tokens WHERE jti =").
+					WithArgs("jti").
+					WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+			},
+			want: true,
+		},
+		{
+			name: "Not revoked",
+			mock: func() {
+				mock.ExpectQuery("SELECT 1 FROM revoked_tokens WHERE jti =").
+					WithArgs("jti").
+					WillReturnRows(sqlmock.NewRows([]string{"1"}))
+			},
+			want: false,
+		},
+		{
+			name: "Database error",
+			mock: func() {
+				mock.ExpectQuery("SELECT 1 FROM revoked_tokens WHERE jti =").
+					WithArgs("jti").
+					WillReturnError(errors.New("db error"))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.IsRevoked("jti")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
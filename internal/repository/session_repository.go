@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/logger"
+	"go.uber.org/zap"
+	"time"
+)
+
+type SessionRepository interface {
+	Create(session models.Session) error
+	GetByID(id string) (models.Session, error)
+	GetActiveByUser(userID int, now time.Time) ([]models.Session, error)
+	Touch(id string, lastSeenAt, expiresAt time.Time) error
+	Revoke(id string, revokedAt time.Time) error
+	RevokeByUser(userID int, revokedAt time.Time) error
+}
+
+type sessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+const sessionColumns = `id, user_id, client_id, device_name, user_agent, ip, created_at, last_seen_at, expires_at, revoked_at`
+
+func scanSession(row rowScanner) (models.Session, error) {
+	var session models.Session
+	var revokedAt sql.NullTime
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.ClientID,
+		&session.DeviceName,
+		&session.UserAgent,
+		&session.IP,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.ExpiresAt,
+		&revokedAt,
+	)
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return session, err
+}
+
+func (r *sessionRepository) Create(session models.Session) error {
+	query := `INSERT INTO sessions (id, user_id, client_id, device_name, user_agent, ip, created_at, last_seen_at, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := r.db.Exec(
+		query,
+		session.ID,
+		session.UserID,
+		session.ClientID,
+		session.DeviceName,
+		session.UserAgent,
+		session.IP,
+		session.CreatedAt,
+		session.LastSeenAt,
+		session.ExpiresAt,
+	)
+	if err != nil {
+		logger.Logger.Error("Ошибка при создании сессии",
+			zap.Error(err),
+			zap.Int("user_id", session.UserID),
+			zap.String("метод", "Create"))
+		return fmt.Errorf("ошибка при создании сессии: %w", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) GetByID(id string) (models.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1`
+
+	session, err := scanSession(r.db.QueryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Session{}, models.ErrorSessionNotFound
+		}
+		logger.Logger.Error("Ошибка при получении сессии",
+			zap.Error(err),
+			zap.String("id", id),
+			zap.String("метод", "GetByID"))
+		return models.Session{}, fmt.Errorf("ошибка при получении сессии: %w", err)
+	}
+	return session, nil
+}
+
+// GetActiveByUser возвращает неотозванные сессии пользователя, refresh токены
+// которых еще не истекли, начиная с последней активной.
+func (r *sessionRepository) GetActiveByUser(userID int, now time.Time) ([]models.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions
+		 WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2 ORDER BY last_seen_at DESC`
+	rows, err := r.db.Query(query, userID, now)
+	if err != nil {
+		logger.Logger.Error("Ошибка при запросе сессий пользователя",
+			zap.Error(err),
+			zap.Int("user_id", userID),
+			zap.String("метод", "GetActiveByUser"))
+		return nil, fmt.Errorf("ошибка при запросе сессий пользователя: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Logger.Warn("Ошибка при закрытии rows",
+				zap.Error(err),
+				zap.String("метод", "GetActiveByUser"))
+		}
+	}()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			logger.Logger.Error("Ошибка при сканировании данных сессии",
+				zap.Error(err),
+				zap.String("метод", "GetActiveByUser"))
+			return nil, fmt.Errorf("ошибка при сканировании данных сессии: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err = rows.Err(); err != nil {
+		logger.Logger.Error("Ошибка при итерации по результатам запроса",
+			zap.Error(err),
+			zap.String("метод", "GetActiveByUser"))
+		return nil, fmt.Errorf("ошибка при итерации по результатам запроса: %w", err)
+	}
+	return sessions, nil
+}
+
+// Touch отмечает активность сессии при выдаче ей новых токенов. Для семейств
+// refresh токенов без сессии (например, выданных по коду авторизации)
+// запрос ничего не меняет.
+func (r *sessionRepository) Touch(id string, lastSeenAt, expiresAt time.Time) error {
+	query := `UPDATE sessions SET last_seen_at = $1, expires_at = $2 WHERE id = $3 AND revoked_at IS NULL`
+	if _, err := r.db.Exec(query, lastSeenAt, expiresAt, id); err != nil {
+		logger.Logger.Error("Ошибка при обновлении сессии",
+			zap.Error(err),
+			zap.String("id", id),
+			zap.String("метод", "Touch"))
+		return fmt.Errorf("ошибка при обновлении сессии: %w", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) Revoke(id string, revokedAt time.Time) error {
+	query := `UPDATE sessions SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`
+	if _, err := r.db.Exec(query, revokedAt, id); err != nil {
+		logger.Logger.Error("Ошибка при отзыве сессии",
+			zap.Error(err),
+			zap.String("id", id),
+			zap.String("метод", "Revoke"))
+		return fmt.Errorf("ошибка при отзыве сессии: %w", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) RevokeByUser(userID int, revokedAt time.Time) error {
+	query := `UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`
+	if _, err := r.db.Exec(query, revokedAt, userID); err != nil {
+		logger.Logger.Error("Ошибка при отзыве сессий пользователя",
+			zap.Error(err),
+			zap.Int("user_id", userID),
+			zap.String("метод", "RevokeByUser"))
+		return fmt.Errorf("ошибка при отзыве сессий пользователя: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var sessionTestColumns = []string{"id", "user_id", "client_id", "device_name", "user_agent", "ip",
+	"created_at", "last_seen_at", "expires_at", "revoked_at"}
+
+func TestSessionRepository_GetByID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger.Logger = zap.NewNop()
+	repo := NewSessionRepository(db)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	t.Run("Revoked session", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM sessions WHERE id =").
+			WithArgs("family").
+			WillReturnRows(sqlmock.NewRows(sessionTestColumns).
+				AddRow("family", 7, "", "Ноутбук", "Firefox", "10.0.0.1", now, now, now.Add(time.Hour), now))
+
+		got, err := repo.GetByID("family")
+		require.NoError(t, err)
+		assert.Equal(t, 7, got.UserID)
+		assert.Equal(t, "Ноутбук", got.DeviceName)
+		require.NotNil(t, got.RevokedAt)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM sessions WHERE id =").
+			WithArgs("missing").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetByID("missing")
+		assert.Equal(t, models.ErrorSessionNotFound, err)
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepository_GetActiveByUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger.Logger = zap.NewNop()
+	repo := NewSessionRepository(db)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	mock.ExpectQuery("SELECT (.+) FROM sessions WHERE user_id = (.+) AND revoked_at IS NULL AND expires_at >").
+		WithArgs(7, now).
+		WillReturnRows(sqlmock.NewRows(sessionTestColumns).
+			AddRow("phone", 7, "", "Телефон", "Safari", "10.0.0.2", now, now, now.Add(time.Hour), nil).
+			AddRow("laptop", 7, "spa", "", "Firefox", "10.0.0.1", now, now.Add(-time.Hour), now.Add(time.Hour), nil))
+
+	sessions, err := repo.GetActiveByUser(7, now)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "phone", sessions[0].ID)
+	assert.Equal(t, "spa", sessions[1].ClientID)
+	assert.Nil(t, sessions[1].RevokedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepository_TouchAndRevoke(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger.Logger = zap.NewNop()
+	repo := NewSessionRepository(db)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	mock.ExpectExec("UPDATE sessions SET last_seen_at = (.+), expires_at = (.+) WHERE id = (.+) AND revoked_at IS NULL").
+		WithArgs(now, now.Add(time.Hour), "family").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE sessions SET revoked_at = (.+) WHERE id = (.+) AND revoked_at IS NULL").
+		WithArgs(now, "family").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectExec("UPDATE sessions SET revoked_at = (.+) WHERE user_id = (.+) AND revoked_at IS NULL").
+		WithArgs(now, 7).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	require.NoError(t, repo.Touch("family", now, now.Add(time.Hour)))
+	require.NoError(t, repo.Revoke("family", now))
+	require.NoError(t, repo.RevokeByUser(7, now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
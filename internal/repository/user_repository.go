@@ -17,6 +17,10 @@ type UserRepository interface {
 	Update(id int, user models.User) (models.User, error)
 	Delete(id int) error
 	CheckPassword(id int, password string) bool
+	GetTokenVersion(id int) (int, error)
+	BumpTokenVersion(id int) (int, error)
+	Suspend(id int) error
+	UpdatePasswordHash(id int, hash string) error
 }
 
 type userRepository struct {
@@ -30,7 +34,7 @@ func NewUserRepository(db *sql.DB) UserRepository {
 func (r *userRepository) GetAll() ([]models.User, error) {
 	logger.Logger.Info("Получение всех пользователей")
 
-	query := `SELECT id, name, email, password, role FROM users ORDER BY id`
+	query := `SELECT id, name, email, password, role, token_version, suspended FROM users ORDER BY id`
 	rows, err := r.db.Query(query)
 	if err != nil {
 		logger.Logger.Error("Ошибка при запросе всех пользователей",
@@ -49,7 +53,7 @@ func (r *userRepository) GetAll() ([]models.User, error) {
 	var users []models.User
 	for rows.Next() {
 		var user models.User
-		err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role)
+		err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.TokenVersion, &user.Suspended)
 		if err != nil {
 			logger.Logger.Error("Ошибка при сканировании данных пользователя",
 				zap.Error(err),
@@ -75,11 +79,11 @@ func (r *userRepository) GetByID(id int) (models.User, error) {
 	logger.Logger.Info("Получение пользователя по ID",
 		zap.Int("id", id))
 
-	query := `SELECT id, name, email, password, role FROM users WHERE id = $1`
+	query := `SELECT id, name, email, password, role, token_version, suspended FROM users WHERE id = $1`
 	row := r.db.QueryRow(query, id)
 
 	var user models.User
-	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role)
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.TokenVersion, &user.Suspended)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			logger.Logger.Warn("Пользователь не найден",
@@ -135,11 +139,11 @@ func (r *userRepository) Create(user models.User) (models.User, error) {
 }
 
 func (r *userRepository) GetByEmail(email string) (models.User, error) {
-	query := `SELECT id, name, email, password, role FROM users WHERE email = $1`
+	query := `SELECT id, name, email, password, role, token_version, suspended FROM users WHERE email = $1`
 	row := r.db.QueryRow(query, email)
 
 	var user models.User
-	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role)
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.TokenVersion, &user.Suspended)
 	if err != nil {
 		fmt.Println(err.Error())
 		if errors.Is(err, sql.ErrNoRows) {
@@ -189,14 +193,6 @@ func (r *userRepository) Update(id int, user models.User) (models.User, error) {
 		return models.User{}, fmt.Errorf("ошибка при обновлении пользователя: %w", err)
 	}
 
-	if err = updatedUser.HashPassword(); err != nil {
-		logger.Logger.Error("Ошибка при хешировании пароля",
-			zap.Error(err),
-			zap.Int("id", id),
-			zap.String("метод", "Update"))
-		return models.User{}, err
-	}
-
 	logger.Logger.Info("Данные пользователя успешно обновлены",
 		zap.Int("id", updatedUser.ID),
 		zap.String("email", updatedUser.Email))
@@ -264,3 +260,78 @@ func (r *userRepository) CheckPassword(id int, password string) bool {
 
 	return isValid
 }
+
+func (r *userRepository) GetTokenVersion(id int) (int, error) {
+	var version int
+	err := r.db.QueryRow(`SELECT token_version FROM users WHERE id = $1`, id).Scan(&version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrorUserNotFound
+		}
+		logger.Logger.Error("Ошибка при получении версии токенов пользователя",
+			zap.Error(err),
+			zap.Int("id", id),
+			zap.String("метод", "GetTokenVersion"))
+		return 0, fmt.Errorf("ошибка при получении версии токенов пользователя: %w", err)
+	}
+	return version, nil
+}
+
+// BumpTokenVersion увеличивает версию токенов пользователя и возвращает новую.
+func (r *userRepository) BumpTokenVersion(id int) (int, error) {
+	query := `UPDATE users SET token_version = token_version + 1 WHERE id = $1 RETURNING token_version`
+
+	var version int
+	err := r.db.QueryRow(query, id).Scan(&version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrorUserNotFound
+		}
+		logger.Logger.Error("Ошибка при увеличении версии токенов пользователя",
+			zap.Error(err),
+			zap.Int("id", id),
+			zap.String("метод", "BumpTokenVersion"))
+		return 0, fmt.Errorf("ошибка при увеличении версии токенов пользователя: %w", err)
+	}
+
+	logger.Logger.Info("Версия токенов пользователя увеличена",
+		zap.Int("id", id),
+		zap.Int("version", version))
+	return version, nil
+}
+
+// UpdatePasswordHash заменяет только хеш пароля, например при пересчете
+// хеша устаревшего формата; версия токенов при этом не меняется.
+func (r *userRepository) UpdatePasswordHash(id int, hash string) error {
+	if _, err := r.db.Exec(`UPDATE users SET password = $1 WHERE id = $2`, hash, id); err != nil {
+		logger.Logger.Error("Ошибка при обновлении хеша пароля",
+			zap.Error(err),
+			zap.Int("id", id),
+			zap.String("метод", "UpdatePasswordHash"))
+		return fmt.Errorf("ошибка при обновлении хеша пароля: %w", err)
+	}
+	return nil
+}
+
+func (r *userRepository) Suspend(id int) error {
+	result, err := r.db.Exec(`UPDATE users SET suspended = 1 WHERE id = $1`, id)
+	if err != nil {
+		logger.Logger.Error("Ошибка при блокировке пользователя",
+			zap.Error(err),
+			zap.Int("id", id),
+			zap.String("метод", "Suspend"))
+		return fmt.Errorf("ошибка при блокировке пользователя: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка при получении количества измененных строк: %w", err)
+	}
+	if rowsAffected == 0 {
+		return models.ErrorUserNotFound
+	}
+
+	logger.Logger.Info("Пользователь заблокирован",
+		zap.Int("id", id))
+	return nil
+}
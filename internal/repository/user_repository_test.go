@@ -30,22 +30,22 @@ func TestUserRepository_GetAll(t *testing.T) {
 		{
 			name: "Success",
 			mock: func() {
-				rows := sqlmock.NewRows([]string{"id", "name", "email", "password", "role"}).
-					AddRow(1, "User1", "user1@test.com", "pass1", "user").
-					AddRow(2, "User2", "user2@test.com", "pass2", "admin")
-				mock.ExpectQuery("SELECT id, name, email, password, role FROM users ORDER BY id").WillReturnRows(rows)
+				rows := sqlmock.NewRows([]string{"id", "name", "email", "password", "role", "token_version", "suspended"}).
+					AddRow(1, "User1", "user1@test.com", "pass1", "user", 0, false).
+					AddRow(2, "User2", "user2@test.com", "pass2", "admin", 2, false)
+				mock.ExpectQuery("SELECT id, name, email, password, role, token_version, suspended FROM users ORDER BY id").WillReturnRows(rows)
 			},
 			want: []models.User{
 				{ID: 1, Name: "User1", Email: "user1@test.com", Password: "pass1", Role: "user"},
-				{ID: 2, Name: "User2", Email: "user2@test.com", Password: "pass2", Role: "admin"},
+				{ID: 2, Name: "User2", Email: "user2@test.com", Password: "pass2", Role: "admin", TokenVersion: 2},
 			},
 			wantErr: false,
 		},
 		{
 			name: "Empty result",
 			mock: func() {
-				rows := sqlmock.NewRows([]string{"id", "name", "email", "password", "role"})
-				mock.ExpectQuery("SELECT id, name, email, password, role FROM users ORDER BY id").WillReturnRows(rows)
+				rows := sqlmock.NewRows([]string{"id", "name", "email", "password", "role", "token_version", "suspended"})
+				mock.ExpectQuery("SELECT id, name, email, password, role, token_version, suspended FROM users ORDER BY id").WillReturnRows(rows)
 			},
 			want:    []models.User(nil),
 			wantErr: false,
@@ -53,7 +53,7 @@ func TestUserRepository_GetAll(t *testing.T) {
 		{
 			name: "Query error",
 			mock: func() {
-				mock.ExpectQuery("SELECT id, name, email, password, role FROM users ORDER BY id").WillReturnError(errors.New("query error"))
+				mock.ExpectQuery("SELECT id, name, email, password, role, token_version, suspended FROM users ORDER BY id").WillReturnError(errors.New("query error"))
 			},
 			want:    nil,
 			wantErr: true,
@@ -95,9 +95,9 @@ func TestUserRepository_GetByID(t *testing.T) {
 			name: "Success",
 			id:   1,
 			mock: func() {
-				row := sqlmock.NewRows([]string{"id", "name", "email", "password", "role"}).
-					AddRow(1, "User1", "user1@test.com", "pass1", "user")
-				mock.ExpectQuery("SELECT id, name, email, password, role FROM users WHERE id =").WithArgs(1).WillReturnRows(row)
+				row := sqlmock.NewRows([]string{"id", "name", "email", "password", "role", "token_version", "suspended"}).
+					AddRow(1, "User1", "user1@test.com", "pass1", "user", 0, false)
+				mock.ExpectQuery("SELECT id, name, email, password, role, token_version, suspended FROM users WHERE id =").WithArgs(1).WillReturnRows(row)
 			},
 			want:    models.User{ID: 1, Name: "User1", Email: "user1@test.com", Password: "pass1", Role: "user"},
 			wantErr: nil,
@@ -106,7 +106,7 @@ func TestUserRepository_GetByID(t *testing.T) {
 			name: "Not found",
 			id:   999,
 			mock: func() {
-				mock.ExpectQuery("SELECT id, name, email, password, role FROM users WHERE id =").WithArgs(999).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery("SELECT id, name, email, password, role, token_version, suspended FROM users WHERE id =").WithArgs(999).WillReturnError(sql.ErrNoRows)
 			},
 			want:    models.User{},
 			wantErr: models.ErrorUserNotFound,
@@ -115,7 +115,7 @@ func TestUserRepository_GetByID(t *testing.T) {
 			name: "Database error",
 			id:   1,
 			mock: func() {
-				mock.ExpectQuery("SELECT id, name, email, password, role FROM users WHERE id =").WithArgs(1).WillReturnError(errors.New("db error"))
+				mock.ExpectQuery("SELECT id, name, email, password, role, token_version, suspended FROM users WHERE id =").WithArgs(1).WillReturnError(errors.New("db error"))
 			},
 			want:    models.User{},
 			wantErr: errors.New("ошибка при получении пользователя по ID: db error"),
@@ -326,17 +326,17 @@ func TestUserRepository_CheckPassword(t *testing.T) {
 		}
 		user.Password = "correctpass"
 
-		mock.ExpectQuery("SELECT id, name, email, password, role FROM users WHERE id =").
+		mock.ExpectQuery("SELECT id, name, email, password, role, token_version, suspended FROM users WHERE id =").
 			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "password", "role"}).
-				AddRow(user.ID, "test", "test@test.com", user.Password, "user"))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "password", "role", "token_version", "suspended"}).
+				AddRow(user.ID, "test", "test@test.com", user.Password, "user", 0, false))
 
 		valid := repo.CheckPassword(1, "wrongpass")
 		assert.False(t, valid)
 	})
 
 	t.Run("User not found", func(t *testing.T) {
-		mock.ExpectQuery("SELECT id, name, email, password, role FROM users WHERE id =").
+		mock.ExpectQuery("SELECT id, name, email, password, role, token_version, suspended FROM users WHERE id =").
 			WithArgs(999).
 			WillReturnError(sql.ErrNoRows)
 
@@ -344,3 +344,68 @@ func TestUserRepository_CheckPassword(t *testing.T) {
 		assert.False(t, valid)
 	})
 }
+
+func TestUserRepository_BumpTokenVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger.Logger = zap.NewNop()
+	repo := NewUserRepository(db)
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectQuery("UPDATE users SET token_version = token_version \\+ 1 WHERE id =").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"token_version"}).AddRow(3))
+
+		version, err := repo.BumpTokenVersion(1)
+		require.NoError(t, err)
+		assert.Equal(t, 3, version)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		mock.ExpectQuery("UPDATE users SET token_version = token_version \\+ 1 WHERE id =").
+			WithArgs(999).
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.BumpTokenVersion(999)
+		assert.Equal(t, models.ErrorUserNotFound, err)
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_Suspend(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger.Logger = zap.NewNop()
+	repo := NewUserRepository(db)
+
+	mock.ExpectExec("UPDATE users SET suspended = 1 WHERE id =").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE users SET suspended = 1 WHERE id =").
+		WithArgs(999).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, repo.Suspend(1))
+	assert.Equal(t, models.ErrorUserNotFound, repo.Suspend(999))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_UpdatePasswordHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger.Logger = zap.NewNop()
+	repo := NewUserRepository(db)
+
+	mock.ExpectExec("UPDATE users SET password = (.+) WHERE id =").
+		WithArgs("$argon2id$hash", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, repo.UpdatePasswordHash(1, "$argon2id$hash"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/internal/usecase"
+	"github.com/fire9900/auth/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// authorizationRequest читает параметры запроса авторизации из строки
+// запроса или из скрытых полей формы входа.
+func authorizationRequest(c *gin.Context) usecase.AuthorizationRequest {
+	_ = c.Request.ParseForm()
+	form := c.Request.Form
+	return usecase.AuthorizationRequest{
+		ResponseType:        form.Get("response_type"),
+		ClientID:            form.Get("client_id"),
+		RedirectURI:         form.Get("redirect_uri"),
+		Scope:               form.Get("scope"),
+		State:               form.Get("state"),
+		CodeChallenge:       form.Get("code_challenge"),
+		CodeChallengeMethod: form.Get("code_challenge_method"),
+		Nonce:               form.Get("nonce"),
+	}
+}
+
+func authorizationParams(req usecase.AuthorizationRequest) map[string]string {
+	params := map[string]string{
+		"response_type":         req.ResponseType,
+		"client_id":             req.ClientID,
+		"redirect_uri":          req.RedirectURI,
+		"scope":                 req.Scope,
+		"state":                 req.State,
+		"code_challenge":        req.CodeChallenge,
+		"code_challenge_method": req.CodeChallengeMethod,
+		"nonce":                 req.Nonce,
+	}
+	for name, value := range params {
+		if value == "" {
+			delete(params, name)
+		}
+	}
+	return params
+}
+
+// redirectWith возвращает пользователя на redirect_uri клиента с параметрами
+// ответа и исходным state (RFC 6749, 4.1.2).
+func redirectWith(c *gin.Context, req usecase.AuthorizationRequest, params map[string]string) {
+	target, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		renderError(c, http.StatusBadRequest, "Недопустимый redirect_uri")
+		return
+	}
+	query := target.Query()
+	for name, value := range params {
+		query.Set(name, value)
+	}
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	target.RawQuery = query.Encode()
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusSeeOther, target.String())
+}
+
+// authorizationError сообщает об ошибке запроса авторизации. Пока клиент
+// и redirect_uri не подтверждены, ошибка показывается пользователю, а не
+// передается редиректом (RFC 6749, 4.1.2.1).
+func authorizationError(c *gin.Context, req usecase.AuthorizationRequest, err error) {
+	switch {
+	case errors.Is(err, models.ErrorInvalidClient):
+		renderError(c, http.StatusBadRequest, "Неизвестный клиент")
+	case errors.Is(err, models.ErrorInvalidRedirectURI):
+		renderError(c, http.StatusBadRequest, "Недопустимый redirect_uri")
+	case errors.Is(err, models.ErrorUnsupportedResponseType):
+		redirectWith(c, req, map[string]string{"error": "unsupported_response_type"})
+	case errors.Is(err, models.ErrorUnauthorizedClient):
+		redirectWith(c, req, map[string]string{"error": "unauthorized_client"})
+	case errors.Is(err, models.ErrorInvalidScope):
+		redirectWith(c, req, map[string]string{"error": "invalid_scope"})
+	case errors.Is(err, models.ErrorPKCERequired):
+		redirectWith(c, req, map[string]string{
+			"error":             "invalid_request",
+			"error_description": "Требуется code_challenge с методом S256",
+		})
+	default:
+		logger.Logger.Error("Ошибка авторизации клиента",
+			zap.String("client_id", req.ClientID),
+			zap.Error(err))
+		redirectWith(c, req, map[string]string{"error": "server_error"})
+	}
+}
+
+func renderLogin(c *gin.Context, status int, req usecase.AuthorizationRequest, message string) {
+	renderPage(c, status, loginPage, loginView{
+		Action:   "/oauth2/authorize",
+		ClientID: req.ClientID,
+		Hidden:   authorizationParams(req),
+		Error:    message,
+	})
+}
+
+func (h *OAuthHandler) issueCode(c *gin.Context, req usecase.AuthorizationRequest, session models.Session) {
+	code, err := h.oauth.IssueAuthorizationCode(req, session)
+	if err != nil {
+		authorizationError(c, req, err)
+		return
+	}
+	redirectWith(c, req, map[string]string{"code": code})
+}
+
+// renderConsent просит пользователя разрешить стороннему клиенту доступ.
+// Без scope запрашиваются все области, разрешенные клиенту.
+func renderConsent(c *gin.Context, req usecase.AuthorizationRequest, client models.Client) {
+	name := client.Name
+	if name == "" {
+		name = client.ID
+	}
+	scopes := strings.Fields(req.Scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	renderPage(c, http.StatusOK, consentPage, consentView{
+		ClientName: name,
+		Scopes:     scopes,
+		Hidden:     authorizationParams(req),
+	})
+}
+
+// grantAccess выдает код собственному приложению сразу, а стороннему
+// клиенту только после согласия пользователя.
+func (h *OAuthHandler) grantAccess(c *gin.Context, req usecase.AuthorizationRequest, client models.Client, session models.Session) {
+	if client.FirstParty {
+		h.issueCode(c, req, session)
+		return
+	}
+	renderConsent(c, req, client)
+}
+
+// @Summary Запрос авторизации
+// @Description Начинает grant authorization_code (RFC 6749, 4.1) с обязательным PKCE S256. Без сессии показывает страницу входа, для стороннего клиента - экран согласия
+// @Tags oauth2
+// @Produce html
+// @Param response_type query string true "code"
+// @Param client_id query string true "Идентификатор клиента"
+// @Param redirect_uri query string true "Зарегистрированный redirect URI"
+// @Param scope query string false "Области доступа через пробел"
+// @Param state query string false "Состояние клиента"
+// @Param code_challenge query string true "PKCE code_challenge"
+// @Param code_challenge_method query string true "S256"
+// @Param nonce query string false "Nonce для ID токена"
+// @Success 200
+// @Success 303
+// @Failure 400
+// @Router /oauth2/authorize [get]
+func (h *OAuthHandler) Authorize(c *gin.Context) {
+	req := authorizationRequest(c)
+	client, err := h.oauth.ValidateAuthorizationRequest(req)
+	if err != nil {
+		authorizationError(c, req, err)
+		return
+	}
+
+	session, ok := h.currentSession(c)
+	if !ok {
+		renderLogin(c, http.StatusOK, req, "")
+		return
+	}
+	h.grantAccess(c, req, client, session)
+}
+
+// @Summary Решение на странице авторизации
+// @Description Принимает форму входа или решение пользователя (action=approve или deny) по запросу стороннего клиента. Отказ возвращает клиенту error=access_denied
+// @Tags oauth2
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param action formData string false "approve или deny"
+// @Param email formData string false "Email для входа"
+// @Param password formData string false "Пароль для входа"
+// @Success 200
+// @Success 303
+// @Failure 400
+// @Failure 401
+// @Router /oauth2/authorize [post]
+func (h *OAuthHandler) AuthorizeDecision(c *gin.Context) {
+	req := authorizationRequest(c)
+	client, err := h.oauth.ValidateAuthorizationRequest(req)
+	if err != nil {
+		authorizationError(c, req, err)
+		return
+	}
+
+	if c.PostForm("email") != "" {
+		h.authorizeLogin(c, req, client)
+		return
+	}
+
+	session, ok := h.currentSession(c)
+	if !ok {
+		renderLogin(c, http.StatusUnauthorized, req, "Сессия истекла, войдите снова")
+		return
+	}
+
+	switch c.PostForm("action") {
+	case "approve":
+		h.issueCode(c, req, session)
+	case "deny":
+		redirectWith(c, req, map[string]string{"error": "access_denied"})
+	default:
+		h.grantAccess(c, req, client, session)
+	}
+}
+
+// authorizeLogin открывает браузерную сессию и продолжает запрос авторизации.
+func (h *OAuthHandler) authorizeLogin(c *gin.Context, req usecase.AuthorizationRequest, client models.Client) {
+	session, err := h.startSession(c)
+	if err != nil {
+		if errors.Is(err, models.ErrorWrongPassword) {
+			renderLogin(c, http.StatusUnauthorized, req, "Неверный email или пароль")
+			return
+		}
+		if errors.Is(err, models.ErrorUserSuspended) {
+			renderLogin(c, http.StatusForbidden, req, "Пользователь заблокирован")
+			return
+		}
+		logger.Logger.Error("Ошибка входа на странице авторизации", zap.Error(err))
+		renderError(c, http.StatusInternalServerError, "Не удалось выполнить вход")
+		return
+	}
+	h.grantAccess(c, req, client, session)
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type ClientHandler struct {
+	clients usecase.Clients
+}
+
+func NewClientHandler(clients usecase.Clients) *ClientHandler {
+	return &ClientHandler{clients: clients}
+}
+
+// ClientRequest - настройки клиента. Сроки жизни токенов задаются в секундах,
+// 0 означает срок по умолчанию. ExchangeAudiences - аудитории, для которых
+// клиенту разрешен обмен токенов, ExchangeSubjectAudiences - аудитории
+// токенов входа по паролю, которые он может обменивать. FirstParty
+// отключает экран согласия.
+type ClientRequest struct {
+	ClientID                 string   `json:"client_id"`
+	Name                     string   `json:"name" binding:"required"`
+	Public                   bool     `json:"public"`
+	FirstParty               bool     `json:"first_party"`
+	GrantTypes               []string `json:"grant_types"`
+	RedirectURIs             []string `json:"redirect_uris"`
+	Scopes                   []string `json:"scopes"`
+	ExchangeAudiences        []string `json:"exchange_audiences"`
+	ExchangeSubjectAudiences []string `json:"exchange_subject_audiences"`
+	AccessTokenTTL           int64    `json:"access_token_ttl"`
+	RefreshTokenTTL          int64    `json:"refresh_token_ttl"`
+	RememberMeTTL            int64    `json:"remember_me_ttl"`
+}
+
+func (r ClientRequest) client() models.Client {
+	return models.Client{
+		ID:                       r.ClientID,
+		Name:                     r.Name,
+		Public:                   r.Public,
+		FirstParty:               r.FirstParty,
+		GrantTypes:               r.GrantTypes,
+		RedirectURIs:             r.RedirectURIs,
+		Scopes:                   r.Scopes,
+		ExchangeAudiences:        r.ExchangeAudiences,
+		ExchangeSubjectAudiences: r.ExchangeSubjectAudiences,
+		AccessTokenTTL:           r.AccessTokenTTL,
+		RefreshTokenTTL:          r.RefreshTokenTTL,
+		RememberMeTTL:            r.RememberMeTTL,
+	}
+}
+
+// ClientSecretResponse - клиент вместе с секретом. Секрет показывается
+// один раз: в базе хранится только его хеш.
+type ClientSecretResponse struct {
+	models.Client
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+func clientError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrorClientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrorClientExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrorInvalidClientMetadata):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// @Summary Получить всех клиентов
+// @Description Список зарегистрированных OAuth клиентов
+// @Tags clients
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} models.Client
+// @Failure 401 {object} object
+// @Failure 403 {object} object
+// @Router /admin/clients [get]
+func (h *ClientHandler) GetAll(c *gin.Context) {
+	clients, err := h.clients.GetAllClients()
+	if err != nil {
+		clientError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, clients)
+}
+
+// @Summary Найти клиента по client_id
+// @Tags clients
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "client_id"
+// @Success 200 {object} models.Client
+// @Failure 404 {object} object
+// @Router /admin/clients/{id} [get]
+func (h *ClientHandler) GetByID(c *gin.Context) {
+	client, err := h.clients.GetClient(c.Param("id"))
+	if err != nil {
+		clientError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, client)
+}
+
+// @Summary Зарегистрировать клиента
+// @Description Создает клиента. Конфиденциальному клиенту возвращается client_secret, который больше нельзя получить
+// @Tags clients
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param client body ClientRequest true "Настройки клиента"
+// @Success 201 {object} ClientSecretResponse
+// @Failure 400 {object} object
+// @Failure 409 {object} object
+// @Router /admin/clients [post]
+func (h *ClientHandler) Create(c *gin.Context) {
+	var req ClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, secret, err := h.clients.CreateClient(req.client())
+	if err != nil {
+		clientError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, ClientSecretResponse{Client: client, ClientSecret: secret})
+}
+
+// @Summary Изменить клиента
+// @Description Меняет настройки клиента, кроме client_id и секрета
+// @Tags clients
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "client_id"
+// @Param client body ClientRequest true "Настройки клиента"
+// @Success 200 {object} models.Client
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Router /admin/clients/{id} [put]
+func (h *ClientHandler) Update(c *gin.Context) {
+	var req ClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client := req.client()
+	client.ID = c.Param("id")
+	updated, err := h.clients.UpdateClient(client)
+	if err != nil {
+		clientError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// @Summary Удалить клиента
+// @Tags clients
+// @Security ApiKeyAuth
+// @Param id path string true "client_id"
+// @Success 204
+// @Failure 404 {object} object
+// @Router /admin/clients/{id} [delete]
+func (h *ClientHandler) Delete(c *gin.Context) {
+	if err := h.clients.DeleteClient(c.Param("id")); err != nil {
+		clientError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Сменить секрет клиента
+// @Description Выдает новый client_secret, старый перестает действовать сразу
+// @Tags clients
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "client_id"
+// @Success 200 {object} object{client_secret=string}
+// @Failure 400 {object} object
+// @Failure 404 {object} object
+// @Router /admin/clients/{id}/secret [post]
+func (h *ClientHandler) RotateSecret(c *gin.Context) {
+	secret, err := h.clients.RotateClientSecret(c.Param("id"))
+	if err != nil {
+		clientError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"client_secret": secret})
+}
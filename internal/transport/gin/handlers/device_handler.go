@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/internal/usecase"
+	"github.com/fire9900/auth/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DeviceAuthorizationResponse - ответ эндпоинта авторизации устройства (RFC 8628, 3.2).
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// @Summary Авторизация устройства
+// @Description Начинает grant device_code (RFC 8628): выдает device_code для опроса /oauth2/token и user_code, который пользователь вводит на странице /device. Публичные клиенты передают только client_id
+// @Tags oauth2
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param scope formData string false "Области доступа через пробел"
+// @Success 200 {object} DeviceAuthorizationResponse
+// @Failure 400 {object} object
+// @Failure 401 {object} object
+// @Router /oauth2/device_authorization [post]
+func (h *OAuthHandler) DeviceAuthorization(c *gin.Context) {
+	clientID, ok := h.authenticateClient(c, true)
+	if !ok {
+		return
+	}
+
+	device, err := h.oauth.AuthorizeDevice(clientID, c.PostForm("scope"))
+	if err != nil {
+		tokenError(c, clientID, err)
+		return
+	}
+
+	verificationURI := h.issuer + "/device"
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, DeviceAuthorizationResponse{
+		DeviceCode:              device.DeviceCode,
+		UserCode:                device.UserCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + url.QueryEscape(device.UserCode),
+		ExpiresIn:               device.ExpiresIn,
+		Interval:                device.Interval,
+	})
+}
+
+func renderDeviceLogin(c *gin.Context, status int, userCode, message string) {
+	hidden := map[string]string{}
+	if userCode != "" {
+		hidden["user_code"] = userCode
+	}
+	renderPage(c, status, loginPage, loginView{
+		Action: "/device",
+		Hidden: hidden,
+		Error:  message,
+	})
+}
+
+// showDevice показывает пользователю запрос устройства на подтверждение
+// или форму ввода кода, если код не передан или недействителен.
+func (h *OAuthHandler) showDevice(c *gin.Context, userCode string) {
+	if userCode == "" {
+		renderPage(c, http.StatusOK, deviceCodePage, "")
+		return
+	}
+
+	device, client, err := h.oauth.PendingDevice(userCode)
+	if err != nil {
+		if errors.Is(err, models.ErrorUserCodeInvalid) {
+			renderPage(c, http.StatusBadRequest, deviceCodePage, "Код недействителен или истек")
+			return
+		}
+		logger.Logger.Error("Ошибка поиска запроса устройства", zap.Error(err))
+		renderError(c, http.StatusInternalServerError, "Не удалось проверить код")
+		return
+	}
+
+	name := client.Name
+	if name == "" {
+		name = client.ID
+	}
+	renderPage(c, http.StatusOK, deviceConfirmPage, deviceConfirmView{
+		UserCode:   usecase.FormatUserCode(usecase.NormalizeUserCode(userCode)),
+		ClientName: name,
+		Scopes:     strings.Fields(device.Scope),
+	})
+}
+
+// @Summary Страница подключения устройства
+// @Description Показывает форму ввода user_code и запрос на подтверждение. Без сессии показывает страницу входа
+// @Tags oauth2
+// @Produce html
+// @Param user_code query string false "Код с экрана устройства"
+// @Success 200
+// @Failure 400
+// @Router /device [get]
+func (h *OAuthHandler) Device(c *gin.Context) {
+	userCode := c.Query("user_code")
+	if _, ok := h.currentSession(c); !ok {
+		renderDeviceLogin(c, http.StatusOK, userCode, "")
+		return
+	}
+	h.showDevice(c, userCode)
+}
+
+// @Summary Подтверждение устройства
+// @Description Принимает форму входа или решение пользователя (action=approve или deny) по запросу устройства
+// @Tags oauth2
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param user_code formData string true "Код с экрана устройства"
+// @Param action formData string false "approve или deny"
+// @Param email formData string false "Email для входа"
+// @Param password formData string false "Пароль для входа"
+// @Success 200
+// @Success 303
+// @Failure 400
+// @Failure 401
+// @Router /device [post]
+func (h *OAuthHandler) DeviceDecision(c *gin.Context) {
+	userCode := c.PostForm("user_code")
+
+	if c.PostForm("email") != "" {
+		h.deviceLogin(c, userCode)
+		return
+	}
+
+	session, ok := h.currentSession(c)
+	if !ok {
+		renderDeviceLogin(c, http.StatusUnauthorized, userCode, "Сессия истекла, войдите снова")
+		return
+	}
+
+	action := c.PostForm("action")
+	if action != "approve" && action != "deny" {
+		h.showDevice(c, userCode)
+		return
+	}
+
+	approve := action == "approve"
+	if err := h.oauth.DecideDevice(userCode, session, approve); err != nil {
+		if errors.Is(err, models.ErrorUserCodeInvalid) {
+			renderPage(c, http.StatusBadRequest, deviceCodePage, "Код недействителен или истек")
+			return
+		}
+		logger.Logger.Error("Ошибка подтверждения устройства",
+			zap.Int("user_id", session.UserID),
+			zap.Error(err))
+		renderError(c, http.StatusInternalServerError, "Не удалось сохранить решение")
+		return
+	}
+	renderPage(c, http.StatusOK, deviceDonePage, approve)
+}
+
+// deviceLogin открывает браузерную сессию и возвращает пользователя
+// на страницу подтверждения с тем же кодом.
+func (h *OAuthHandler) deviceLogin(c *gin.Context, userCode string) {
+	if _, err := h.startSession(c); err != nil {
+		if errors.Is(err, models.ErrorWrongPassword) {
+			renderDeviceLogin(c, http.StatusUnauthorized, userCode, "Неверный email или пароль")
+			return
+		}
+		if errors.Is(err, models.ErrorUserSuspended) {
+			renderDeviceLogin(c, http.StatusForbidden, userCode, "Пользователь заблокирован")
+			return
+		}
+		logger.Logger.Error("Ошибка входа на странице устройства", zap.Error(err))
+		renderError(c, http.StatusInternalServerError, "Не удалось выполнить вход")
+		return
+	}
+
+	target := "/device"
+	if userCode != "" {
+		target += "?user_code=" + url.QueryEscape(userCode)
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusSeeOther, target)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuthHandler_DeviceAuthorization(t *testing.T) {
+	router := newOAuthTestRouter(&fakeOAuth{})
+
+	t.Run("Public client", func(t *testing.T) {
+		w := postForm(router, "/oauth2/device_authorization", url.Values{"client_id": {"spa"}}, "", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+		var resp DeviceAuthorizationResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "device", resp.DeviceCode)
+		assert.Equal(t, "BCDF-GHJK", resp.UserCode)
+		assert.Equal(t, "https://auth.test/device", resp.VerificationURI)
+		assert.Equal(t, "https://auth.test/device?user_code=BCDF-GHJK", resp.VerificationURIComplete)
+		assert.Equal(t, int64(600), resp.ExpiresIn)
+		assert.Equal(t, 5, resp.Interval)
+	})
+
+	t.Run("Unknown client", func(t *testing.T) {
+		w := postForm(router, "/oauth2/device_authorization", url.Values{"client_id": {"other"}}, "", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_client")
+	})
+
+	t.Run("Foreign scope", func(t *testing.T) {
+		w := postForm(router, "/oauth2/device_authorization", url.Values{"client_id": {"spa"}, "scope": {"admin"}}, "", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_scope")
+	})
+}
+
+func deviceRequest(router http.Handler, method, target string, form url.Values, session bool) *httptest.ResponseRecorder {
+	var req *http.Request
+	if form != nil {
+		req = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req = httptest.NewRequest(method, target, nil)
+	}
+	if session {
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: "valid"})
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestOAuthHandler_Device(t *testing.T) {
+	oauth := &fakeOAuth{}
+	router := newOAuthTestRouter(oauth)
+
+	t.Run("Without session shows login", func(t *testing.T) {
+		w := deviceRequest(router, http.MethodGet, "/device?user_code=BCDF-GHJK", nil, false)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `action="/device"`)
+		assert.Contains(t, w.Body.String(), `name="password"`)
+		assert.Contains(t, w.Body.String(), `value="BCDF-GHJK"`)
+	})
+
+	t.Run("Without code shows code form", func(t *testing.T) {
+		w := deviceRequest(router, http.MethodGet, "/device", nil, true)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `name="user_code"`)
+	})
+
+	t.Run("Invalid code", func(t *testing.T) {
+		w := deviceRequest(router, http.MethodGet, "/device?user_code=XXXX", nil, true)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Код недействителен")
+	})
+
+	t.Run("Code typed in lowercase shows confirmation", func(t *testing.T) {
+		w := deviceRequest(router, http.MethodGet, "/device?user_code=bcdfghjk", nil, true)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Телевизор")
+		assert.Contains(t, w.Body.String(), "BCDF-GHJK")
+		assert.Contains(t, w.Body.String(), "<li>profile</li>")
+		assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	})
+
+	t.Run("Decision without session", func(t *testing.T) {
+		form := url.Values{"user_code": {"BCDF-GHJK"}, "action": {"approve"}}
+		w := deviceRequest(router, http.MethodPost, "/device", form, false)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Empty(t, oauth.decisions)
+	})
+
+	t.Run("Approve", func(t *testing.T) {
+		form := url.Values{"user_code": {"BCDF-GHJK"}, "action": {"approve"}}
+		w := deviceRequest(router, http.MethodPost, "/device", form, true)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Доступ разрешен")
+		assert.Equal(t, map[string]bool{"BCDFGHJK": true}, oauth.decisions)
+	})
+
+	t.Run("Deny", func(t *testing.T) {
+		form := url.Values{"user_code": {"BCDF-GHJK"}, "action": {"deny"}}
+		w := deviceRequest(router, http.MethodPost, "/device", form, true)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Доступ отклонен")
+		assert.Equal(t, map[string]bool{"BCDFGHJK": false}, oauth.decisions)
+	})
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type JWKSHandler struct {
+	verifier *auth.Verifier
+}
+
+func NewJWKSHandler(verifier *auth.Verifier) *JWKSHandler {
+	return &JWKSHandler{verifier: verifier}
+}
+
+// @Summary Открытые ключи подписи
+// @Description Возвращает JWKS для офлайн-проверки токенов
+// @Tags keys
+// @Produce json
+// @Success 200 {object} auth.JWKS
+// @Router /.well-known/jwks.json [get]
+func (h *JWKSHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.verifier.JWKS())
+}
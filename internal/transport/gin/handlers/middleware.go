@@ -10,7 +10,16 @@ import (
 	"strings"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+type AccessTokenValidator interface {
+	ValidateAccessToken(accessToken string) (*auth.Claims, error)
+}
+
+// AuthMiddleware проверяет access токен из заголовка Authorization. Токен,
+// привязанный к ключу клиента, принимается только по схеме DPoP вместе
+// с доказательством (RFC 9449, 7.1), которое проверяет proofs. URL запроса
+// для доказательства строится от origin - внешнего адреса сервиса.
+func AuthMiddleware(validator AccessTokenValidator, proofs *auth.DPoPVerifier, origin string) gin.HandlerFunc {
+	origin = strings.TrimSuffix(origin, "/")
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -19,16 +28,72 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		claims, err := auth.ValidateToken(tokenString)
+		scheme, tokenString, found := strings.Cut(authHeader, " ")
+		if !found {
+			scheme, tokenString = "Bearer", authHeader
+		}
+		claims, err := validator.ValidateAccessToken(tokenString)
 		if err != nil {
 			logger.Logger.Error(fmt.Sprintf("Невалидный токен %s", tokenString),
 				zap.Error(err))
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Невалидный токен"})
 			return
 		}
+
+		err = proofs.VerifyBinding(claims, tokenString, auth.DPoPRequest{
+			Scheme: scheme,
+			Proof:  c.GetHeader("DPoP"),
+			Method: c.Request.Method,
+			URL:    origin + c.Request.URL.Path,
+		})
+		if err != nil {
+			logger.Logger.Warn("Токен предъявлен без действительного DPoP доказательства",
+				zap.String("jti", claims.ID),
+				zap.Error(err))
+			c.Header("WWW-Authenticate", `DPoP error="invalid_dpop_proof"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Невалидный токен"})
+			return
+		}
 		logger.Logger.Info("Успешная проверка авторизации пользователя")
-		c.Set("userID", claims.UserID)
+		// У токена клиента (client_credentials) нет пользователя,
+		// поэтому userID для него не устанавливается.
+		if claims.IsClient() {
+			c.Set("clientID", claims.ClientID)
+		} else {
+			c.Set("userID", claims.UserID)
+		}
+		c.Set("claims", claims)
+		c.Next()
+	}
+}
+
+// RequireScope пропускает запрос, только если access токен, проверенный
+// AuthMiddleware, содержит область доступа scope.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := c.MustGet("claims").(*auth.Claims)
+		if !ok || !claims.HasScope(scope) {
+			logger.Logger.Warn("Недостаточно прав",
+				zap.String("scope", scope))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireUser пропускает запрос только с токеном пользователя. У токена
+// клиента (client_credentials) нет пользователя, от имени которого
+// можно менять пароль, удалять учетную запись или управлять сессиями.
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := c.MustGet("claims").(*auth.Claims)
+		if !ok || claims.IsClient() {
+			logger.Logger.Warn("Токен клиента на маршруте пользователя",
+				zap.String("path", c.FullPath()))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав"})
+			return
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/fire9900/auth/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var testTokenConfig = auth.TokenConfig{Issuer: "https://auth.test", Audience: "api"}
+
+// dpopKey - ключ клиента DPoP и его отпечаток по RFC 7638.
+type dpopKey struct {
+	private *ecdsa.PrivateKey
+	jwk     auth.JWK
+	jkt     string
+}
+
+func newDPoPKey(t *testing.T) dpopKey {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwk := auth.JWK{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(private.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(private.Y.FillBytes(make([]byte, 32))),
+	}
+	sum := sha256.Sum256(fmt.Appendf(nil, `{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`, jwk.X, jwk.Y))
+	return dpopKey{private: private, jwk: jwk, jkt: base64.RawURLEncoding.EncodeToString(sum[:])}
+}
+
+// proof подписывает DPoP доказательство запроса method к url. Непустой
+// accessToken добавляет в доказательство его хеш (ath).
+func (k dpopKey) proof(t *testing.T, method, url, accessToken string) string {
+	claims := jwt.MapClaims{"htm": method, "htu": url, "iat": time.Now().Unix(), "jti": rand.Text()}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = k.jwk
+	proof, err := token.SignedString(k.private)
+	require.NoError(t, err)
+	return proof
+}
+
+func TestAuthMiddleware_TokenTypes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Logger = zap.NewNop()
+
+	for _, format := range []string{auth.TokenFormatJWT, auth.TokenFormatPasetoLocal} {
+		t.Run(format, func(t *testing.T) {
+			ring, err := auth.LoadKeyRing(auth.KeyConfig{Secret: "0123456789abcdef0123456789abcdef"})
+			require.NoError(t, err)
+			signer, verifier := auth.NewSigner(ring, testTokenConfig), auth.NewVerifier(ring, testTokenConfig)
+			if format == auth.TokenFormatPasetoLocal {
+				codec, err := auth.NewPasetoCodec(format, ring)
+				require.NoError(t, err)
+				signer.UseCodec(codec)
+				verifier.UseCodec(codec)
+			}
+
+			accessToken, _, err := signer.GenerateAccessToken(auth.TokenParams{UserID: 3})
+			require.NoError(t, err)
+			refreshToken, _, err := signer.GenerateRefreshToken(auth.TokenParams{UserID: 3})
+			require.NoError(t, err)
+
+			router := gin.New()
+			router.GET("/me", AuthMiddleware(verifier, nil, "https://auth.test"), func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"user_id": c.GetInt("userID")})
+			})
+
+			tests := []struct {
+				name       string
+				token      string
+				wantStatus int
+			}{
+				{name: "Access token", token: accessToken, wantStatus: http.StatusOK},
+				{name: "Refresh token", token: refreshToken, wantStatus: http.StatusUnauthorized},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					req := httptest.NewRequest(http.MethodGet, "/me", nil)
+					req.Header.Set("Authorization", "Bearer "+tt.token)
+					w := httptest.NewRecorder()
+					router.ServeHTTP(w, req)
+					assert.Equal(t, tt.wantStatus, w.Code)
+				})
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_DPoP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Logger = zap.NewNop()
+
+	ring, err := auth.LoadKeyRing(auth.KeyConfig{Secret: "0123456789abcdef0123456789abcdef"})
+	require.NoError(t, err)
+	signer := auth.NewSigner(ring, testTokenConfig)
+	key, otherKey := newDPoPKey(t), newDPoPKey(t)
+
+	bound, _, err := signer.GenerateAccessToken(auth.TokenParams{UserID: 3, JKT: key.jkt})
+	require.NoError(t, err)
+	bearer, _, err := signer.GenerateAccessToken(auth.TokenParams{UserID: 3})
+	require.NoError(t, err)
+
+	proofs := auth.NewDPoPVerifier(auth.NewMemoryReplayCache(), time.Minute)
+	router := gin.New()
+	router.GET("/api/v1/me", AuthMiddleware(auth.NewVerifier(ring, testTokenConfig), proofs, "https://auth.test/"), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetInt("userID")})
+	})
+	const resource = "https://auth.test/api/v1/me"
+	replayed := key.proof(t, http.MethodGet, resource, bound)
+
+	tests := []struct {
+		name       string
+		header     string
+		proof      string
+		wantStatus int
+	}{
+		{name: "Bound token with proof", header: "DPoP " + bound, proof: replayed, wantStatus: http.StatusOK},
+		{name: "Replayed proof", header: "DPoP " + bound, proof: replayed, wantStatus: http.StatusUnauthorized},
+		{name: "Bound token as bearer", header: "Bearer " + bound, proof: key.proof(t, http.MethodGet, resource, bound), wantStatus: http.StatusUnauthorized},
+		{name: "Bound token without proof", header: "DPoP " + bound, wantStatus: http.StatusUnauthorized},
+		{name: "Proof by foreign key", header: "DPoP " + bound, proof: otherKey.proof(t, http.MethodGet, resource, bound), wantStatus: http.StatusUnauthorized},
+		{name: "Proof for another method", header: "DPoP " + bound, proof: key.proof(t, http.MethodPost, resource, bound), wantStatus: http.StatusUnauthorized},
+		{name: "Proof for another token", header: "DPoP " + bound, proof: key.proof(t, http.MethodGet, resource, bearer), wantStatus: http.StatusUnauthorized},
+		{name: "Bearer token", header: "Bearer " + bearer, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/me?x=1", nil)
+			req.Header.Set("Authorization", tt.header)
+			if tt.proof != "" {
+				req.Header.Set("DPoP", tt.proof)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusUnauthorized {
+				assert.Contains(t, w.Header().Get("WWW-Authenticate"), "invalid_dpop_proof")
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Logger = zap.NewNop()
+
+	ring, err := auth.LoadKeyRing(auth.KeyConfig{Secret: "0123456789abcdef0123456789abcdef"})
+	require.NoError(t, err)
+	signer := auth.NewSigner(ring, testTokenConfig)
+
+	admin, _, err := signer.GenerateAccessToken(auth.TokenParams{UserID: 1, Scopes: []string{"clients:read"}})
+	require.NoError(t, err)
+	user, _, err := signer.GenerateAccessToken(auth.TokenParams{UserID: 2, Scopes: []string{"profile"}})
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.GET("/admin/clients", AuthMiddleware(auth.NewVerifier(ring, testTokenConfig), nil, "https://auth.test"), RequireScope("clients:read"),
+		func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{name: "Token with scope", token: admin, wantStatus: http.StatusOK},
+		{name: "Token without scope", token: user, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/clients", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestRequireUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Logger = zap.NewNop()
+
+	ring, err := auth.LoadKeyRing(auth.KeyConfig{Secret: "0123456789abcdef0123456789abcdef"})
+	require.NoError(t, err)
+	signer := auth.NewSigner(ring, testTokenConfig)
+
+	user, _, err := signer.GenerateAccessToken(auth.TokenParams{UserID: 1})
+	require.NoError(t, err)
+	client, _, err := signer.GenerateAccessToken(auth.TokenParams{ClientID: "billing", Scopes: []string{"users:write"}})
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.GET("/logout/all", AuthMiddleware(auth.NewVerifier(ring, testTokenConfig), nil, "https://auth.test"), RequireUser(),
+		func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{name: "User token", token: user, wantStatus: http.StatusOK},
+		{name: "Client token", token: client, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/logout/all", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/internal/usecase"
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/fire9900/auth/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OAuthHandler обслуживает /oauth2/* и /device. sessions открывает и находит
+// браузерные сессии страниц входа, secureCookies выставляет их cookie флаг
+// Secure. proofs проверяет DPoP доказательства на эндпоинте токенов. issuer -
+// внешний адрес сервиса, от которого строятся verification_uri и htu.
+type OAuthHandler struct {
+	oauth         usecase.OAuth
+	sessions      BrowserSessions
+	secureCookies bool
+	proofs        *auth.DPoPVerifier
+	issuer        string
+}
+
+func NewOAuthHandler(oauth usecase.OAuth, sessions BrowserSessions, secureCookies bool,
+	proofs *auth.DPoPVerifier, issuer string) *OAuthHandler {
+	return &OAuthHandler{oauth: oauth, sessions: sessions, secureCookies: secureCookies, proofs: proofs,
+		issuer: strings.TrimSuffix(issuer, "/")}
+}
+
+// oauthError - ответ об ошибке в формате RFC 6749, раздел 5.2.
+func oauthError(c *gin.Context, status int, code, description string) {
+	c.Header("Cache-Control", "no-store")
+	c.AbortWithStatusJSON(status, gin.H{"error": code, "error_description": description})
+}
+
+// authenticateClient проверяет клиента по client_secret_basic (заголовок
+// Authorization) или client_secret_post (поля формы). Использовать оба способа
+// в одном запросе запрещено RFC 6749, раздел 2.3. При allowPublic публичный
+// клиент может передать только client_id.
+func (h *OAuthHandler) authenticateClient(c *gin.Context, allowPublic bool) (string, bool) {
+	basicID, basicSecret, hasBasic := c.Request.BasicAuth()
+	postID, postSecret := c.PostForm("client_id"), c.PostForm("client_secret")
+
+	var clientID, clientSecret string
+	switch {
+	case hasBasic && postSecret != "":
+		oauthError(c, http.StatusBadRequest, "invalid_request", "Использовано несколько способов аутентификации клиента")
+		return "", false
+	case hasBasic:
+		clientID, clientSecret = basicID, basicSecret
+	default:
+		clientID, clientSecret = postID, postSecret
+	}
+
+	var err error
+	if allowPublic && !hasBasic && clientSecret == "" {
+		err = h.oauth.AuthenticatePublicClient(clientID)
+	} else {
+		err = h.oauth.AuthenticateClient(clientID, clientSecret)
+	}
+	if err != nil {
+		logger.Logger.Warn("Ошибка аутентификации клиента",
+			zap.String("client_id", clientID),
+			zap.Error(err))
+		if hasBasic {
+			c.Header("WWW-Authenticate", `Basic realm="oauth2"`)
+		}
+		oauthError(c, http.StatusUnauthorized, "invalid_client", "Неверные учетные данные клиента")
+		return "", false
+	}
+	return clientID, true
+}
+
+type IntrospectionResponse struct {
+	Active    bool               `json:"active"`
+	Scope     string             `json:"scope,omitempty"`
+	ClientID  string             `json:"client_id,omitempty"`
+	TokenType string             `json:"token_type,omitempty"`
+	Exp       int64              `json:"exp,omitempty"`
+	Iat       int64              `json:"iat,omitempty"`
+	Nbf       int64              `json:"nbf,omitempty"`
+	Sub       string             `json:"sub,omitempty"`
+	Aud       []string           `json:"aud,omitempty"`
+	Iss       string             `json:"iss,omitempty"`
+	Jti       string             `json:"jti,omitempty"`
+	Act       *auth.Actor        `json:"act,omitempty"`
+	Cnf       *auth.Confirmation `json:"cnf,omitempty"`
+}
+
+func newIntrospectionResponse(claims *auth.Claims) IntrospectionResponse {
+	tokenType := "refresh_token"
+	if claims.TokenType == auth.TokenTypeAccess {
+		tokenType = "Bearer"
+		if claims.Confirmation != nil {
+			tokenType = auth.SchemeDPoP
+		}
+	}
+	return IntrospectionResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		TokenType: tokenType,
+		Exp:       claims.ExpiresAt.Unix(),
+		Iat:       claims.IssuedAt.Unix(),
+		Nbf:       claims.NotBefore.Unix(),
+		Sub:       claims.Subject,
+		Aud:       claims.Audience,
+		Iss:       claims.Issuer,
+		Jti:       claims.ID,
+		Act:       claims.Actor,
+		Cnf:       claims.Confirmation,
+	}
+}
+
+// @Summary Интроспекция токена
+// @Description Возвращает состояние токена по RFC 7662. Клиент аутентифицируется через client_secret_basic или client_secret_post
+// @Tags oauth2
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "Токен"
+// @Param token_type_hint formData string false "access_token или refresh_token"
+// @Success 200 {object} IntrospectionResponse
+// @Failure 400 {object} object
+// @Failure 401 {object} object
+// @Router /oauth2/introspect [post]
+func (h *OAuthHandler) Introspect(c *gin.Context) {
+	if _, ok := h.authenticateClient(c, false); !ok {
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "Не передан token")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	claims, err := h.oauth.Introspect(token, c.PostForm("token_type_hint"))
+	if err != nil {
+		c.JSON(http.StatusOK, IntrospectionResponse{Active: false})
+		return
+	}
+	c.JSON(http.StatusOK, newIntrospectionResponse(claims))
+}
+
+// @Summary Отзыв токена
+// @Description Отзывает access или refresh токен клиента по RFC 7009. Для refresh токена отзывается вся его сессия. Повторный отзыв, отзыв неизвестного токена и токена другого клиента тоже завершаются успехом, но чужой токен не отзывается
+// @Tags oauth2
+// @Accept x-www-form-urlencoded
+// @Param token formData string true "Токен"
+// @Param token_type_hint formData string false "access_token или refresh_token"
+// @Success 200
+// @Failure 400 {object} object
+// @Failure 401 {object} object
+// @Router /oauth2/revoke [post]
+func (h *OAuthHandler) Revoke(c *gin.Context) {
+	clientID, ok := h.authenticateClient(c, false)
+	if !ok {
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "Не передан token")
+		return
+	}
+
+	if err := h.oauth.Revoke(token, c.PostForm("token_type_hint"), clientID); err != nil {
+		logger.Logger.Error("Ошибка отзыва токена",
+			zap.String("client_id", clientID),
+			zap.Error(err))
+		oauthError(c, http.StatusServiceUnavailable, "temporarily_unavailable", "Не удалось отозвать токен")
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// OAuthTokenResponse - успешный ответ эндпоинта токенов (RFC 6749, 5.1).
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	// IssuedTokenType возвращается только при обмене токенов (RFC 8693, 2.2.1).
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+}
+
+// dpopKey проверяет заголовок DPoP запроса к эндпоинту токенов и возвращает
+// отпечаток ключа, к которому привязываются токены. Без заголовка
+// выдаются обычные Bearer токены.
+func (h *OAuthHandler) dpopKey(c *gin.Context) (string, bool) {
+	proofs := c.Request.Header.Values("DPoP")
+	if len(proofs) == 0 {
+		return "", true
+	}
+	if len(proofs) > 1 || h.proofs == nil {
+		oauthError(c, http.StatusBadRequest, "invalid_dpop_proof", "Недействительное DPoP доказательство")
+		return "", false
+	}
+
+	jkt, err := h.proofs.VerifyProof(proofs[0], c.Request.Method, h.issuer+c.Request.URL.Path, "")
+	if err != nil {
+		logger.Logger.Warn("Недействительное DPoP доказательство",
+			zap.Error(err))
+		oauthError(c, http.StatusBadRequest, "invalid_dpop_proof", "Недействительное DPoP доказательство")
+		return "", false
+	}
+	return jkt, true
+}
+
+// tokenError сопоставляет ошибки выдачи токенов с кодами RFC 6749, 5.2.
+func tokenError(c *gin.Context, clientID string, err error) {
+	switch {
+	case errors.Is(err, models.ErrorInvalidGrant),
+		errors.Is(err, models.ErrorTokenClientMismatch),
+		errors.Is(err, models.ErrorRefreshTokenInvalid),
+		errors.Is(err, models.ErrorRefreshTokenNotFound),
+		errors.Is(err, models.ErrorRefreshTokenReused),
+		errors.Is(err, models.ErrorRefreshTokenRevoked),
+		errors.Is(err, models.ErrorUserSuspended):
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "Грант недействителен, истек или выдан другому клиенту")
+	case errors.Is(err, models.ErrorInvalidClient):
+		oauthError(c, http.StatusUnauthorized, "invalid_client", "Неверные учетные данные клиента")
+	case errors.Is(err, models.ErrorUnauthorizedClient):
+		oauthError(c, http.StatusBadRequest, "unauthorized_client", "Клиенту не разрешен этот grant_type")
+	case errors.Is(err, models.ErrorInvalidScope):
+		oauthError(c, http.StatusBadRequest, "invalid_scope", "Запрошена недопустимая область доступа")
+	case errors.Is(err, models.ErrorInvalidTarget):
+		oauthError(c, http.StatusBadRequest, "invalid_target", "Клиенту не разрешено получать токены для этой аудитории")
+	case errors.Is(err, models.ErrorInvalidRequest):
+		oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+	case errors.Is(err, models.ErrorUnsupportedGrantType):
+		oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "Неподдерживаемый grant_type")
+	case errors.Is(err, models.ErrorAuthorizationPending):
+		oauthError(c, http.StatusBadRequest, "authorization_pending", "Пользователь еще не подтвердил вход")
+	case errors.Is(err, models.ErrorSlowDown):
+		oauthError(c, http.StatusBadRequest, "slow_down", "Слишком частый опрос, интервал увеличен")
+	case errors.Is(err, models.ErrorAccessDenied):
+		oauthError(c, http.StatusBadRequest, "access_denied", "Пользователь отклонил вход")
+	case errors.Is(err, models.ErrorExpiredToken):
+		oauthError(c, http.StatusBadRequest, "expired_token", "Срок действия device_code истек")
+	default:
+		logger.Logger.Error("Ошибка выдачи токенов",
+			zap.String("client_id", clientID),
+			zap.Error(err))
+		oauthError(c, http.StatusInternalServerError, "server_error", "Не удалось выдать токены")
+	}
+}
+
+// @Summary Эндпоинт токенов
+// @Description Выдает токены по grant_type authorization_code (с code_verifier PKCE), refresh_token, client_credentials, urn:ietf:params:oauth:grant-type:device_code и urn:ietf:params:oauth:grant-type:token-exchange. Публичные клиенты передают только client_id
+// @Tags oauth2
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "authorization_code, refresh_token, client_credentials, urn:ietf:params:oauth:grant-type:device_code или urn:ietf:params:oauth:grant-type:token-exchange"
+// @Param code formData string false "Код авторизации"
+// @Param redirect_uri formData string false "redirect_uri запроса авторизации"
+// @Param code_verifier formData string false "PKCE code_verifier"
+// @Param refresh_token formData string false "Refresh токен"
+// @Param scope formData string false "Области доступа для client_credentials и обмена токенов"
+// @Param device_code formData string false "Код устройства"
+// @Param subject_token formData string false "Обмениваемый токен пользователя"
+// @Param subject_token_type formData string false "urn:ietf:params:oauth:token-type:access_token"
+// @Param actor_token formData string false "Токен актора для делегирования"
+// @Param actor_token_type formData string false "urn:ietf:params:oauth:token-type:access_token"
+// @Param audience formData string false "Аудитория нового токена"
+// @Param requested_token_type formData string false "urn:ietf:params:oauth:token-type:access_token"
+// @Param DPoP header string false "DPoP доказательство: токены привязываются к ключу клиента"
+// @Success 200 {object} OAuthTokenResponse
+// @Failure 400 {object} object
+// @Failure 401 {object} object
+// @Router /oauth2/token [post]
+func (h *OAuthHandler) Token(c *gin.Context) {
+	clientID, ok := h.authenticateClient(c, true)
+	if !ok {
+		return
+	}
+	jkt, ok := h.dpopKey(c)
+	if !ok {
+		return
+	}
+
+	var pair usecase.TokenPair
+	var err error
+	grantType := c.PostForm("grant_type")
+	switch grantType {
+	case "authorization_code":
+		if c.PostForm("code") == "" || c.PostForm("code_verifier") == "" {
+			oauthError(c, http.StatusBadRequest, "invalid_request", "Не переданы code или code_verifier")
+			return
+		}
+		pair, err = h.oauth.ExchangeAuthorizationCode(usecase.CodeExchange{
+			Code:         c.PostForm("code"),
+			RedirectURI:  c.PostForm("redirect_uri"),
+			ClientID:     clientID,
+			CodeVerifier: c.PostForm("code_verifier"),
+			JKT:          jkt,
+		})
+	case "refresh_token":
+		if c.PostForm("refresh_token") == "" {
+			oauthError(c, http.StatusBadRequest, "invalid_request", "Не передан refresh_token")
+			return
+		}
+		pair, err = h.oauth.RefreshToken(c.PostForm("refresh_token"), clientID, jkt)
+	case "client_credentials":
+		pair, err = h.oauth.ClientCredentials(clientID, c.PostForm("scope"), jkt)
+	case models.GrantDeviceCode:
+		if c.PostForm("device_code") == "" {
+			oauthError(c, http.StatusBadRequest, "invalid_request", "Не передан device_code")
+			return
+		}
+		pair, err = h.oauth.DeviceToken(c.PostForm("device_code"), clientID, jkt)
+	case models.GrantTokenExchange:
+		if c.PostForm("subject_token") == "" || c.PostForm("subject_token_type") == "" {
+			oauthError(c, http.StatusBadRequest, "invalid_request", "Не переданы subject_token или subject_token_type")
+			return
+		}
+		if len(c.PostFormArray("audience")) > 1 {
+			oauthError(c, http.StatusBadRequest, "invalid_target", "Поддерживается только одна аудитория")
+			return
+		}
+		pair, err = h.oauth.ExchangeToken(usecase.TokenExchangeRequest{
+			ClientID:           clientID,
+			SubjectToken:       c.PostForm("subject_token"),
+			SubjectTokenType:   c.PostForm("subject_token_type"),
+			ActorToken:         c.PostForm("actor_token"),
+			ActorTokenType:     c.PostForm("actor_token_type"),
+			Audience:           c.PostForm("audience"),
+			Scope:              c.PostForm("scope"),
+			RequestedTokenType: c.PostForm("requested_token_type"),
+			JKT:                jkt,
+		})
+	case "":
+		oauthError(c, http.StatusBadRequest, "invalid_request", "Не передан grant_type")
+		return
+	default:
+		err = models.ErrorUnsupportedGrantType
+	}
+	if err != nil {
+		tokenError(c, clientID, err)
+		return
+	}
+
+	resp := newOAuthTokenResponse(pair)
+	if grantType == models.GrantTokenExchange {
+		resp.IssuedTokenType = usecase.TokenTypeAccessToken
+	}
+	if jkt != "" {
+		resp.TokenType = auth.SchemeDPoP
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, resp)
+}
+
+// newOAuthTokenResponse переводит момент истечения access токена
+// в expires_in - срок жизни в секундах, как того требует RFC 6749.
+func newOAuthTokenResponse(pair usecase.TokenPair) OAuthTokenResponse {
+	return OAuthTokenResponse{
+		AccessToken:  pair.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    max(int64(time.Until(time.Unix(pair.ExpiresIn, 0)).Seconds()), 0),
+		RefreshToken: pair.RefreshToken,
+		Scope:        strings.TrimSpace(pair.Scope),
+		IDToken:      pair.IDToken,
+	}
+}
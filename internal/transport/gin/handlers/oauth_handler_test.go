@@ -0,0 +1,639 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/internal/usecase"
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/fire9900/auth/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeOAuth struct {
+	claims    map[string]*auth.Claims
+	revoked   []string
+	revokeErr error
+	decisions map[string]bool
+}
+
+func (f *fakeOAuth) AuthenticateClient(clientID, clientSecret string) error {
+	if clientID == "gateway" && clientSecret == "secret" {
+		return nil
+	}
+	return models.ErrorInvalidClient
+}
+
+func (f *fakeOAuth) Introspect(token, tokenTypeHint string) (*auth.Claims, error) {
+	if claims, ok := f.claims[token]; ok {
+		return claims, nil
+	}
+	return nil, auth.ErrorInvalidToken
+}
+
+func (f *fakeOAuth) Revoke(token, tokenTypeHint, clientID string) error {
+	f.revoked = append(f.revoked, clientID+":"+token)
+	return f.revokeErr
+}
+
+func (f *fakeOAuth) AuthenticatePublicClient(clientID string) error {
+	if clientID == "spa" {
+		return nil
+	}
+	return models.ErrorInvalidClient
+}
+
+// authorizeClients - клиенты страницы авторизации: собственное приложение
+// spa и стороннее приложение partner.
+var authorizeClients = map[string]models.Client{
+	"spa":     {ID: "spa", FirstParty: true},
+	"partner": {ID: "partner", Name: "Партнер", Scopes: []string{"openid", "profile"}},
+}
+
+func (f *fakeOAuth) ValidateAuthorizationRequest(req usecase.AuthorizationRequest) (models.Client, error) {
+	client, ok := authorizeClients[req.ClientID]
+	if !ok {
+		return models.Client{}, models.ErrorInvalidClient
+	}
+	if req.RedirectURI != "https://spa.test/cb" {
+		return models.Client{}, models.ErrorInvalidRedirectURI
+	}
+	if req.CodeChallengeMethod != auth.PKCEMethodS256 {
+		return models.Client{}, models.ErrorPKCERequired
+	}
+	return client, nil
+}
+
+func (f *fakeOAuth) IssueAuthorizationCode(req usecase.AuthorizationRequest, session models.Session) (string, error) {
+	return "code", nil
+}
+
+func (f *fakeOAuth) ExchangeAuthorizationCode(exchange usecase.CodeExchange) (usecase.TokenPair, error) {
+	if exchange.Code != "code" || exchange.ClientID != "spa" {
+		return usecase.TokenPair{}, models.ErrorInvalidGrant
+	}
+	return usecase.TokenPair{
+		AccessToken: "access",
+		IDToken:     "id",
+		Scope:       "openid profile",
+		ExpiresIn:   time.Now().Add(time.Minute).Unix(),
+	}, nil
+}
+
+func (f *fakeOAuth) ClientCredentials(clientID, scope, jkt string) (usecase.TokenPair, error) {
+	if scope == "admin" {
+		return usecase.TokenPair{}, models.ErrorInvalidScope
+	}
+	return usecase.TokenPair{
+		AccessToken: "service" + jkt,
+		Scope:       scope,
+		ExpiresIn:   time.Now().Add(time.Minute).Unix(),
+	}, nil
+}
+
+func (f *fakeOAuth) RefreshToken(refreshToken, clientID, jkt string) (usecase.TokenPair, error) {
+	return usecase.TokenPair{}, models.ErrorRefreshTokenReused
+}
+
+func (f *fakeOAuth) AuthorizeDevice(clientID, scope string) (usecase.DeviceAuthorization, error) {
+	if scope == "admin" {
+		return usecase.DeviceAuthorization{}, models.ErrorInvalidScope
+	}
+	return usecase.DeviceAuthorization{DeviceCode: "device", UserCode: "BCDF-GHJK", ExpiresIn: 600, Interval: 5}, nil
+}
+
+func (f *fakeOAuth) PendingDevice(userCode string) (models.DeviceCode, models.Client, error) {
+	if usecase.NormalizeUserCode(userCode) != "BCDFGHJK" {
+		return models.DeviceCode{}, models.Client{}, models.ErrorUserCodeInvalid
+	}
+	return models.DeviceCode{UserCode: "BCDFGHJK", ClientID: "tv", Scope: "openid profile"},
+		models.Client{ID: "tv", Name: "Телевизор"}, nil
+}
+
+func (f *fakeOAuth) DecideDevice(userCode string, session models.Session, approve bool) error {
+	if _, _, err := f.PendingDevice(userCode); err != nil {
+		return err
+	}
+	if f.decisions == nil {
+		f.decisions = map[string]bool{}
+	}
+	f.decisions[usecase.NormalizeUserCode(userCode)] = approve
+	return nil
+}
+
+func (f *fakeOAuth) DeviceToken(deviceCode, clientID, jkt string) (usecase.TokenPair, error) {
+	switch deviceCode {
+	case "pending":
+		return usecase.TokenPair{}, models.ErrorAuthorizationPending
+	case "fast":
+		return usecase.TokenPair{}, models.ErrorSlowDown
+	case "denied":
+		return usecase.TokenPair{}, models.ErrorAccessDenied
+	case "expired":
+		return usecase.TokenPair{}, models.ErrorExpiredToken
+	case "approved":
+		return usecase.TokenPair{AccessToken: "device", ExpiresIn: time.Now().Add(time.Minute).Unix()}, nil
+	}
+	return usecase.TokenPair{}, models.ErrorInvalidGrant
+}
+
+func (f *fakeOAuth) ExchangeToken(req usecase.TokenExchangeRequest) (usecase.TokenPair, error) {
+	if req.SubjectToken != "user" || req.SubjectTokenType != usecase.TokenTypeAccessToken {
+		return usecase.TokenPair{}, models.ErrorInvalidRequest
+	}
+	if req.Audience != "orders" {
+		return usecase.TokenPair{}, models.ErrorInvalidTarget
+	}
+	return usecase.TokenPair{AccessToken: "exchanged", Scope: req.Scope, ExpiresIn: time.Now().Add(time.Minute).Unix()}, nil
+}
+
+// fakeSessions открывает сессию для user@example.com с паролем "secret"
+// и принимает только cookie "valid".
+type fakeSessions struct{}
+
+func (fakeSessions) StartBrowserSession(params usecase.LoginParams) (string, models.Session, error) {
+	if params.Email != "user@example.com" || params.Password != "secret" {
+		return "", models.Session{}, models.ErrorWrongPassword
+	}
+	now := time.Now()
+	return "valid", models.Session{ID: "session", UserID: 1, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}, nil
+}
+
+func (fakeSessions) BrowserSession(secret string) (models.Session, error) {
+	if secret != "valid" {
+		return models.Session{}, models.ErrorSessionNotFound
+	}
+	return models.Session{ID: "session", UserID: 1, CreatedAt: time.Now()}, nil
+}
+
+func newOAuthTestRouter(oauth *fakeOAuth) *gin.Engine {
+	return newOAuthTestRouterWithDPoP(oauth, nil)
+}
+
+func newOAuthTestRouterWithDPoP(oauth *fakeOAuth, proofs *auth.DPoPVerifier) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger.Logger = zap.NewNop()
+
+	router := gin.New()
+	h := NewOAuthHandler(oauth, fakeSessions{}, true, proofs, "https://auth.test/")
+	router.GET("/oauth2/authorize", h.Authorize)
+	router.POST("/oauth2/authorize", h.AuthorizeDecision)
+	router.POST("/oauth2/device_authorization", h.DeviceAuthorization)
+	router.GET("/device", h.Device)
+	router.POST("/device", h.DeviceDecision)
+	router.POST("/oauth2/introspect", h.Introspect)
+	router.POST("/oauth2/revoke", h.Revoke)
+	router.POST("/oauth2/token", h.Token)
+	return router
+}
+
+func postForm(router *gin.Engine, path string, form url.Values, basicID, basicSecret string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if basicID != "" {
+		req.SetBasicAuth(basicID, basicSecret)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestOAuthHandler_Introspect(t *testing.T) {
+	now := jwt.NewNumericDate(time.Now())
+	router := newOAuthTestRouter(&fakeOAuth{claims: map[string]*auth.Claims{
+		"good": {
+			UserID:    1,
+			TokenType: auth.TokenTypeAccess,
+			ClientID:  "spa",
+			Scope:     "profile",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "1",
+				ExpiresAt: now,
+				IssuedAt:  now,
+				NotBefore: now,
+			},
+		},
+	}})
+
+	t.Run("Unauthenticated client", func(t *testing.T) {
+		w := postForm(router, "/oauth2/introspect", url.Values{"token": {"good"}}, "gateway", "wrong")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_client")
+	})
+
+	t.Run("Active token via client_secret_basic", func(t *testing.T) {
+		w := postForm(router, "/oauth2/introspect", url.Values{"token": {"good"}}, "gateway", "secret")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp IntrospectionResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.True(t, resp.Active)
+		assert.Equal(t, "1", resp.Sub)
+		assert.Equal(t, "profile", resp.Scope)
+		assert.Equal(t, "spa", resp.ClientID)
+		assert.Equal(t, "Bearer", resp.TokenType)
+	})
+
+	t.Run("Inactive token via client_secret_post", func(t *testing.T) {
+		form := url.Values{"token": {"bad"}, "client_id": {"gateway"}, "client_secret": {"secret"}}
+		w := postForm(router, "/oauth2/introspect", form, "", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"active": false}`, w.Body.String())
+	})
+}
+
+func TestOAuthHandler_Revoke(t *testing.T) {
+	oauth := &fakeOAuth{}
+	router := newOAuthTestRouter(oauth)
+
+	t.Run("Revoked", func(t *testing.T) {
+		w := postForm(router, "/oauth2/revoke", url.Values{"token": {"token"}}, "gateway", "secret")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"gateway:token"}, oauth.revoked, "отзыв выполняется от имени аутентифицированного клиента")
+	})
+
+	t.Run("No token", func(t *testing.T) {
+		w := postForm(router, "/oauth2/revoke", url.Values{}, "gateway", "secret")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Wrong secret", func(t *testing.T) {
+		w := postForm(router, "/oauth2/revoke", url.Values{"token": {"token"}}, "gateway", "wrong")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Storage error", func(t *testing.T) {
+		oauth.revokeErr = errors.New("db error")
+		w := postForm(router, "/oauth2/revoke", url.Values{"token": {"token"}}, "gateway", "secret")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+}
+
+func TestOAuthHandler_Authorize(t *testing.T) {
+	router := newOAuthTestRouter(&fakeOAuth{})
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"spa"},
+		"redirect_uri":          {"https://spa.test/cb"},
+		"state":                 {"xyz"},
+		"code_challenge":        {"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"},
+		"code_challenge_method": {"S256"},
+	}
+	authorize := func(query url.Values, cookie string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/oauth2/authorize?"+query.Encode(), nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: sessionCookie, Value: cookie})
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Login page without session", func(t *testing.T) {
+		w := authorize(query, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `name="password"`)
+		assert.Contains(t, w.Body.String(), `name="state" value="xyz"`)
+	})
+
+	t.Run("Code with session", func(t *testing.T) {
+		w := authorize(query, "valid")
+		require.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "https://spa.test/cb?code=code&state=xyz", w.Header().Get("Location"))
+	})
+
+	t.Run("Login sets opaque session cookie", func(t *testing.T) {
+		form := url.Values{"email": {"user@example.com"}, "password": {"secret"}}
+		for k, v := range query {
+			form[k] = v
+		}
+		w := postForm(router, "/oauth2/authorize", form, "", "")
+		require.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "https://spa.test/cb?code=code&state=xyz", w.Header().Get("Location"))
+
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, sessionCookie, cookies[0].Name)
+		assert.Equal(t, "valid", cookies[0].Value, "в cookie только секрет сессии")
+		assert.True(t, cookies[0].HttpOnly)
+		assert.True(t, cookies[0].Secure, "флаг Secure задается конфигурацией")
+		assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+	})
+
+	t.Run("Wrong password sets no cookie", func(t *testing.T) {
+		form := url.Values{"email": {"user@example.com"}, "password": {"wrong"}}
+		for k, v := range query {
+			form[k] = v
+		}
+		w := postForm(router, "/oauth2/authorize", form, "", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Empty(t, w.Result().Cookies())
+	})
+
+	t.Run("Unregistered redirect URI is not followed", func(t *testing.T) {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("redirect_uri", "https://evil.test/cb")
+		w := authorize(q, "valid")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+	})
+
+	t.Run("Missing PKCE is redirected as invalid_request", func(t *testing.T) {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Del("code_challenge_method")
+		w := authorize(q, "valid")
+		require.Equal(t, http.StatusSeeOther, w.Code)
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "invalid_request", location.Query().Get("error"))
+		assert.Equal(t, "xyz", location.Query().Get("state"))
+	})
+}
+
+func TestOAuthHandler_AuthorizeConsent(t *testing.T) {
+	router := newOAuthTestRouter(&fakeOAuth{})
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"partner"},
+		"redirect_uri":          {"https://spa.test/cb"},
+		"state":                 {"xyz"},
+		"code_challenge":        {"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"},
+		"code_challenge_method": {"S256"},
+	}
+	decide := func(form url.Values, cookie string) *httptest.ResponseRecorder {
+		for k, v := range query {
+			form[k] = v
+		}
+		req := httptest.NewRequest(http.MethodPost, "/oauth2/authorize", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: sessionCookie, Value: cookie})
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Consent page with session", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/oauth2/authorize?"+query.Encode(), nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: "valid"})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, "стороннему клиенту код без согласия не выдается")
+		assert.Empty(t, w.Header().Get("Location"))
+		assert.Contains(t, w.Body.String(), "Партнер")
+		assert.Contains(t, w.Body.String(), "<li>profile</li>", "без scope показываются все области клиента")
+		assert.Contains(t, w.Body.String(), `name="state" value="xyz"`)
+		assert.Contains(t, w.Body.String(), `value="approve"`)
+		assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	})
+
+	t.Run("Consent page after login", func(t *testing.T) {
+		w := decide(url.Values{"email": {"user@example.com"}, "password": {"secret"}}, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+		assert.Contains(t, w.Body.String(), `value="approve"`)
+		assert.Len(t, w.Result().Cookies(), 1)
+	})
+
+	t.Run("Approve", func(t *testing.T) {
+		w := decide(url.Values{"action": {"approve"}}, "valid")
+		require.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "https://spa.test/cb?code=code&state=xyz", w.Header().Get("Location"))
+	})
+
+	t.Run("Deny", func(t *testing.T) {
+		w := decide(url.Values{"action": {"deny"}}, "valid")
+		require.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "https://spa.test/cb?error=access_denied&state=xyz", w.Header().Get("Location"))
+	})
+
+	t.Run("Approve without session", func(t *testing.T) {
+		w := decide(url.Values{"action": {"approve"}}, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+		assert.Contains(t, w.Body.String(), `name="password"`)
+	})
+}
+
+func TestOAuthHandler_Token(t *testing.T) {
+	router := newOAuthTestRouter(&fakeOAuth{})
+
+	t.Run("Authorization code for public client", func(t *testing.T) {
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {"code"},
+			"code_verifier": {"verifier"},
+			"client_id":     {"spa"},
+		}
+		w := postForm(router, "/oauth2/token", form, "", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+		var resp OAuthTokenResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "access", resp.AccessToken)
+		assert.Equal(t, "Bearer", resp.TokenType)
+		assert.Equal(t, "id", resp.IDToken)
+		assert.Equal(t, "openid profile", resp.Scope)
+		assert.InDelta(t, 60, resp.ExpiresIn, 2)
+	})
+
+	t.Run("Client credentials", func(t *testing.T) {
+		form := url.Values{"grant_type": {"client_credentials"}, "scope": {"users:read"}}
+		w := postForm(router, "/oauth2/token", form, "gateway", "secret")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp OAuthTokenResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "service", resp.AccessToken)
+		assert.Equal(t, "users:read", resp.Scope)
+		assert.Empty(t, resp.RefreshToken)
+	})
+
+	t.Run("Token exchange", func(t *testing.T) {
+		form := url.Values{
+			"grant_type":         {models.GrantTokenExchange},
+			"subject_token":      {"user"},
+			"subject_token_type": {usecase.TokenTypeAccessToken},
+			"audience":           {"orders"},
+			"scope":              {"orders:read"},
+		}
+		w := postForm(router, "/oauth2/token", form, "gateway", "secret")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp OAuthTokenResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "exchanged", resp.AccessToken)
+		assert.Equal(t, usecase.TokenTypeAccessToken, resp.IssuedTokenType)
+		assert.Equal(t, "orders:read", resp.Scope)
+		assert.Empty(t, resp.RefreshToken)
+	})
+
+	tests := []struct {
+		name      string
+		form      url.Values
+		wantCode  int
+		wantError string
+	}{
+		{
+			name:      "Client credentials with foreign scope",
+			form:      url.Values{"grant_type": {"client_credentials"}, "scope": {"admin"}, "client_id": {"gateway"}, "client_secret": {"secret"}},
+			wantCode:  http.StatusBadRequest,
+			wantError: "invalid_scope",
+		},
+		{
+			name:      "Unknown public client",
+			form:      url.Values{"grant_type": {"authorization_code"}, "client_id": {"other"}},
+			wantCode:  http.StatusUnauthorized,
+			wantError: "invalid_client",
+		},
+		{
+			name:      "Invalid code",
+			form:      url.Values{"grant_type": {"authorization_code"}, "client_id": {"spa"}, "code": {"bad"}, "code_verifier": {"v"}},
+			wantCode:  http.StatusBadRequest,
+			wantError: "invalid_grant",
+		},
+		{
+			name:      "Missing code verifier",
+			form:      url.Values{"grant_type": {"authorization_code"}, "client_id": {"spa"}, "code": {"code"}},
+			wantCode:  http.StatusBadRequest,
+			wantError: "invalid_request",
+		},
+		{
+			name:      "Reused refresh token",
+			form:      url.Values{"grant_type": {"refresh_token"}, "client_id": {"spa"}, "refresh_token": {"old"}},
+			wantCode:  http.StatusBadRequest,
+			wantError: "invalid_grant",
+		},
+		{
+			name:      "Device authorization pending",
+			form:      url.Values{"grant_type": {models.GrantDeviceCode}, "client_id": {"spa"}, "device_code": {"pending"}},
+			wantCode:  http.StatusBadRequest,
+			wantError: "authorization_pending",
+		},
+		{
+			name:      "Device polling too fast",
+			form:      url.Values{"grant_type": {models.GrantDeviceCode}, "client_id": {"spa"}, "device_code": {"fast"}},
+			wantCode:  http.StatusBadRequest,
+			wantError: "slow_down",
+		},
+		{
+			name:      "Device access denied",
+			form:      url.Values{"grant_type": {models.GrantDeviceCode}, "client_id": {"spa"}, "device_code": {"denied"}},
+			wantCode:  http.StatusBadRequest,
+			wantError: "access_denied",
+		},
+		{
+			name:      "Device code expired",
+			form:      url.Values{"grant_type": {models.GrantDeviceCode}, "client_id": {"spa"}, "device_code": {"expired"}},
+			wantCode:  http.StatusBadRequest,
+			wantError: "expired_token",
+		},
+		{
+			name:      "Missing device code",
+			form:      url.Values{"grant_type": {models.GrantDeviceCode}, "client_id": {"spa"}},
+			wantCode:  http.StatusBadRequest,
+			wantError: "invalid_request",
+		},
+		{
+			name:      "Token exchange for foreign audience",
+			form:      url.Values{"grant_type": {models.GrantTokenExchange}, "client_id": {"gateway"}, "client_secret": {"secret"}, "subject_token": {"user"}, "subject_token_type": {usecase.TokenTypeAccessToken}, "audience": {"billing"}},
+			wantCode:  http.StatusBadRequest,
+			wantError: "invalid_target",
+		},
+		{
+			name:      "Token exchange for several audiences",
+			form:      url.Values{"grant_type": {models.GrantTokenExchange}, "client_id": {"gateway"}, "client_secret": {"secret"}, "subject_token": {"user"}, "subject_token_type": {usecase.TokenTypeAccessToken}, "audience": {"orders", "billing"}},
+			wantCode:  http.StatusBadRequest,
+			wantError: "invalid_target",
+		},
+		{
+			name:      "Token exchange with invalid subject token",
+			form:      url.Values{"grant_type": {models.GrantTokenExchange}, "client_id": {"gateway"}, "client_secret": {"secret"}, "subject_token": {"bad"}, "subject_token_type": {usecase.TokenTypeAccessToken}, "audience": {"orders"}},
+			wantCode:  http.StatusBadRequest,
+			wantError: "invalid_request",
+		},
+		{
+			name:      "Token exchange without subject token type",
+			form:      url.Values{"grant_type": {models.GrantTokenExchange}, "client_id": {"gateway"}, "client_secret": {"secret"}, "subject_token": {"user"}, "audience": {"orders"}},
+			wantCode:  http.StatusBadRequest,
+			wantError: "invalid_request",
+		},
+		{
+			name:      "Unsupported grant type",
+			form:      url.Values{"grant_type": {"password"}, "client_id": {"spa"}},
+			wantCode:  http.StatusBadRequest,
+			wantError: "unsupported_grant_type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postForm(router, "/oauth2/token", tt.form, "", "")
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantError)
+		})
+	}
+}
+
+func TestOAuthHandler_TokenDPoP(t *testing.T) {
+	router := newOAuthTestRouterWithDPoP(&fakeOAuth{}, auth.NewDPoPVerifier(auth.NewMemoryReplayCache(), time.Minute))
+	key := newDPoPKey(t)
+	const tokenURL = "https://auth.test/oauth2/token"
+
+	request := func(proofs ...string) *httptest.ResponseRecorder {
+		form := url.Values{"grant_type": {"client_credentials"}}
+		req := httptest.NewRequest(http.MethodPost, "/oauth2/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("gateway", "secret")
+		for _, proof := range proofs {
+			req.Header.Add("DPoP", proof)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	proof := key.proof(t, http.MethodPost, tokenURL, "")
+	w := request(proof)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp OAuthTokenResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "DPoP", resp.TokenType)
+	assert.Equal(t, "service"+key.jkt, resp.AccessToken, "токен привязан к ключу доказательства")
+
+	tests := []struct {
+		name   string
+		proofs []string
+	}{
+		{name: "Replayed proof", proofs: []string{proof}},
+		{name: "Proof for another URL", proofs: []string{key.proof(t, http.MethodPost, "https://auth.test/oauth2/revoke", "")}},
+		{name: "Several proofs", proofs: []string{key.proof(t, http.MethodPost, tokenURL, ""), key.proof(t, http.MethodPost, tokenURL, "")}},
+		{name: "Malformed proof", proofs: []string{"proof"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := request(tt.proofs...)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "invalid_dpop_proof")
+		})
+	}
+}
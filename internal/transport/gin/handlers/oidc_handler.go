@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/internal/usecase"
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+type OIDCHandler struct {
+	userUseCase usecase.UseCase
+	verifier    *auth.Verifier
+}
+
+func NewOIDCHandler(userUseCase usecase.UseCase, verifier *auth.Verifier) *OIDCHandler {
+	return &OIDCHandler{userUseCase: userUseCase, verifier: verifier}
+}
+
+// Discovery - документ OpenID Provider Metadata.
+type Discovery struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint"`
+	TokenEndpoint                    string   `json:"token_endpoint"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	UserinfoEndpoint                 string   `json:"userinfo_endpoint"`
+	IntrospectionEndpoint            string   `json:"introspection_endpoint"`
+	RevocationEndpoint               string   `json:"revocation_endpoint"`
+	DeviceAuthorizationEndpoint      string   `json:"device_authorization_endpoint"`
+	ResponseTypesSupported           []string `json:"response_types_supported"`
+	GrantTypesSupported              []string `json:"grant_types_supported"`
+	CodeChallengeMethodsSupported    []string `json:"code_challenge_methods_supported"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                  []string `json:"scopes_supported"`
+	ClaimsSupported                  []string `json:"claims_supported"`
+	TokenEndpointAuthMethods         []string `json:"token_endpoint_auth_methods_supported"`
+	DPoPSigningAlgValuesSupported    []string `json:"dpop_signing_alg_values_supported"`
+}
+
+// UserInfo - ответ /userinfo. name и email возвращаются только при наличии
+// областей profile и email соответственно.
+type UserInfo struct {
+	Subject string `json:"sub"`
+	Name    string `json:"name,omitempty"`
+	Email   string `json:"email,omitempty"`
+}
+
+// @Summary Метаданные OpenID Connect
+// @Description Возвращает документ discovery OpenID Connect
+// @Tags oidc
+// @Produce json
+// @Success 200 {object} Discovery
+// @Router /.well-known/openid-configuration [get]
+func (h *OIDCHandler) Discovery(c *gin.Context) {
+	issuer := strings.TrimSuffix(h.verifier.Issuer(), "/")
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, Discovery{
+		Issuer:                           h.verifier.Issuer(),
+		AuthorizationEndpoint:            issuer + "/oauth2/authorize",
+		TokenEndpoint:                    issuer + "/oauth2/token",
+		JWKSURI:                          issuer + "/.well-known/jwks.json",
+		UserinfoEndpoint:                 issuer + "/userinfo",
+		IntrospectionEndpoint:            issuer + "/oauth2/introspect",
+		RevocationEndpoint:               issuer + "/oauth2/revoke",
+		DeviceAuthorizationEndpoint:      issuer + "/oauth2/device_authorization",
+		ResponseTypesSupported:           []string{"code"},
+		GrantTypesSupported:              []string{"authorization_code", "refresh_token", "client_credentials", models.GrantDeviceCode, models.GrantTokenExchange},
+		CodeChallengeMethodsSupported:    []string{auth.PKCEMethodS256},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: []string{h.verifier.SigningAlgorithm()},
+		ScopesSupported:                  []string{"openid", "profile", "email"},
+		ClaimsSupported:                  []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "name", "email"},
+		TokenEndpointAuthMethods:         []string{"client_secret_basic", "client_secret_post", "none"},
+		DPoPSigningAlgValuesSupported:    auth.DPoPAlgorithms,
+	})
+}
+
+// @Summary Сведения о пользователе
+// @Description Возвращает claims пользователя по access токену с областью openid
+// @Tags oidc
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} UserInfo
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /userinfo [get]
+func (h *OIDCHandler) UserInfo(c *gin.Context) {
+	claims := c.MustGet("claims").(*auth.Claims)
+	if claims.IsClient() || !claims.HasScope("openid") {
+		c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_scope"})
+		return
+	}
+
+	user, err := h.userUseCase.GetUserByID(claims.UserID)
+	if err != nil {
+		if err == models.ErrorUserNotFound {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	info := UserInfo{Subject: claims.Subject}
+	if claims.HasScope("profile") {
+		info.Name = user.Name
+	}
+	if claims.HasScope("email") {
+		info.Email = user.Email
+	}
+	c.JSON(http.StatusOK, info)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Проверяющая сторона знает только адрес discovery: алгоритм, издателя
+// и ключ она берет из опубликованных документов.
+func TestOIDCHandler_IDTokenVerifiableFromDiscovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	ring, err := auth.LoadKeyRing(auth.KeyConfig{Algorithm: "ES256", PrivateKeyFile: keyFile})
+	require.NoError(t, err)
+
+	config := auth.TokenConfig{Issuer: "https://auth.test", Audience: "api"}
+	verifier := auth.NewVerifier(ring, config)
+	require.True(t, verifier.IDTokensSupported())
+	router := gin.New()
+	router.GET("/.well-known/openid-configuration", NewOIDCHandler(nil, verifier).Discovery)
+	router.GET("/.well-known/jwks.json", NewJWKSHandler(verifier).JWKS)
+
+	idToken, err := auth.NewSigner(ring, config).GenerateIDToken(auth.IDTokenParams{UserID: 7, Audience: "spa", Nonce: "n-0S6"})
+	require.NoError(t, err)
+
+	get := func(path string, out any) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), out))
+	}
+	var discovery Discovery
+	get("/.well-known/openid-configuration", &discovery)
+	jwksURI, err := url.Parse(discovery.JWKSURI)
+	require.NoError(t, err)
+	var jwks auth.JWKS
+	get(jwksURI.Path, &jwks)
+	assert.NotContains(t, discovery.IDTokenSigningAlgValuesSupported, "HS256")
+
+	claims := &auth.IDTokenClaims{}
+	_, err = jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		index := slices.IndexFunc(jwks.Keys, func(key auth.JWK) bool { return key.Kid == kid })
+		if index < 0 || jwks.Keys[index].Kty != "EC" || jwks.Keys[index].Crv != "P-256" {
+			return nil, errors.New("ключ не опубликован")
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwks.Keys[index].X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwks.Keys[index].Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	},
+		jwt.WithValidMethods(discovery.IDTokenSigningAlgValuesSupported),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience("spa"))
+	require.NoError(t, err)
+	assert.Equal(t, "7", claims.Subject)
+	assert.Equal(t, "n-0S6", claims.Nonce)
+}
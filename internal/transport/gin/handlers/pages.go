@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"github.com/fire9900/auth/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"html/template"
+)
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Вход</title>
+</head>
+<body>
+<h1>Вход</h1>
+{{if .ClientID}}<p>Приложение <b>{{.ClientID}}</b> запрашивает доступ к вашей учетной записи.</p>
+{{else}}<p>Войдите, чтобы подключить устройство.</p>
+{{end}}
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+<form method="post" action="{{.Action}}">
+{{range $name, $value := .Hidden}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}<label>Email <input type="email" name="email" autocomplete="username" required></label>
+<label>Пароль <input type="password" name="password" autocomplete="current-password" required></label>
+<button type="submit">Войти</button>
+</form>
+</body>
+</html>
+`))
+
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>Ошибка</title>
+</head>
+<body>
+<h1>Ошибка</h1>
+<p>{{.}}</p>
+</body>
+</html>
+`))
+
+var deviceCodePage = template.Must(template.New("device").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Подключение устройства</title>
+</head>
+<body>
+<h1>Подключение устройства</h1>
+<p>Введите код, показанный на экране устройства.</p>
+{{if .}}<p role="alert">{{.}}</p>{{end}}
+<form method="get" action="/device">
+<label>Код <input type="text" name="user_code" autocomplete="off" autocapitalize="characters" required></label>
+<button type="submit">Продолжить</button>
+</form>
+</body>
+</html>
+`))
+
+var consentPage = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Доступ к учетной записи</title>
+</head>
+<body>
+<h1>Доступ к учетной записи</h1>
+<p>Приложение <b>{{.ClientName}}</b> запрашивает доступ к вашей учетной записи.</p>
+{{if .Scopes}}<ul>
+{{range .Scopes}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}<form method="post" action="/oauth2/authorize">
+{{range $name, $value := .Hidden}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}<button type="submit" name="action" value="approve">Разрешить</button>
+<button type="submit" name="action" value="deny">Отклонить</button>
+</form>
+</body>
+</html>
+`))
+
+var deviceConfirmPage = template.Must(template.New("device_confirm").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Подключение устройства</title>
+</head>
+<body>
+<h1>Подключение устройства</h1>
+<p>Устройство с кодом <b>{{.UserCode}}</b> от имени приложения <b>{{.ClientName}}</b> запрашивает доступ к вашей учетной записи.</p>
+{{if .Scopes}}<ul>
+{{range .Scopes}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}<p>Разрешайте доступ, только если сами начали вход на этом устройстве.</p>
+<form method="post" action="/device">
+<input type="hidden" name="user_code" value="{{.UserCode}}">
+<button type="submit" name="action" value="approve">Разрешить</button>
+<button type="submit" name="action" value="deny">Отклонить</button>
+</form>
+</body>
+</html>
+`))
+
+var deviceDonePage = template.Must(template.New("device_done").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>Подключение устройства</title>
+</head>
+<body>
+<h1>Подключение устройства</h1>
+<p>{{if .}}Доступ разрешен. Вернитесь к устройству.{{else}}Доступ отклонен.{{end}}</p>
+</body>
+</html>
+`))
+
+type loginView struct {
+	Action   string
+	ClientID string
+	Hidden   map[string]string
+	Error    string
+}
+
+type consentView struct {
+	ClientName string
+	Scopes     []string
+	Hidden     map[string]string
+}
+
+type deviceConfirmView struct {
+	UserCode   string
+	ClientName string
+	Scopes     []string
+}
+
+// renderPage отдает серверную страницу. Страницы с формами запрещено
+// встраивать во фреймы, чтобы исключить кликджекинг.
+func renderPage(c *gin.Context, status int, page *template.Template, data any) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Frame-Options", "DENY")
+	c.Header("Content-Security-Policy", "frame-ancestors 'none'")
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := page.Execute(c.Writer, data); err != nil {
+		logger.Logger.Error("Ошибка отрисовки страницы", zap.Error(err))
+	}
+}
+
+func renderError(c *gin.Context, status int, message string) {
+	renderPage(c, status, errorPage, message)
+}
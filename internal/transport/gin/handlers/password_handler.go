@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/fire9900/auth/pkg/password"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// PolicyViolationResponse - ответ на пароль, нарушающий политику: Violations
+// перечисляет все нарушения с кодами, по которым фронтенд строит подсказки.
+type PolicyViolationResponse struct {
+	Error      string               `json:"error"`
+	Violations []password.Violation `json:"violations"`
+}
+
+// @Summary Политика паролей
+// @Description Возвращает действующие требования к паролю, чтобы фронтенд проверял их до отправки формы
+// @Tags users
+// @Produce json
+// @Success 200 {object} password.Policy
+// @Router /password-policy [get]
+func (h *UserHandler) PasswordPolicy(c *gin.Context) {
+	c.JSON(http.StatusOK, h.userUseCase.PasswordPolicy())
+}
+
+// policyViolation отвечает 400 со списком нарушений, если err - нарушение
+// политики паролей, и сообщает, был ли отправлен ответ.
+func policyViolation(c *gin.Context, err error) bool {
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, PolicyViolationResponse{
+		Error:      password.ErrorPolicyViolation.Error(),
+		Violations: policyErr.Violations,
+	})
+	return true
+}
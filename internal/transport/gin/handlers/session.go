@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/internal/usecase"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// sessionCookie - cookie браузерной сессии страниц входа. В ней хранится
+// только случайный секрет сессии: это не токен, и к API с ним не обратиться.
+const sessionCookie = "auth_session"
+
+// BrowserSessions - браузерные сессии страниц /oauth2/authorize и /device.
+type BrowserSessions interface {
+	StartBrowserSession(params usecase.LoginParams) (string, models.Session, error)
+	BrowserSession(secret string) (models.Session, error)
+}
+
+// startSession проверяет email и пароль из формы входа и открывает сессию.
+func (h *OAuthHandler) startSession(c *gin.Context) (models.Session, error) {
+	secret, session, err := h.sessions.StartBrowserSession(usecase.LoginParams{
+		Email:     c.PostForm("email"),
+		Password:  c.PostForm("password"),
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	})
+	if err != nil {
+		return models.Session{}, err
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookie, secret, int(time.Until(session.ExpiresAt).Seconds()), "/", "", h.secureCookies, true)
+	return session, nil
+}
+
+// currentSession возвращает браузерную сессию из cookie, если она есть и действительна.
+func (h *OAuthHandler) currentSession(c *gin.Context) (models.Session, bool) {
+	secret, err := c.Cookie(sessionCookie)
+	if err != nil || secret == "" {
+		return models.Session{}, false
+	}
+	session, err := h.sessions.BrowserSession(secret)
+	if err != nil {
+		return models.Session{}, false
+	}
+	return session, true
+}
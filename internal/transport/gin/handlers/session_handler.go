@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// SessionResponse - сессия в списке устройств. Current отмечает сессию,
+// которой принадлежит токен запроса.
+type SessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// @Summary Список сессий
+// @Description Возвращает действующие сессии текущего пользователя: устройство, User-Agent, IP, время входа и последней активности
+// @Tags auth
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} SessionResponse
+// @Failure 401 {object} object
+// @Failure 403 {object} object
+// @Failure 500 {object} object
+// @Router /me/sessions [get]
+func (h *UserHandler) Sessions(c *gin.Context) {
+	claims, ok := c.MustGet("claims").(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Невалидный токен"})
+		return
+	}
+
+	sessions, err := h.userUseCase.Sessions(claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{
+			Session: session,
+			Current: session.ID == claims.SessionID,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Завершить сессию
+// @Description Отзывает сессию текущего пользователя и все refresh токены, выданные в ней
+// @Tags auth
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "ID сессии"
+// @Success 200 {object} object{details=string}
+// @Failure 401 {object} object
+// @Failure 403 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Router /me/sessions/{id} [delete]
+func (h *UserHandler) RevokeSession(c *gin.Context) {
+	claims, ok := c.MustGet("claims").(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Невалидный токен"})
+		return
+	}
+
+	if err := h.userUseCase.RevokeSession(claims.UserID, c.Param("id")); err != nil {
+		if errors.Is(err, models.ErrorSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"details": "Сессия завершена"})
+}
@@ -1,15 +1,20 @@
 package handlers
 
 import (
+	"errors"
 	"github.com/fire9900/auth/internal/models"
-	"github.com/fire9900/auth/pkg/auth"
+	"github.com/fire9900/auth/internal/usecase"
 	"github.com/gin-gonic/gin"
 	"net/http"
 )
 
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required"`
+	ClientID   string `json:"client_id"`
+	RememberMe bool   `json:"remember_me"`
+	Nonce      string `json:"nonce"`
+	DeviceName string `json:"device_name"`
 }
 
 type LoginResponse struct {
@@ -19,6 +24,7 @@ type LoginResponse struct {
 type TokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token,omitempty"`
 	ExpiresIn    int64  `json:"expires_in"`
 	UserID       int    `json:"user_id"`
 	Role         string `json:"role"`
@@ -37,7 +43,16 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	accessToken, refreshToken, expiresIn, err := h.userUseCase.Authenticate(req.Email, req.Password)
+	pair, err := h.userUseCase.Authenticate(usecase.LoginParams{
+		Email:      req.Email,
+		Password:   req.Password,
+		ClientID:   req.ClientID,
+		RememberMe: req.RememberMe,
+		Nonce:      req.Nonce,
+		DeviceName: req.DeviceName,
+		UserAgent:  c.Request.UserAgent(),
+		IP:         c.ClientIP(),
+	})
 	if err != nil {
 		if err == models.ErrorWrongPassword {
 			c.JSON(http.StatusUnauthorized, gin.H{
@@ -46,14 +61,29 @@ func (h *UserHandler) Login(c *gin.Context) {
 			})
 			return
 		}
+		if err == models.ErrorInvalidClient {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Ошибка аутентификации",
+				"details": "Неизвестный client_id",
+			})
+			return
+		}
+		if err == models.ErrorUserSuspended {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Ошибка аутентификации",
+				"details": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, TokenResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:    expiresIn,
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		IDToken:      pair.IDToken,
+		ExpiresIn:    pair.ExpiresIn,
 		UserID:       user.ID,
 		Role:         user.Role,
 	})
@@ -66,33 +96,24 @@ func (h *UserHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	claims, err := auth.ValidateToken(req.RefreshToken)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Невалидный refresh токен"})
-		return
-	}
-
-	_, err = h.userUseCase.GetUserByID(claims.UserID)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не найден"})
-		return
-	}
-
-	accessToken, expiresIn, err := auth.GenerateAccessToken(claims.UserID)
+	pair, err := h.userUseCase.Refresh(req.RefreshToken)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации токена"})
-		return
-	}
-
-	refreshToken, err := auth.GenerateRefreshToken(claims.UserID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации refresh токена"})
+		switch {
+		case errors.Is(err, models.ErrorRefreshTokenReused),
+			errors.Is(err, models.ErrorRefreshTokenRevoked),
+			errors.Is(err, models.ErrorRefreshTokenNotFound),
+			errors.Is(err, models.ErrorRefreshTokenInvalid),
+			errors.Is(err, models.ErrorTokenClientMismatch):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Невалидный refresh токен"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации токена"})
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, TokenResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:    expiresIn,
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
 	})
 }
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"github.com/fire9900/auth/internal/models"
 	"github.com/fire9900/auth/internal/usecase"
+	"github.com/fire9900/auth/pkg/auth"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strconv"
@@ -46,10 +48,50 @@ func (h *UserHandler) GetAll(c *gin.Context) {
 	c.JSON(http.StatusOK, users)
 }
 
+// @Summary Выйти из аккаунта
+// @Description Отзывает предъявленный access токен и все refresh токены его сессии
+// @Tags auth
+// @Security ApiKeyAuth
+// @Success 200 {object} object{details=string}
+// @Failure 401 {object} object
+// @Failure 403 {object} object
+// @Failure 500 {object} object
+// @Router /logout [get]
 func (h *UserHandler) Logout(c *gin.Context) {
-	c.Set("userID", nil)
+	claims, ok := c.MustGet("claims").(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Невалидный токен"})
+		return
+	}
+
+	if err := h.userUseCase.Logout(claims); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"details": "Выход из аккаунта"})
-	c.Next()
+}
+
+// @Summary Выйти на всех устройствах
+// @Description Отзывает все access и refresh токены пользователя и завершает все его сессии, включая текущую
+// @Tags auth
+// @Security ApiKeyAuth
+// @Success 200 {object} object{details=string}
+// @Failure 401 {object} object
+// @Failure 403 {object} object
+// @Failure 500 {object} object
+// @Router /logout/all [get]
+func (h *UserHandler) LogoutAll(c *gin.Context) {
+	claims, ok := c.MustGet("claims").(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Невалидный токен"})
+		return
+	}
+
+	if err := h.userUseCase.LogoutAll(claims); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"details": "Выход на всех устройствах"})
 }
 
 // @Summary Найти пользователя по ID
@@ -110,7 +152,7 @@ func (h *UserHandler) GetByEmail(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Param user body models.User true "Данные пользователя"
 // @Success 201 {object} models.User
-// @Failure 400 {object} object
+// @Failure 400 {object} PolicyViolationResponse
 // @Failure 401 {object} object
 // @Failure 500 {object} object
 // @Router /users [post]
@@ -121,13 +163,11 @@ func (h *UserHandler) Create(c *gin.Context) {
 		return
 	}
 
-	if user.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Пароль не может быть пустым"})
-		return
-	}
-
 	createUser, err := h.userUseCase.CreateUser(user)
 	if err != nil {
+		if policyViolation(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -135,7 +175,7 @@ func (h *UserHandler) Create(c *gin.Context) {
 }
 
 // @Summary Обновить пароль пользователя
-// @Description Обновляет пароль пользователя по ID
+// @Description Обновляет пароль пользователя по ID. Менять чужой пароль может только администратор с областью users:write. Новый пароль проверяется по политике паролей
 // @Tags users
 // @Accept json
 // @Produce json
@@ -143,8 +183,9 @@ func (h *UserHandler) Create(c *gin.Context) {
 // @Param id path int true "ID пользователя"
 // @Param password body object{password=string} true "Новый пароль"
 // @Success 200 {object} models.User
-// @Failure 400 {object} object
+// @Failure 400 {object} PolicyViolationResponse
 // @Failure 401 {object} object
+// @Failure 403 {object} object
 // @Failure 404 {object} object
 // @Failure 500 {object} object
 // @Router /users/{id}/password [put]
@@ -164,19 +205,25 @@ func (h *UserHandler) UpdatePassword(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userUseCase.GetUserByID(id)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	claims, ok := c.MustGet("claims").(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Невалидный токен"})
 		return
 	}
 
-	user.Password = updateData.Password
-	if err = user.HashPassword(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка обработки пароля"})
-		return
-	}
-	updateUser, err := h.userUseCase.UpdateUser(id, user)
+	updateUser, err := h.userUseCase.UpdatePassword(claims, id, updateData.Password)
 	if err != nil {
+		if errors.Is(err, models.ErrorForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, models.ErrorUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if policyViolation(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -194,6 +241,7 @@ func (h *UserHandler) UpdatePassword(c *gin.Context) {
 // @Success 200 {object} object{message=string}
 // @Failure 400 {object} object
 // @Failure 401 {object} object
+// @Failure 403 {object} object
 // @Failure 500 {object} object
 // @Router /users/{id} [delete]
 func (h *UserHandler) Delete(c *gin.Context) {
@@ -240,3 +288,34 @@ func (h *UserHandler) CheckPassword(c *gin.Context) {
 	booler := h.userUseCase.CheckPassword(id, InPassword.Password)
 	c.JSON(http.StatusOK, booler)
 }
+
+// @Summary Заблокировать пользователя
+// @Description Запрещает пользователю вход и отзывает все его токены и сессии. Требует область users:write
+// @Tags users
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "ID пользователя"
+// @Success 200 {object} object{details=string}
+// @Failure 400 {object} object
+// @Failure 401 {object} object
+// @Failure 403 {object} object
+// @Failure 404 {object} object
+// @Failure 500 {object} object
+// @Router /admin/users/{id}/suspend [post]
+func (h *UserHandler) Suspend(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Параметр id недействительный"})
+		return
+	}
+
+	if err := h.userUseCase.SuspendUser(id); err != nil {
+		if errors.Is(err, models.ErrorUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"details": "Пользователь заблокирован"})
+}
@@ -4,6 +4,7 @@ import (
 	_ "github.com/fire9900/auth/docs"
 	"github.com/fire9900/auth/internal/transport/gin/handlers"
 	"github.com/fire9900/auth/internal/usecase"
+	"github.com/fire9900/auth/pkg/auth"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -11,13 +12,17 @@ import (
 	"time"
 )
 
-func SetupRouter(userUseCase usecase.UseCase) *gin.Engine {
+// SetupRouter собирает маршруты сервиса. secureCookies выставляет флаг Secure
+// cookie браузерной сессии страниц входа. Эндпоинты OpenID Connect
+// регистрируются только с асимметричным ключом подписи.
+func SetupRouter(userUseCase usecase.UseCase, oauthUseCase usecase.OAuth, clientUseCase usecase.Clients,
+	tokens handlers.AccessTokenValidator, verifier *auth.Verifier, proofs *auth.DPoPVerifier, secureCookies bool) *gin.Engine {
 	router := gin.Default()
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "DPoP"},
+		ExposeHeaders:    []string{"Content-Length", "WWW-Authenticate"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -28,18 +33,67 @@ func SetupRouter(userUseCase usecase.UseCase) *gin.Engine {
 		api.POST("/login", userHandler.Login)
 		api.POST("/refresh", userHandler.RefreshToken)
 		api.POST("/users", userHandler.Create)
+		api.GET("/password-policy", userHandler.PasswordPolicy)
 		api.GET("/users", userHandler.GetAll)
 		api.GET("/user/:id", userHandler.GetByID)
 		auth := api.Group("/")
-		auth.Use(handlers.AuthMiddleware())
+		auth.Use(handlers.AuthMiddleware(tokens, proofs, verifier.Issuer()))
 		{
 			auth.GET("/users/:email", userHandler.GetByEmail)
-			auth.PUT("/users/:id", userHandler.UpdatePassword)
-			auth.DELETE("/users/:id", userHandler.Delete)
+			auth.PUT("/users/:id", handlers.RequireUser(), userHandler.UpdatePassword)
+			auth.DELETE("/users/:id", handlers.RequireUser(), userHandler.Delete)
 			auth.POST("/user/:id", userHandler.CheckPassword)
-			auth.GET("/logout", userHandler.Logout)
+			auth.GET("/logout", handlers.RequireUser(), userHandler.Logout)
+			auth.GET("/logout/all", handlers.RequireUser(), userHandler.LogoutAll)
+			auth.GET("/me/sessions", handlers.RequireUser(), userHandler.Sessions)
+			auth.DELETE("/me/sessions/:id", handlers.RequireUser(), userHandler.RevokeSession)
 		}
+
+		users := api.Group("/admin/users")
+		users.Use(handlers.AuthMiddleware(tokens, proofs, verifier.Issuer()))
+		{
+			users.POST("/:id/suspend", handlers.RequireScope("users:write"), userHandler.Suspend)
+		}
+
+		clientHandler := handlers.NewClientHandler(clientUseCase)
+		clients := api.Group("/admin/clients")
+		clients.Use(handlers.AuthMiddleware(tokens, proofs, verifier.Issuer()))
+		{
+			clients.GET("", handlers.RequireScope("clients:read"), clientHandler.GetAll)
+			clients.GET("/:id", handlers.RequireScope("clients:read"), clientHandler.GetByID)
+			clients.POST("", handlers.RequireScope("clients:write"), clientHandler.Create)
+			clients.PUT("/:id", handlers.RequireScope("clients:write"), clientHandler.Update)
+			clients.DELETE("/:id", handlers.RequireScope("clients:write"), clientHandler.Delete)
+			clients.POST("/:id/secret", handlers.RequireScope("clients:write"), clientHandler.RotateSecret)
+		}
+	}
+	oauthHandler := handlers.NewOAuthHandler(oauthUseCase, userUseCase, secureCookies, proofs, verifier.Issuer())
+	oauth := router.Group("/oauth2")
+	{
+		oauth.POST("/device_authorization", oauthHandler.DeviceAuthorization)
+		oauth.GET("/authorize", oauthHandler.Authorize)
+		oauth.POST("/authorize", oauthHandler.AuthorizeDecision)
+		oauth.POST("/token", oauthHandler.Token)
+		oauth.POST("/introspect", oauthHandler.Introspect)
+		oauth.POST("/revoke", oauthHandler.Revoke)
 	}
+	router.GET("/device", oauthHandler.Device)
+	router.POST("/device", oauthHandler.DeviceDecision)
+
+	jwksHandler := handlers.NewJWKSHandler(verifier)
+	router.GET("/.well-known/jwks.json", jwksHandler.JWKS)
+
+	if verifier.IDTokensSupported() {
+		oidcHandler := handlers.NewOIDCHandler(userUseCase, verifier)
+		router.GET("/.well-known/openid-configuration", oidcHandler.Discovery)
+		userinfo := router.Group("/userinfo")
+		userinfo.Use(handlers.AuthMiddleware(tokens, proofs, verifier.Issuer()))
+		{
+			userinfo.GET("", oidcHandler.UserInfo)
+			userinfo.POST("", oidcHandler.UserInfo)
+		}
+	}
+
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	return router
 }
@@ -1,33 +1,211 @@
 package usecase
 
 import (
+	"errors"
 	"fmt"
 	"github.com/fire9900/auth/internal/models"
 	"github.com/fire9900/auth/pkg/auth"
+	"github.com/fire9900/auth/pkg/logger"
+	"go.uber.org/zap"
+	"time"
 )
 
-func (uc *UserUseCase) Authenticate(email string, password string) (string, string, int64, error) {
+// BrowserSessionTTL - срок жизни браузерной сессии страниц входа. Она нужна
+// только для выдачи кодов авторизации и подтверждения устройств.
+const BrowserSessionTTL = time.Hour
+
+// LoginParams - данные входа по паролю. RememberMe выбирает длинный срок
+// жизни refresh токена. ClientID - зарегистрированный клиент, для которого
+// выполняется вход: он выбирает переопределения сроков и аудиторию ID токена,
+// а выданный refresh токен обновляется только с аутентификацией этого клиента.
+// Nonce передается в ID токен без изменений.
+// DeviceName, UserAgent и IP сохраняются в сессии для списка устройств.
+type LoginParams struct {
+	Email      string
+	Password   string
+	ClientID   string
+	RememberMe bool
+	Nonce      string
+	DeviceName string
+	UserAgent  string
+	IP         string
+}
+
+// Authenticate проверяет пароль и начинает сессию. Незарегистрированный
+// ClientID отклоняется с models.ErrorInvalidClient.
+func (uc *UserUseCase) Authenticate(params LoginParams) (TokenPair, error) {
+	if params.ClientID != "" {
+		if _, err := uc.tokens.clients.GetByID(params.ClientID); err != nil {
+			if errors.Is(err, models.ErrorClientNotFound) {
+				return TokenPair{}, models.ErrorInvalidClient
+			}
+			return TokenPair{}, err
+		}
+	}
+
+	user, err := uc.checkCredentials(params.Email, params.Password)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	sessionID, err := auth.NewTokenID()
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("ошибка генерации идентификатора сессии: %w", err)
+	}
+	now := uc.tokens.now()
+	err = uc.sessions.Create(models.Session{
+		ID:         sessionID,
+		UserID:     user.ID,
+		ClientID:   params.ClientID,
+		DeviceName: params.DeviceName,
+		UserAgent:  params.UserAgent,
+		IP:         params.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now,
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return uc.tokens.IssuePair(user, IssueOptions{
+		ClientID:   params.ClientID,
+		RememberMe: params.RememberMe,
+		Nonce:      params.Nonce,
+		SessionID:  sessionID,
+	})
+}
+
+// StartBrowserSession проверяет пароль и открывает браузерную сессию страниц
+// входа /oauth2/authorize и /device. Токены для нее не выпускаются: браузер
+// получает случайный секрет, а сессия хранится под его хешем, поэтому
+// ни cookie, ни список сессий не дают доступа к API.
+func (uc *UserUseCase) StartBrowserSession(params LoginParams) (string, models.Session, error) {
+	user, err := uc.checkCredentials(params.Email, params.Password)
+	if err != nil {
+		return "", models.Session{}, err
+	}
+
+	secret, err := auth.NewTokenID()
+	if err != nil {
+		return "", models.Session{}, fmt.Errorf("ошибка генерации секрета сессии: %w", err)
+	}
+	now := uc.tokens.now()
+	session := models.Session{
+		ID:         auth.HashToken(secret),
+		UserID:     user.ID,
+		DeviceName: params.DeviceName,
+		UserAgent:  params.UserAgent,
+		IP:         params.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(BrowserSessionTTL),
+	}
+	if err := uc.sessions.Create(session); err != nil {
+		return "", models.Session{}, err
+	}
+	return secret, session, nil
+}
+
+// BrowserSession находит действующую браузерную сессию по секрету из cookie.
+// Сессия удаленного или заблокированного пользователя недействительна.
+func (uc *UserUseCase) BrowserSession(secret string) (models.Session, error) {
+	if secret == "" {
+		return models.Session{}, models.ErrorSessionNotFound
+	}
+	session, err := uc.sessions.GetByID(auth.HashToken(secret))
+	if err != nil {
+		return models.Session{}, err
+	}
+	if session.RevokedAt != nil || !uc.tokens.now().Before(session.ExpiresAt) {
+		return models.Session{}, models.ErrorSessionNotFound
+	}
+
+	user, err := uc.repo.GetByID(session.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrorUserNotFound) {
+			return models.Session{}, models.ErrorSessionNotFound
+		}
+		return models.Session{}, err
+	}
+	if user.Suspended {
+		return models.Session{}, models.ErrorSessionNotFound
+	}
+	return session, nil
+}
+
+// checkCredentials находит пользователя по email и проверяет пароль.
+// Неизвестный email неотличим от неверного пароля. Хеш пароля
+// заблокированного пользователя не пересчитывается.
+func (uc *UserUseCase) checkCredentials(email, password string) (models.User, error) {
 	user, err := uc.repo.GetByEmail(email)
 	if err != nil {
 		if err == models.ErrorUserNotFound {
-			return "", "", 0, models.ErrorWrongPassword
+			return models.User{}, models.ErrorWrongPassword
 		}
-		return "", "", 0, err
+		return models.User{}, err
 	}
 
 	if err := user.CheckPassword(password); err != nil {
-		return "", "", 0, models.ErrorWrongPassword
+		return models.User{}, models.ErrorWrongPassword
+	}
+	if user.Suspended {
+		return models.User{}, models.ErrorUserSuspended
+	}
+	if user.NeedsRehash() {
+		uc.rehashPassword(user, password)
 	}
+	return user, nil
+}
 
-	accessToken, expiresIn, err := auth.GenerateAccessToken(user.ID)
-	if err != nil {
-		return "", "", 0, fmt.Errorf("ошибка генерации access токена: %w", err)
+// rehashPassword пересчитывает хеш пароля текущим алгоритмом, пока пароль
+// известен после успешного входа. Ошибка не мешает входу: хеш будет
+// пересчитан при следующем входе.
+func (uc *UserUseCase) rehashPassword(user models.User, password string) {
+	user.Password = password
+	if err := user.HashPassword(); err != nil {
+		logger.Logger.Warn("Не удалось пересчитать хеш пароля",
+			zap.Int("user_id", user.ID),
+			zap.Error(err))
+		return
+	}
+	if err := uc.repo.UpdatePasswordHash(user.ID, user.Password); err != nil {
+		logger.Logger.Warn("Не удалось сохранить пересчитанный хеш пароля",
+			zap.Int("user_id", user.ID),
+			zap.Error(err))
+		return
 	}
+	logger.Logger.Info("Хеш пароля пересчитан",
+		zap.Int("user_id", user.ID))
+}
+
+// Sessions возвращает действующие сессии пользователя, последние активные - первыми.
+func (uc *UserUseCase) Sessions(userID int) ([]models.Session, error) {
+	return uc.sessions.GetActiveByUser(userID, uc.tokens.now())
+}
 
-	refreshToken, err := auth.GenerateRefreshToken(user.ID)
+// RevokeSession завершает сессию пользователя вместе с ее refresh токенами.
+// Чужая сессия считается ненайденной, чтобы не раскрывать ее существование.
+func (uc *UserUseCase) RevokeSession(userID int, sessionID string) error {
+	session, err := uc.sessions.GetByID(sessionID)
 	if err != nil {
-		return "", "", 0, fmt.Errorf("ошибка генерации refresh токена: %w", err)
+		return err
 	}
+	if session.UserID != userID || session.RevokedAt != nil {
+		return models.ErrorSessionNotFound
+	}
+	return uc.tokens.RevokeSession(session.ID, uc.tokens.now())
+}
+
+func (uc *UserUseCase) Refresh(refreshToken string) (TokenPair, error) {
+	return uc.tokens.Refresh(refreshToken)
+}
+
+func (uc *UserUseCase) Logout(claims *auth.Claims) error {
+	return uc.tokens.Revoke(claims)
+}
 
-	return accessToken, refreshToken, expiresIn, nil
+// LogoutAll завершает все сессии пользователя на всех устройствах, включая текущую.
+func (uc *UserUseCase) LogoutAll(claims *auth.Claims) error {
+	return uc.tokens.RevokeAll(claims.UserID)
 }
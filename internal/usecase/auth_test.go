@@ -0,0 +1,188 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/password"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUserUseCase_Logout(t *testing.T) {
+	env := newTestEnv(t)
+	env.createUser(t, "user@example.com", "user")
+	current := env.login(t, "user@example.com")
+	other := env.login(t, "user@example.com")
+
+	claims, err := env.tokens.ValidateAccessToken(current.AccessToken)
+	require.NoError(t, err)
+	require.NoError(t, env.userUC.Logout(claims))
+
+	_, err = env.tokens.ValidateAccessToken(current.AccessToken)
+	assert.ErrorIs(t, err, models.ErrorTokenRevoked)
+	_, err = env.userUC.Refresh(current.RefreshToken)
+	assert.ErrorIs(t, err, models.ErrorRefreshTokenRevoked)
+
+	_, err = env.tokens.ValidateAccessToken(other.AccessToken)
+	assert.NoError(t, err, "выход завершает только текущую сессию")
+	_, err = env.userUC.Refresh(other.RefreshToken)
+	assert.NoError(t, err)
+}
+
+func TestUserUseCase_LogoutAll(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser(t, "user@example.com", "user")
+	current := env.login(t, "user@example.com")
+	other := env.login(t, "user@example.com")
+
+	claims, err := env.tokens.ValidateAccessToken(current.AccessToken)
+	require.NoError(t, err)
+	require.NoError(t, env.userUC.LogoutAll(claims))
+	assert.Equal(t, 1, env.users.users[user.ID].TokenVersion)
+
+	for _, pair := range []TokenPair{current, other} {
+		_, err := env.tokens.ValidateAccessToken(pair.AccessToken)
+		assert.ErrorIs(t, err, models.ErrorTokenRevoked)
+		_, err = env.userUC.Refresh(pair.RefreshToken)
+		assert.ErrorIs(t, err, models.ErrorRefreshTokenRevoked)
+	}
+	sessions, err := env.userUC.Sessions(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	pair := env.login(t, "user@example.com")
+	_, err = env.tokens.ValidateAccessToken(pair.AccessToken)
+	assert.NoError(t, err, "после выхода со всех устройств можно войти снова")
+}
+
+func TestUserUseCase_AuthenticateRehashesLegacyPassword(t *testing.T) {
+	env := newTestEnv(t)
+	models.PasswordHasher = password.NewHashers(password.NewArgon2id(password.Argon2Params{
+		Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 16,
+	}), password.Bcrypt{})
+	user := env.createUser(t, "user@example.com", "user")
+	suspended := env.createUser(t, "suspended@example.com", "user")
+	legacy, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	for _, id := range []int{user.ID, suspended.ID} {
+		require.NoError(t, env.users.UpdatePasswordHash(id, string(legacy)))
+	}
+	require.NoError(t, env.userUC.SuspendUser(suspended.ID))
+
+	env.login(t, "user@example.com")
+	stored := env.users.users[user.ID]
+	assert.True(t, strings.HasPrefix(stored.Password, "$argon2id$"), "bcrypt хеш заменен на Argon2id")
+	assert.NoError(t, stored.CheckPassword("secret"))
+	assert.False(t, stored.NeedsRehash())
+	env.login(t, "user@example.com")
+
+	_, err = env.userUC.Authenticate(LoginParams{Email: "suspended@example.com", Password: "secret"})
+	assert.ErrorIs(t, err, models.ErrorUserSuspended)
+	assert.Equal(t, string(legacy), env.users.users[suspended.ID].Password, "хеш заблокированного пользователя не пересчитывается")
+}
+
+func TestUserUseCase_AuthenticateClient(t *testing.T) {
+	env := newTestEnv(t)
+	env.createUser(t, "user@example.com", "user")
+	env.clients.clients["mobile"] = models.Client{ID: "mobile", Public: true, RefreshTokenTTL: 3600}
+
+	_, err := env.userUC.Authenticate(LoginParams{Email: "user@example.com", Password: "secret", ClientID: "unknown"})
+	assert.ErrorIs(t, err, models.ErrorInvalidClient)
+	assert.Empty(t, env.sessions.sessions, "вход с неизвестным клиентом не начинает сессию")
+
+	pair, err := env.userUC.Authenticate(LoginParams{Email: "user@example.com", Password: "secret", ClientID: "mobile"})
+	require.NoError(t, err)
+	claims, err := env.tokens.ValidateAccessToken(pair.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "mobile", claims.ClientID)
+	assert.Empty(t, pair.IDToken, "ID токен не подписывается секретом HS256")
+	require.Len(t, env.refresh.tokens, 1)
+	assert.Equal(t, "mobile", env.refresh.tokens[0].ClientID)
+}
+
+func TestUserUseCase_RevokeSession(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser(t, "user@example.com", "user")
+	other := env.createUser(t, "other@example.com", "user")
+	phone := env.login(t, "user@example.com")
+	laptop := env.login(t, "user@example.com")
+	env.login(t, "other@example.com")
+
+	sessions, err := env.userUC.Sessions(user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	claims, err := env.tokens.ValidateAccessToken(phone.AccessToken)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, env.userUC.RevokeSession(other.ID, claims.SessionID), models.ErrorSessionNotFound,
+		"чужая сессия неотличима от несуществующей")
+	assert.ErrorIs(t, env.userUC.RevokeSession(user.ID, "unknown"), models.ErrorSessionNotFound)
+
+	require.NoError(t, env.userUC.RevokeSession(user.ID, claims.SessionID))
+	_, err = env.userUC.Refresh(phone.RefreshToken)
+	assert.ErrorIs(t, err, models.ErrorRefreshTokenRevoked)
+	assert.ErrorIs(t, env.userUC.RevokeSession(user.ID, claims.SessionID), models.ErrorSessionNotFound)
+
+	sessions, err = env.userUC.Sessions(user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.NotEqual(t, claims.SessionID, sessions[0].ID)
+	_, err = env.userUC.Refresh(laptop.RefreshToken)
+	assert.NoError(t, err, "остальные сессии продолжают действовать")
+	others, err := env.userUC.Sessions(other.ID)
+	require.NoError(t, err)
+	assert.Len(t, others, 1)
+}
+
+func TestUserUseCase_BrowserSession(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser(t, "user@example.com", "user")
+	now := time.Now()
+	env.setClock(&now)
+
+	_, _, err := env.userUC.StartBrowserSession(LoginParams{Email: "user@example.com", Password: "wrong"})
+	assert.ErrorIs(t, err, models.ErrorWrongPassword)
+
+	secret, session, err := env.userUC.StartBrowserSession(LoginParams{Email: "user@example.com", Password: "secret"})
+	require.NoError(t, err)
+	assert.Empty(t, env.refresh.tokens, "браузерная сессия не выпускает refresh токены")
+	assert.NotEqual(t, secret, session.ID, "в базе хранится только хеш секрета")
+	assert.Equal(t, user.ID, session.UserID)
+
+	found, err := env.userUC.BrowserSession(secret)
+	require.NoError(t, err)
+	assert.Equal(t, session.ID, found.ID)
+	_, err = env.userUC.BrowserSession(session.ID)
+	assert.ErrorIs(t, err, models.ErrorSessionNotFound, "идентификатор из списка сессий не заменяет секрет")
+	_, err = env.userUC.BrowserSession("")
+	assert.ErrorIs(t, err, models.ErrorSessionNotFound)
+
+	t.Run("Expired", func(t *testing.T) {
+		now = now.Add(BrowserSessionTTL)
+		defer func() { now = now.Add(-BrowserSessionTTL) }()
+		_, err := env.userUC.BrowserSession(secret)
+		assert.ErrorIs(t, err, models.ErrorSessionNotFound)
+	})
+
+	t.Run("Logout everywhere", func(t *testing.T) {
+		secret, _, err := env.userUC.StartBrowserSession(LoginParams{Email: "user@example.com", Password: "secret"})
+		require.NoError(t, err)
+		require.NoError(t, env.tokens.RevokeAll(user.ID))
+		_, err = env.userUC.BrowserSession(secret)
+		assert.ErrorIs(t, err, models.ErrorSessionNotFound)
+	})
+
+	t.Run("Suspended user", func(t *testing.T) {
+		secret, _, err := env.userUC.StartBrowserSession(LoginParams{Email: "user@example.com", Password: "secret"})
+		require.NoError(t, err)
+		user := env.users.users[user.ID]
+		user.Suspended = true
+		env.users.users[user.ID] = user
+		_, err = env.userUC.BrowserSession(secret)
+		assert.ErrorIs(t, err, models.ErrorSessionNotFound)
+	})
+}
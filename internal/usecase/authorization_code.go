@@ -0,0 +1,181 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/fire9900/auth/pkg/logger"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+// AuthorizationCodeTTL - срок жизни кода авторизации (RFC 6749, 4.1.2
+// рекомендует не более 10 минут).
+const AuthorizationCodeTTL = 5 * time.Minute
+
+// AuthorizationRequest - параметры запроса /oauth2/authorize.
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+}
+
+// CodeExchange - параметры обмена кода на токены (grant_type=authorization_code).
+// ClientID - уже аутентифицированный клиент. JKT - отпечаток ключа DPoP,
+// к которому привязываются токены, пустой для Bearer токенов.
+type CodeExchange struct {
+	Code         string
+	RedirectURI  string
+	ClientID     string
+	CodeVerifier string
+	JKT          string
+}
+
+// ValidateAuthorizationRequest проверяет запрос авторизации и возвращает
+// клиента, чтобы страница согласия могла показать его название. Ошибки
+// models.ErrorInvalidClient и models.ErrorInvalidRedirectURI нельзя
+// возвращать клиенту редиректом: redirect_uri не подтвержден (RFC 6749, 4.1.2.1).
+func (uc *OAuthUseCase) ValidateAuthorizationRequest(req AuthorizationRequest) (models.Client, error) {
+	if err := uc.clients.ValidateRedirectURI(req.ClientID, req.RedirectURI); err != nil {
+		return models.Client{}, err
+	}
+	if req.ResponseType != "code" {
+		return models.Client{}, models.ErrorUnsupportedResponseType
+	}
+	client, err := uc.client(req.ClientID)
+	if err != nil {
+		return models.Client{}, err
+	}
+	if !client.AllowsGrant(models.GrantAuthorizationCode) {
+		return models.Client{}, models.ErrorUnauthorizedClient
+	}
+	for _, scope := range strings.Fields(req.Scope) {
+		if !client.AllowsScope(scope) {
+			return models.Client{}, models.ErrorInvalidScope
+		}
+	}
+	if req.CodeChallengeMethod != auth.PKCEMethodS256 || !auth.ValidPKCEValue(req.CodeChallenge) {
+		return models.Client{}, models.ErrorPKCERequired
+	}
+	return client, nil
+}
+
+// IssueAuthorizationCode выдает код пользователю браузерной сессии session.
+// Время входа в сессию становится auth_time ID токена. Без scope
+// запрашиваются все области, разрешенные клиенту.
+func (uc *OAuthUseCase) IssueAuthorizationCode(req AuthorizationRequest, session models.Session) (string, error) {
+	client, err := uc.ValidateAuthorizationRequest(req)
+	if err != nil {
+		return "", err
+	}
+	if req.Scope == "" {
+		req.Scope = strings.Join(client.Scopes, " ")
+	}
+
+	code, err := auth.NewTokenID()
+	if err != nil {
+		return "", fmt.Errorf("ошибка генерации кода авторизации: %w", err)
+	}
+	familyID, err := auth.NewTokenID()
+	if err != nil {
+		return "", fmt.Errorf("ошибка генерации семейства токенов: %w", err)
+	}
+
+	now := uc.tokens.now()
+	err = uc.codes.Create(models.AuthorizationCode{
+		CodeHash:      auth.HashToken(code),
+		ClientID:      req.ClientID,
+		UserID:        session.UserID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         req.Scope,
+		CodeChallenge: req.CodeChallenge,
+		Nonce:         req.Nonce,
+		FamilyID:      familyID,
+		AuthTime:      session.CreatedAt,
+		ExpiresAt:     now.Add(AuthorizationCodeTTL),
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// ExchangeAuthorizationCode обменивает код на токены. Код одноразовый:
+// при повторном предъявлении отзывается семейство refresh токенов,
+// выданное по первому обмену (RFC 6749, 4.1.2).
+func (uc *OAuthUseCase) ExchangeAuthorizationCode(exchange CodeExchange) (TokenPair, error) {
+	client, err := uc.client(exchange.ClientID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !client.AllowsGrant(models.GrantAuthorizationCode) {
+		return TokenPair{}, models.ErrorUnauthorizedClient
+	}
+
+	stored, err := uc.codes.GetByHash(auth.HashToken(exchange.Code))
+	if err != nil {
+		if errors.Is(err, models.ErrorAuthorizationCodeNotFound) {
+			return TokenPair{}, models.ErrorInvalidGrant
+		}
+		return TokenPair{}, err
+	}
+
+	now := uc.tokens.now()
+	if stored.ClientID != exchange.ClientID || stored.RedirectURI != exchange.RedirectURI ||
+		!now.Before(stored.ExpiresAt) || !auth.VerifyPKCE(stored.CodeChallenge, exchange.CodeVerifier) {
+		return TokenPair{}, models.ErrorInvalidGrant
+	}
+
+	if stored.UsedAt != nil {
+		return TokenPair{}, uc.revokeCodeFamily(stored, now)
+	}
+	marked, err := uc.codes.MarkUsed(stored.CodeHash, now)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !marked {
+		return TokenPair{}, uc.revokeCodeFamily(stored, now)
+	}
+
+	user, err := uc.users.GetByID(stored.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrorUserNotFound) {
+			return TokenPair{}, models.ErrorInvalidGrant
+		}
+		return TokenPair{}, err
+	}
+
+	pair, err := uc.tokens.IssuePair(user, IssueOptions{
+		ClientID:  stored.ClientID,
+		Scopes:    scopesOf(stored.Scope),
+		Nonce:     stored.Nonce,
+		AuthTime:  stored.AuthTime,
+		SessionID: stored.FamilyID,
+		JKT:       exchange.JKT,
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !client.AllowsGrant(models.GrantRefreshToken) {
+		pair.RefreshToken = ""
+	}
+	return pair, nil
+}
+
+func (uc *OAuthUseCase) revokeCodeFamily(stored models.AuthorizationCode, now time.Time) error {
+	logger.Logger.Warn("Повторное использование кода авторизации, токены отзываются",
+		zap.String("client_id", stored.ClientID),
+		zap.String("family_id", stored.FamilyID))
+	if err := uc.tokens.RevokeSession(stored.FamilyID, now); err != nil {
+		return err
+	}
+	return models.ErrorInvalidGrant
+}
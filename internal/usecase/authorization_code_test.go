@@ -0,0 +1,110 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRedirectURI  = "https://app.test/callback"
+	testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+// authorize выдает код клиенту clientID от имени вошедшего пользователя email.
+func (env *testEnv) authorize(t *testing.T, clientID, email, scope string) string {
+	code, err := env.oauth.IssueAuthorizationCode(AuthorizationRequest{
+		ResponseType:        "code",
+		ClientID:            clientID,
+		RedirectURI:         testRedirectURI,
+		Scope:               scope,
+		CodeChallenge:       auth.PKCEChallenge(testCodeVerifier),
+		CodeChallengeMethod: auth.PKCEMethodS256,
+	}, env.browserSession(t, email))
+	require.NoError(t, err)
+	return code
+}
+
+func (env *testEnv) addCodeClient(id string, scopes ...string) {
+	env.clients.clients[id] = models.Client{
+		ID:           id,
+		Public:       true,
+		GrantTypes:   []string{models.GrantAuthorizationCode, models.GrantRefreshToken},
+		RedirectURIs: []string{testRedirectURI},
+		Scopes:       scopes,
+	}
+}
+
+func TestOAuthUseCase_AuthorizationCodeScopes(t *testing.T) {
+	env := newTestEnv(t)
+	env.createUser(t, "admin@example.com", "admin")
+	env.addCodeClient("reports")
+
+	code := env.authorize(t, "reports", "admin@example.com", "")
+	pair, err := env.oauth.ExchangeAuthorizationCode(CodeExchange{
+		Code: code, RedirectURI: testRedirectURI, ClientID: "reports", CodeVerifier: testCodeVerifier,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, pair.Scope, "клиент без областей не получает области роли администратора")
+	assert.Empty(t, pair.IDToken)
+	claims, err := env.tokens.ValidateAccessToken(pair.AccessToken)
+	require.NoError(t, err)
+	assert.Empty(t, claims.Scope)
+
+	refreshed, err := env.oauth.RefreshToken(pair.RefreshToken, "reports", "")
+	require.NoError(t, err)
+	assert.Empty(t, refreshed.Scope, "обновление не расширяет области")
+	claims, err = env.tokens.ValidateAccessToken(refreshed.AccessToken)
+	require.NoError(t, err)
+	assert.Empty(t, claims.Scope)
+}
+
+func TestOAuthUseCase_AuthorizationCodeSingleUse(t *testing.T) {
+	env := newTestEnv(t)
+	env.createUser(t, "user@example.com", "user")
+	env.addCodeClient("spa", "openid", "profile")
+
+	code := env.authorize(t, "spa", "user@example.com", "profile")
+	exchange := CodeExchange{Code: code, RedirectURI: testRedirectURI, ClientID: "spa", CodeVerifier: testCodeVerifier}
+	pair, err := env.oauth.ExchangeAuthorizationCode(exchange)
+	require.NoError(t, err)
+	assert.Equal(t, "profile", pair.Scope)
+
+	_, err = env.oauth.ExchangeAuthorizationCode(exchange)
+	assert.ErrorIs(t, err, models.ErrorInvalidGrant)
+	_, err = env.oauth.RefreshToken(pair.RefreshToken, "spa", "")
+	assert.ErrorIs(t, err, models.ErrorRefreshTokenRevoked, "повторное предъявление кода отзывает выданные по нему токены")
+}
+
+func TestOAuthUseCase_AuthorizationCodePKCE(t *testing.T) {
+	env := newTestEnv(t)
+	env.createUser(t, "user@example.com", "user")
+	env.addCodeClient("spa", "profile")
+	code := env.authorize(t, "spa", "user@example.com", "")
+
+	tests := []struct {
+		name     string
+		verifier string
+	}{
+		{"Missing verifier", ""},
+		{"Wrong verifier", "eBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"},
+		{"Challenge instead of verifier", auth.PKCEChallenge(testCodeVerifier)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := env.oauth.ExchangeAuthorizationCode(CodeExchange{
+				Code: code, RedirectURI: testRedirectURI, ClientID: "spa", CodeVerifier: tt.verifier,
+			})
+			assert.ErrorIs(t, err, models.ErrorInvalidGrant)
+		})
+	}
+
+	pair, err := env.oauth.ExchangeAuthorizationCode(CodeExchange{
+		Code: code, RedirectURI: testRedirectURI, ClientID: "spa", CodeVerifier: testCodeVerifier,
+	})
+	require.NoError(t, err, "неудачные попытки не расходуют код")
+	assert.Equal(t, "profile", pair.Scope)
+}
@@ -0,0 +1,254 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/internal/repository"
+	"github.com/fire9900/auth/pkg/auth"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SupportedGrantTypes - grant types, которые можно разрешить клиенту.
+var SupportedGrantTypes = []string{
+	models.GrantAuthorizationCode,
+	models.GrantRefreshToken,
+	models.GrantClientCredentials,
+	models.GrantDeviceCode,
+	models.GrantTokenExchange,
+}
+
+// ClientAuthenticator - аутентификация клиентов для эндпоинтов /oauth2/*.
+type ClientAuthenticator interface {
+	AuthenticateClient(clientID, clientSecret string) error
+	AuthenticatePublicClient(clientID string) error
+	ValidateRedirectURI(clientID, redirectURI string) error
+	GetClient(id string) (models.Client, error)
+}
+
+// Clients - управление зарегистрированными клиентами через API администратора.
+// Секрет клиента возвращается только при создании и смене секрета.
+type Clients interface {
+	GetAllClients() ([]models.Client, error)
+	GetClient(id string) (models.Client, error)
+	CreateClient(client models.Client) (models.Client, string, error)
+	UpdateClient(client models.Client) (models.Client, error)
+	DeleteClient(id string) error
+	RotateClientSecret(id string) (string, error)
+}
+
+type ClientUseCase struct {
+	repo repository.ClientRepository
+	now  func() time.Time
+}
+
+func NewClientUseCase(repo repository.ClientRepository) *ClientUseCase {
+	return &ClientUseCase{repo: repo, now: time.Now}
+}
+
+func (uc *ClientUseCase) GetAllClients() ([]models.Client, error) {
+	return uc.repo.GetAll()
+}
+
+func (uc *ClientUseCase) GetClient(id string) (models.Client, error) {
+	return uc.repo.GetByID(id)
+}
+
+// CreateClient регистрирует клиента. Пустой client_id генерируется.
+// Конфиденциальному клиенту выдается секрет, в базе хранится только его хеш.
+func (uc *ClientUseCase) CreateClient(client models.Client) (models.Client, string, error) {
+	if err := validateClient(client); err != nil {
+		return models.Client{}, "", err
+	}
+
+	if client.ID == "" {
+		id, err := auth.NewTokenID()
+		if err != nil {
+			return models.Client{}, "", fmt.Errorf("ошибка генерации client_id: %w", err)
+		}
+		client.ID = id
+	}
+
+	var secret string
+	client.SecretHash = ""
+	if !client.Public {
+		var err error
+		if secret, err = newClientSecret(); err != nil {
+			return models.Client{}, "", err
+		}
+		client.SecretHash = auth.HashToken(secret)
+	}
+
+	client.CreatedAt = uc.now()
+	client.UpdatedAt = client.CreatedAt
+	created, err := uc.repo.Create(client)
+	if err != nil {
+		return models.Client{}, "", err
+	}
+	return created, secret, nil
+}
+
+// UpdateClient меняет настройки клиента. Секрет не меняется: клиент,
+// ставший публичным, перестает его принимать, а ставшему конфиденциальным
+// нужно выдать секрет через RotateClientSecret.
+func (uc *ClientUseCase) UpdateClient(client models.Client) (models.Client, error) {
+	if err := validateClient(client); err != nil {
+		return models.Client{}, err
+	}
+	client.UpdatedAt = uc.now()
+	return uc.repo.Update(client)
+}
+
+func (uc *ClientUseCase) DeleteClient(id string) error {
+	return uc.repo.Delete(id)
+}
+
+func (uc *ClientUseCase) RotateClientSecret(id string) (string, error) {
+	client, err := uc.repo.GetByID(id)
+	if err != nil {
+		return "", err
+	}
+	if client.Public {
+		return "", fmt.Errorf("%w: у публичного клиента нет секрета", models.ErrorInvalidClientMetadata)
+	}
+
+	secret, err := newClientSecret()
+	if err != nil {
+		return "", err
+	}
+	if err := uc.repo.UpdateSecret(id, auth.HashToken(secret)); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// SeedClients регистрирует клиентов из конфигурации, которых еще нет в базе.
+// Такие клиенты не получают ни одного grant type и могут только вызывать
+// интроспекцию и отзыв токенов.
+func (uc *ClientUseCase) SeedClients(secrets map[string]string) error {
+	for id, secret := range secrets {
+		now := uc.now()
+		_, err := uc.repo.Create(models.Client{
+			ID:         id,
+			SecretHash: auth.HashToken(secret),
+			Name:       id,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		})
+		if err != nil && !errors.Is(err, models.ErrorClientExists) {
+			return err
+		}
+	}
+	return nil
+}
+
+// AuthenticateClient сравнивает хеш секрета за постоянное время.
+func (uc *ClientUseCase) AuthenticateClient(clientID, clientSecret string) error {
+	client, err := uc.lookup(clientID)
+	if err != nil {
+		return err
+	}
+	if client.Public || client.SecretHash == "" || clientSecret == "" {
+		return models.ErrorInvalidClient
+	}
+	if subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(auth.HashToken(clientSecret))) != 1 {
+		return models.ErrorInvalidClient
+	}
+	return nil
+}
+
+// AuthenticatePublicClient принимает только публичных клиентов: клиент
+// с секретом обязан аутентифицироваться секретом.
+func (uc *ClientUseCase) AuthenticatePublicClient(clientID string) error {
+	client, err := uc.lookup(clientID)
+	if err != nil {
+		return err
+	}
+	if !client.Public {
+		return models.ErrorInvalidClient
+	}
+	return nil
+}
+
+// ValidateRedirectURI требует точного совпадения с зарегистрированным URI.
+func (uc *ClientUseCase) ValidateRedirectURI(clientID, redirectURI string) error {
+	client, err := uc.lookup(clientID)
+	if err != nil {
+		return err
+	}
+	if redirectURI == "" || !slices.Contains(client.RedirectURIs, redirectURI) {
+		return models.ErrorInvalidRedirectURI
+	}
+	return nil
+}
+
+// lookup находит клиента для аутентификации. Неизвестный клиент
+// неотличим от неверного секрета.
+func (uc *ClientUseCase) lookup(clientID string) (models.Client, error) {
+	if clientID == "" {
+		return models.Client{}, models.ErrorInvalidClient
+	}
+	client, err := uc.repo.GetByID(clientID)
+	if errors.Is(err, models.ErrorClientNotFound) {
+		return models.Client{}, models.ErrorInvalidClient
+	}
+	return client, err
+}
+
+func validateClient(client models.Client) error {
+	for _, grantType := range client.GrantTypes {
+		if !slices.Contains(SupportedGrantTypes, grantType) {
+			return fmt.Errorf("%w: неподдерживаемый grant type %q", models.ErrorInvalidClientMetadata, grantType)
+		}
+	}
+	if client.Public && client.AllowsGrant(models.GrantClientCredentials) {
+		return fmt.Errorf("%w: публичному клиенту нельзя разрешить client_credentials", models.ErrorInvalidClientMetadata)
+	}
+	if client.Public && client.AllowsGrant(models.GrantTokenExchange) {
+		return fmt.Errorf("%w: публичному клиенту нельзя разрешить обмен токенов", models.ErrorInvalidClientMetadata)
+	}
+	if client.AllowsGrant(models.GrantTokenExchange) && len(client.ExchangeAudiences) == 0 {
+		return fmt.Errorf("%w: для обмена токенов нужна хотя бы одна аудитория", models.ErrorInvalidClientMetadata)
+	}
+	if client.AllowsGrant(models.GrantAuthorizationCode) && len(client.RedirectURIs) == 0 {
+		return fmt.Errorf("%w: для authorization_code нужен хотя бы один redirect URI", models.ErrorInvalidClientMetadata)
+	}
+
+	// RFC 6749, 3.1.2: redirect URI - абсолютный URI без фрагмента.
+	for _, uri := range client.RedirectURIs {
+		parsed, err := url.Parse(uri)
+		if err != nil || parsed.Scheme == "" || parsed.Fragment != "" || strings.ContainsAny(uri, " \t\n") {
+			return fmt.Errorf("%w: недопустимый redirect URI %q", models.ErrorInvalidClientMetadata, uri)
+		}
+	}
+	for _, scope := range client.Scopes {
+		if scope == "" || strings.ContainsAny(scope, " \t\n") {
+			return fmt.Errorf("%w: недопустимая область доступа %q", models.ErrorInvalidClientMetadata, scope)
+		}
+	}
+	for _, audience := range slices.Concat(client.ExchangeAudiences, client.ExchangeSubjectAudiences) {
+		if audience == "" || strings.ContainsAny(audience, " \t\n") {
+			return fmt.Errorf("%w: недопустимая аудитория %q", models.ErrorInvalidClientMetadata, audience)
+		}
+	}
+	if client.AccessTokenTTL < 0 || client.RefreshTokenTTL < 0 || client.RememberMeTTL < 0 {
+		return fmt.Errorf("%w: срок жизни токена не может быть отрицательным", models.ErrorInvalidClientMetadata)
+	}
+	return nil
+}
+
+// newClientSecret генерирует секрет из 256 случайных бит. Энтропии достаточно,
+// чтобы хранить его как SHA-256 без медленной функции хеширования паролей.
+func newClientSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("ошибка генерации секрета клиента: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/fire9900/auth/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientUseCase_AuthenticateClient(t *testing.T) {
+	env := newTestEnv(t)
+	clients := NewClientUseCase(env.clients)
+
+	created, secret, err := clients.CreateClient(models.Client{ID: "billing", GrantTypes: []string{models.GrantClientCredentials}})
+	require.NoError(t, err)
+	require.NotEmpty(t, secret)
+	assert.NotEqual(t, secret, env.clients.clients[created.ID].SecretHash, "в базе хранится только хеш секрета")
+	_, public, err := clients.CreateClient(models.Client{ID: "spa", Public: true, GrantTypes: []string{models.GrantAuthorizationCode},
+		RedirectURIs: []string{testRedirectURI}})
+	require.NoError(t, err)
+	assert.Empty(t, public, "публичному клиенту секрет не выдается")
+
+	tests := []struct {
+		name     string
+		clientID string
+		secret   string
+		wantErr  error
+	}{
+		{"Valid secret", "billing", secret, nil},
+		{"Wrong secret", "billing", secret + "x", models.ErrorInvalidClient},
+		{"Empty secret", "billing", "", models.ErrorInvalidClient},
+		{"Unknown client", "unknown", secret, models.ErrorInvalidClient},
+		{"Empty client_id", "", secret, models.ErrorInvalidClient},
+		{"Public client", "spa", "", models.ErrorInvalidClient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := clients.AuthenticateClient(tt.clientID, tt.secret)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+
+	t.Run("Rotated secret", func(t *testing.T) {
+		rotated, err := clients.RotateClientSecret("billing")
+		require.NoError(t, err)
+		assert.ErrorIs(t, clients.AuthenticateClient("billing", secret), models.ErrorInvalidClient)
+		assert.NoError(t, clients.AuthenticateClient("billing", rotated))
+	})
+
+	t.Run("Public client authentication", func(t *testing.T) {
+		assert.NoError(t, clients.AuthenticatePublicClient("spa"))
+		assert.ErrorIs(t, clients.AuthenticatePublicClient("billing"), models.ErrorInvalidClient, "клиент с секретом обязан предъявить секрет")
+	})
+}
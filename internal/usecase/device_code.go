@@ -0,0 +1,252 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/fire9900/auth/pkg/logger"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+const (
+	// DeviceCodeTTL - срок, за который пользователь должен подтвердить вход.
+	DeviceCodeTTL = 10 * time.Minute
+	// DevicePollInterval - начальный интервал опроса (RFC 8628, 3.2),
+	// увеличивается на DevicePollInterval при каждом slow_down.
+	DevicePollInterval = 5
+
+	// userCodeAlphabet - согласные без гласных и похожих символов (RFC 8628, 6.1):
+	// код легко ввести, и из него не складываются слова.
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+	userCodeAttempts = 5
+)
+
+// DeviceAuthorization - ответ эндпоинта авторизации устройства (RFC 8628, 3.2).
+type DeviceAuthorization struct {
+	DeviceCode string
+	UserCode   string
+	ExpiresIn  int64
+	Interval   int
+}
+
+// AuthorizeDevice начинает авторизацию устройства клиента clientID. Без scope
+// запрашиваются все области, разрешенные клиенту, у клиента без областей - ни одной.
+func (uc *OAuthUseCase) AuthorizeDevice(clientID, scope string) (DeviceAuthorization, error) {
+	client, err := uc.client(clientID)
+	if err != nil {
+		return DeviceAuthorization{}, err
+	}
+	if !client.AllowsGrant(models.GrantDeviceCode) {
+		return DeviceAuthorization{}, models.ErrorUnauthorizedClient
+	}
+	for _, requested := range strings.Fields(scope) {
+		if !client.AllowsScope(requested) {
+			return DeviceAuthorization{}, models.ErrorInvalidScope
+		}
+	}
+	if scope == "" {
+		scope = strings.Join(client.Scopes, " ")
+	}
+
+	deviceCode, err := auth.NewTokenID()
+	if err != nil {
+		return DeviceAuthorization{}, fmt.Errorf("ошибка генерации кода устройства: %w", err)
+	}
+
+	now := uc.tokens.now()
+	for attempt := 0; attempt < userCodeAttempts; attempt++ {
+		userCode, err := newUserCode()
+		if err != nil {
+			return DeviceAuthorization{}, err
+		}
+
+		err = uc.devices.Create(models.DeviceCode{
+			DeviceCodeHash: auth.HashToken(deviceCode),
+			UserCode:       userCode,
+			ClientID:       clientID,
+			Scope:          scope,
+			Status:         models.DeviceStatusPending,
+			Interval:       DevicePollInterval,
+			ExpiresAt:      now.Add(DeviceCodeTTL),
+			CreatedAt:      now,
+		})
+		if errors.Is(err, models.ErrorUserCodeExists) {
+			continue
+		}
+		if err != nil {
+			return DeviceAuthorization{}, err
+		}
+
+		return DeviceAuthorization{
+			DeviceCode: deviceCode,
+			UserCode:   FormatUserCode(userCode),
+			ExpiresIn:  int64(DeviceCodeTTL.Seconds()),
+			Interval:   DevicePollInterval,
+		}, nil
+	}
+	return DeviceAuthorization{}, models.ErrorUserCodeExists
+}
+
+// PendingDevice возвращает ожидающий подтверждения запрос по коду,
+// который пользователь ввел на странице /device.
+func (uc *OAuthUseCase) PendingDevice(userCode string) (models.DeviceCode, models.Client, error) {
+	code, err := uc.devices.GetByUserCode(NormalizeUserCode(userCode))
+	if err != nil {
+		if errors.Is(err, models.ErrorDeviceCodeNotFound) {
+			return models.DeviceCode{}, models.Client{}, models.ErrorUserCodeInvalid
+		}
+		return models.DeviceCode{}, models.Client{}, err
+	}
+	if code.Status != models.DeviceStatusPending || !uc.tokens.now().Before(code.ExpiresAt) {
+		return models.DeviceCode{}, models.Client{}, models.ErrorUserCodeInvalid
+	}
+
+	client, err := uc.client(code.ClientID)
+	if err != nil {
+		return models.DeviceCode{}, models.Client{}, models.ErrorUserCodeInvalid
+	}
+	return code, client, nil
+}
+
+// DecideDevice фиксирует решение пользователя браузерной сессии session по запросу userCode.
+func (uc *OAuthUseCase) DecideDevice(userCode string, session models.Session, approve bool) error {
+	code, _, err := uc.PendingDevice(userCode)
+	if err != nil {
+		return err
+	}
+
+	status := models.DeviceStatusDenied
+	if approve {
+		status = models.DeviceStatusApproved
+	}
+	changed, err := uc.devices.SetStatus(code.DeviceCodeHash, models.DeviceStatusPending, status, session.UserID)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return models.ErrorUserCodeInvalid
+	}
+	return nil
+}
+
+// DeviceToken обрабатывает опрос эндпоинта токенов устройством (RFC 8628, 3.5).
+// Опрос чаще текущего интервала увеличивает интервал и возвращает slow_down.
+// Непустой jkt привязывает токены к ключу DPoP устройства.
+func (uc *OAuthUseCase) DeviceToken(deviceCode, clientID, jkt string) (TokenPair, error) {
+	client, err := uc.client(clientID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !client.AllowsGrant(models.GrantDeviceCode) {
+		return TokenPair{}, models.ErrorUnauthorizedClient
+	}
+
+	code, err := uc.devices.GetByDeviceCodeHash(auth.HashToken(deviceCode))
+	if err != nil {
+		if errors.Is(err, models.ErrorDeviceCodeNotFound) {
+			return TokenPair{}, models.ErrorInvalidGrant
+		}
+		return TokenPair{}, err
+	}
+	if code.ClientID != clientID {
+		return TokenPair{}, models.ErrorInvalidGrant
+	}
+
+	now := uc.tokens.now()
+	if !now.Before(code.ExpiresAt) {
+		return TokenPair{}, models.ErrorExpiredToken
+	}
+
+	interval := code.Interval
+	tooFast := code.LastPolledAt != nil && now.Sub(*code.LastPolledAt) < time.Duration(interval)*time.Second
+	if tooFast {
+		interval += DevicePollInterval
+	}
+	if err := uc.devices.RecordPoll(code.DeviceCodeHash, now, interval); err != nil {
+		return TokenPair{}, err
+	}
+	if tooFast {
+		return TokenPair{}, models.ErrorSlowDown
+	}
+
+	switch code.Status {
+	case models.DeviceStatusPending:
+		return TokenPair{}, models.ErrorAuthorizationPending
+	case models.DeviceStatusDenied:
+		return TokenPair{}, models.ErrorAccessDenied
+	case models.DeviceStatusApproved:
+	default:
+		return TokenPair{}, models.ErrorInvalidGrant
+	}
+
+	used, err := uc.devices.SetStatus(code.DeviceCodeHash, models.DeviceStatusApproved, models.DeviceStatusUsed, code.UserID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !used {
+		return TokenPair{}, models.ErrorInvalidGrant
+	}
+
+	user, err := uc.users.GetByID(code.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrorUserNotFound) {
+			return TokenPair{}, models.ErrorInvalidGrant
+		}
+		return TokenPair{}, err
+	}
+
+	logger.Logger.Info("Устройство авторизовано",
+		zap.String("client_id", clientID),
+		zap.Int("user_id", user.ID))
+	pair, err := uc.tokens.IssuePair(user, IssueOptions{
+		ClientID: clientID,
+		Scopes:   scopesOf(code.Scope),
+		JKT:      jkt,
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !client.AllowsGrant(models.GrantRefreshToken) {
+		pair.RefreshToken = ""
+	}
+	return pair, nil
+}
+
+func newUserCode() (string, error) {
+	buf := make([]byte, userCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("ошибка генерации пользовательского кода: %w", err)
+	}
+	code := make([]byte, userCodeLength)
+	for i, b := range buf {
+		// 256 делится на 20 с остатком, но смещение распределения
+		// пренебрежимо мало для кода, живущего 10 минут.
+		code[i] = userCodeAlphabet[int(b)%len(userCodeAlphabet)]
+	}
+	return string(code), nil
+}
+
+// FormatUserCode показывает код группами по четыре символа: BCDF-GHJK.
+func FormatUserCode(code string) string {
+	if len(code) != userCodeLength {
+		return code
+	}
+	return code[:4] + "-" + code[4:]
+}
+
+// NormalizeUserCode приводит введенный пользователем код к хранимому виду:
+// регистр и разделители не важны (RFC 8628, 6.1).
+func NormalizeUserCode(input string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(input) {
+		if strings.ContainsRune(userCodeAlphabet, r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,122 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (env *testEnv) addDeviceClient(id string, scopes ...string) {
+	env.clients.clients[id] = models.Client{
+		ID:         id,
+		Public:     true,
+		GrantTypes: []string{models.GrantDeviceCode, models.GrantRefreshToken},
+		Scopes:     scopes,
+	}
+}
+
+// decideDevice подтверждает или отклоняет запрос устройства от имени пользователя email.
+func (env *testEnv) decideDevice(t *testing.T, userCode, email string, approve bool) {
+	require.NoError(t, env.oauth.DecideDevice(userCode, env.browserSession(t, email), approve))
+}
+
+// setClock останавливает часы сервиса на now.
+func (env *testEnv) setClock(now *time.Time) {
+	env.tokens.now = func() time.Time { return *now }
+}
+
+func TestOAuthUseCase_DeviceScopes(t *testing.T) {
+	env := newTestEnv(t)
+	env.createUser(t, "admin@example.com", "admin")
+	env.addDeviceClient("tv")
+
+	device, err := env.oauth.AuthorizeDevice("tv", "")
+	require.NoError(t, err)
+	env.decideDevice(t, device.UserCode, "admin@example.com", true)
+
+	pair, err := env.oauth.DeviceToken(device.DeviceCode, "tv", "")
+	require.NoError(t, err)
+	assert.Empty(t, pair.Scope, "подтверждение администратором не добавляет областей его роли")
+	claims, err := env.tokens.ValidateAccessToken(pair.AccessToken)
+	require.NoError(t, err)
+	assert.Empty(t, claims.Scope)
+
+	refreshed, err := env.oauth.RefreshToken(pair.RefreshToken, "tv", "")
+	require.NoError(t, err)
+	assert.Empty(t, refreshed.Scope)
+}
+
+func TestOAuthUseCase_DevicePolling(t *testing.T) {
+	env := newTestEnv(t)
+	env.createUser(t, "user@example.com", "user")
+	env.addDeviceClient("tv", "profile")
+	now := time.Now()
+	env.setClock(&now)
+
+	device, err := env.oauth.AuthorizeDevice("tv", "")
+	require.NoError(t, err)
+	assert.Equal(t, DevicePollInterval, device.Interval)
+	hash := auth.HashToken(device.DeviceCode)
+
+	_, err = env.oauth.DeviceToken(device.DeviceCode, "tv", "")
+	assert.ErrorIs(t, err, models.ErrorAuthorizationPending)
+
+	now = now.Add(time.Second)
+	_, err = env.oauth.DeviceToken(device.DeviceCode, "tv", "")
+	assert.ErrorIs(t, err, models.ErrorSlowDown)
+	assert.Equal(t, 2*DevicePollInterval, env.devices.codes[hash].Interval)
+
+	now = now.Add(2*DevicePollInterval*time.Second - time.Second)
+	_, err = env.oauth.DeviceToken(device.DeviceCode, "tv", "")
+	assert.ErrorIs(t, err, models.ErrorSlowDown, "опрос чаще увеличенного интервала")
+	assert.Equal(t, 3*DevicePollInterval, env.devices.codes[hash].Interval)
+
+	now = now.Add(3 * DevicePollInterval * time.Second)
+	_, err = env.oauth.DeviceToken(device.DeviceCode, "tv", "")
+	assert.ErrorIs(t, err, models.ErrorAuthorizationPending)
+
+	_, err = env.oauth.DeviceToken(device.DeviceCode, "spa", "")
+	assert.ErrorIs(t, err, models.ErrorInvalidClient)
+	env.addDeviceClient("other")
+	now = now.Add(3 * DevicePollInterval * time.Second)
+	_, err = env.oauth.DeviceToken(device.DeviceCode, "other", "")
+	assert.ErrorIs(t, err, models.ErrorInvalidGrant, "код выдан другому клиенту")
+
+	env.decideDevice(t, device.UserCode, "user@example.com", true)
+	now = now.Add(3 * DevicePollInterval * time.Second)
+	pair, err := env.oauth.DeviceToken(device.DeviceCode, "tv", "")
+	require.NoError(t, err)
+	assert.Equal(t, "profile", pair.Scope)
+
+	now = now.Add(3 * DevicePollInterval * time.Second)
+	_, err = env.oauth.DeviceToken(device.DeviceCode, "tv", "")
+	assert.ErrorIs(t, err, models.ErrorInvalidGrant, "код устройства одноразовый")
+}
+
+func TestOAuthUseCase_DeviceDeniedAndExpired(t *testing.T) {
+	env := newTestEnv(t)
+	env.createUser(t, "user@example.com", "user")
+	env.addDeviceClient("tv", "profile")
+	now := time.Now()
+	env.setClock(&now)
+
+	denied, err := env.oauth.AuthorizeDevice("tv", "")
+	require.NoError(t, err)
+	env.decideDevice(t, denied.UserCode, "user@example.com", false)
+	_, err = env.oauth.DeviceToken(denied.DeviceCode, "tv", "")
+	assert.ErrorIs(t, err, models.ErrorAccessDenied)
+	_, _, err = env.oauth.PendingDevice(denied.UserCode)
+	assert.ErrorIs(t, err, models.ErrorUserCodeInvalid, "решение по запросу принимается один раз")
+
+	expired, err := env.oauth.AuthorizeDevice("tv", "")
+	require.NoError(t, err)
+	now = now.Add(DeviceCodeTTL)
+	_, _, err = env.oauth.PendingDevice(expired.UserCode)
+	assert.ErrorIs(t, err, models.ErrorUserCodeInvalid)
+	_, err = env.oauth.DeviceToken(expired.DeviceCode, "tv", "")
+	assert.ErrorIs(t, err, models.ErrorExpiredToken)
+}
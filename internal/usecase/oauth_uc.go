@@ -0,0 +1,106 @@
+package usecase
+
+import (
+	"errors"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/internal/repository"
+	"github.com/fire9900/auth/pkg/auth"
+	"strings"
+)
+
+// OAuth - операции стандартных эндпоинтов /oauth2/*.
+type OAuth interface {
+	AuthenticateClient(clientID, clientSecret string) error
+	AuthenticatePublicClient(clientID string) error
+	Introspect(token, tokenTypeHint string) (*auth.Claims, error)
+	Revoke(token, tokenTypeHint, clientID string) error
+	ValidateAuthorizationRequest(req AuthorizationRequest) (models.Client, error)
+	IssueAuthorizationCode(req AuthorizationRequest, session models.Session) (string, error)
+	ExchangeAuthorizationCode(exchange CodeExchange) (TokenPair, error)
+	RefreshToken(refreshToken, clientID, jkt string) (TokenPair, error)
+	ClientCredentials(clientID, scope, jkt string) (TokenPair, error)
+	AuthorizeDevice(clientID, scope string) (DeviceAuthorization, error)
+	PendingDevice(userCode string) (models.DeviceCode, models.Client, error)
+	DecideDevice(userCode string, session models.Session, approve bool) error
+	DeviceToken(deviceCode, clientID, jkt string) (TokenPair, error)
+	ExchangeToken(req TokenExchangeRequest) (TokenPair, error)
+}
+
+type OAuthUseCase struct {
+	clients ClientAuthenticator
+	codes   repository.AuthorizationCodeRepository
+	devices repository.DeviceCodeRepository
+	users   repository.UserRepository
+	tokens  *TokenUseCase
+}
+
+func NewOAuthUseCase(clients ClientAuthenticator, codes repository.AuthorizationCodeRepository,
+	devices repository.DeviceCodeRepository, users repository.UserRepository, tokens *TokenUseCase) *OAuthUseCase {
+	return &OAuthUseCase{clients: clients, codes: codes, devices: devices, users: users, tokens: tokens}
+}
+
+func (uc *OAuthUseCase) AuthenticateClient(clientID, clientSecret string) error {
+	return uc.clients.AuthenticateClient(clientID, clientSecret)
+}
+
+func (uc *OAuthUseCase) AuthenticatePublicClient(clientID string) error {
+	return uc.clients.AuthenticatePublicClient(clientID)
+}
+
+// Introspect возвращает claims активного токена. Тип токена определяется по
+// самому токену, поэтому token_type_hint (RFC 7662, 2.1) не влияет на результат.
+func (uc *OAuthUseCase) Introspect(token, tokenTypeHint string) (*auth.Claims, error) {
+	return uc.tokens.Introspect(token)
+}
+
+// Revoke отзывает токен клиента clientID. Как и при интроспекции,
+// тип токена определяется по самому токену, а token_type_hint не используется.
+func (uc *OAuthUseCase) Revoke(token, tokenTypeHint, clientID string) error {
+	return uc.tokens.RevokeToken(token, clientID)
+}
+
+// RefreshToken обменивает refresh токен клиента на новую пару (grant_type=refresh_token).
+// jkt - отпечаток ключа DPoP запроса, пустой для Bearer токенов.
+func (uc *OAuthUseCase) RefreshToken(refreshToken, clientID, jkt string) (TokenPair, error) {
+	client, err := uc.client(clientID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !client.AllowsGrant(models.GrantRefreshToken) {
+		return TokenPair{}, models.ErrorUnauthorizedClient
+	}
+	return uc.tokens.RefreshForClient(refreshToken, clientID, jkt)
+}
+
+// ClientCredentials выдает клиенту токен от его собственного имени.
+// Запрошенные области должны быть разрешены клиенту; без scope
+// выдаются все его области. Непустой jkt привязывает токен к ключу DPoP.
+func (uc *OAuthUseCase) ClientCredentials(clientID, scope, jkt string) (TokenPair, error) {
+	client, err := uc.client(clientID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if client.Public || !client.AllowsGrant(models.GrantClientCredentials) {
+		return TokenPair{}, models.ErrorUnauthorizedClient
+	}
+	for _, requested := range strings.Fields(scope) {
+		if !client.AllowsScope(requested) {
+			return TokenPair{}, models.ErrorInvalidScope
+		}
+	}
+	var requested []string
+	if scope != "" {
+		requested = scopesOf(scope)
+	}
+	return uc.tokens.IssueClientToken(client, requested, jkt)
+}
+
+// client возвращает уже аутентифицированного клиента. Клиент, удаленный
+// между аутентификацией и выдачей токенов, считается неверным.
+func (uc *OAuthUseCase) client(clientID string) (models.Client, error) {
+	client, err := uc.clients.GetClient(clientID)
+	if errors.Is(err, models.ErrorClientNotFound) {
+		return models.Client{}, models.ErrorInvalidClient
+	}
+	return client, err
+}
@@ -0,0 +1,162 @@
+package usecase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/internal/repository"
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/fire9900/auth/pkg/logger"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// DefaultOpaqueCacheTTL - сколько найденные claims непрозрачного токена
+// живут в кеше. Отзыв через этот экземпляр сервиса виден сразу, а удаление
+// токена другим экземпляром - не позже чем через это время.
+const DefaultOpaqueCacheTTL = 30 * time.Second
+
+type cachedClaims struct {
+	claims auth.Claims
+	until  time.Time
+}
+
+// OpaqueTokenStore хранит claims непрозрачных токенов в базе, кеширует их
+// в памяти и реализует auth.TokenStore для Signer и Verifier.
+type OpaqueTokenStore struct {
+	repo     repository.OpaqueTokenRepository
+	cacheTTL time.Duration
+	mu       sync.RWMutex
+	cache    map[string]cachedClaims
+	now      func() time.Time
+}
+
+func NewOpaqueTokenStore(repo repository.OpaqueTokenRepository, cacheTTL time.Duration) *OpaqueTokenStore {
+	if cacheTTL <= 0 {
+		cacheTTL = DefaultOpaqueCacheTTL
+	}
+	return &OpaqueTokenStore{
+		repo:     repo,
+		cacheTTL: cacheTTL,
+		cache:    make(map[string]cachedClaims),
+		now:      time.Now,
+	}
+}
+
+func (s *OpaqueTokenStore) StoreToken(claims *auth.Claims) (string, error) {
+	token, err := auth.NewOpaqueToken(claims.TokenType)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("ошибка сериализации claims: %w", err)
+	}
+
+	hash := auth.HashToken(token)
+	err = s.repo.Create(models.OpaqueToken{
+		TokenHash: hash,
+		JTI:       claims.ID,
+		TokenType: claims.TokenType,
+		UserID:    claims.UserID,
+		ClientID:  claims.ClientID,
+		SessionID: claims.SessionID,
+		Claims:    string(data),
+		ExpiresAt: claims.ExpiresAt.Time,
+		CreatedAt: s.now(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.remember(hash, *claims)
+	return token, nil
+}
+
+func (s *OpaqueTokenStore) ResolveToken(token string) (*auth.Claims, error) {
+	hash := auth.HashToken(token)
+
+	s.mu.RLock()
+	cached, ok := s.cache[hash]
+	s.mu.RUnlock()
+	if ok && s.now().Before(cached.until) {
+		claims := cached.claims
+		return &claims, nil
+	}
+
+	stored, err := s.repo.GetByHash(hash)
+	if err != nil {
+		if errors.Is(err, models.ErrorOpaqueTokenNotFound) {
+			s.forget(hash)
+			return nil, auth.ErrorInvalidToken
+		}
+		return nil, err
+	}
+
+	var claims auth.Claims
+	if err := json.Unmarshal([]byte(stored.Claims), &claims); err != nil {
+		logger.Logger.Error("Поврежденные claims непрозрачного токена",
+			zap.String("jti", stored.JTI),
+			zap.Error(err))
+		return nil, auth.ErrorInvalidToken
+	}
+
+	s.remember(hash, claims)
+	return &claims, nil
+}
+
+// RevokeSession удаляет все токены сессии из базы и из кеша.
+func (s *OpaqueTokenStore) RevokeSession(sessionID string) error {
+	if _, err := s.repo.DeleteBySession(sessionID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	for hash, cached := range s.cache {
+		if cached.claims.SessionID == sessionID {
+			delete(s.cache, hash)
+		}
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// Cleanup удаляет из кеша и базы истекшие токены.
+func (s *OpaqueTokenStore) Cleanup() {
+	now := s.now()
+
+	s.mu.Lock()
+	for hash, cached := range s.cache {
+		if !now.Before(cached.until) {
+			delete(s.cache, hash)
+		}
+	}
+	s.mu.Unlock()
+
+	deleted, err := s.repo.DeleteExpired(now)
+	if err != nil {
+		return
+	}
+	logger.Logger.Debug("Очищены истекшие непрозрачные токены",
+		zap.Int64("количество", deleted))
+}
+
+// remember кеширует claims, но не дольше срока жизни самого токена.
+func (s *OpaqueTokenStore) remember(hash string, claims auth.Claims) {
+	until := s.now().Add(s.cacheTTL)
+	if claims.ExpiresAt != nil && claims.ExpiresAt.Before(until) {
+		until = claims.ExpiresAt.Time
+	}
+
+	s.mu.Lock()
+	s.cache[hash] = cachedClaims{claims: claims, until: until}
+	s.mu.Unlock()
+}
+
+func (s *OpaqueTokenStore) forget(hash string) {
+	s.mu.Lock()
+	delete(s.cache, hash)
+	s.mu.Unlock()
+}
@@ -0,0 +1,450 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/fire9900/auth/pkg/logger"
+	"github.com/fire9900/auth/pkg/password"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var testTokenConfig = auth.TokenConfig{Issuer: "https://auth.test", Audience: "api"}
+
+// Репозитории в памяти повторяют условия SQL запросов настоящих
+// репозиториев: одноразовые отметки (used_at, revoked_at) ставятся
+// только один раз, а ненайденные записи возвращают ошибки моделей.
+
+type mockUserRepository struct {
+	users map[int]models.User
+}
+
+func newMockUserRepository() *mockUserRepository {
+	return &mockUserRepository{users: make(map[int]models.User)}
+}
+
+func (r *mockUserRepository) GetAll() ([]models.User, error) {
+	users := []models.User{}
+	for id := 1; id <= len(r.users); id++ {
+		if user, ok := r.users[id]; ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (r *mockUserRepository) GetByID(id int) (models.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return models.User{}, models.ErrorUserNotFound
+	}
+	return user, nil
+}
+
+func (r *mockUserRepository) GetByEmail(email string) (models.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return models.User{}, models.ErrorUserNotFound
+}
+
+func (r *mockUserRepository) Create(user models.User) (models.User, error) {
+	user.ID = len(r.users) + 1
+	if user.Role == "" {
+		user.Role = "user"
+	}
+	r.users[user.ID] = user
+	return user, nil
+}
+
+func (r *mockUserRepository) Update(id int, user models.User) (models.User, error) {
+	stored, ok := r.users[id]
+	if !ok {
+		return models.User{}, models.ErrorUserNotFound
+	}
+	stored.Name, stored.Email, stored.Password = user.Name, user.Email, user.Password
+	r.users[id] = stored
+	return stored, nil
+}
+
+func (r *mockUserRepository) Delete(id int) error {
+	if _, ok := r.users[id]; !ok {
+		return models.ErrorUserNotFound
+	}
+	delete(r.users, id)
+	return nil
+}
+
+func (r *mockUserRepository) CheckPassword(id int, password string) bool {
+	user, ok := r.users[id]
+	return ok && user.VerifyPassword(password)
+}
+
+func (r *mockUserRepository) GetTokenVersion(id int) (int, error) {
+	user, err := r.GetByID(id)
+	return user.TokenVersion, err
+}
+
+func (r *mockUserRepository) BumpTokenVersion(id int) (int, error) {
+	user, err := r.GetByID(id)
+	if err != nil {
+		return 0, err
+	}
+	user.TokenVersion++
+	r.users[id] = user
+	return user.TokenVersion, nil
+}
+
+func (r *mockUserRepository) Suspend(id int) error {
+	user, err := r.GetByID(id)
+	if err != nil {
+		return err
+	}
+	user.Suspended = true
+	r.users[id] = user
+	return nil
+}
+
+func (r *mockUserRepository) UpdatePasswordHash(id int, hash string) error {
+	user, err := r.GetByID(id)
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+	r.users[id] = user
+	return nil
+}
+
+type mockRefreshTokenRepository struct {
+	tokens []models.RefreshToken
+}
+
+func (r *mockRefreshTokenRepository) Create(token models.RefreshToken) (models.RefreshToken, error) {
+	token.ID = len(r.tokens) + 1
+	r.tokens = append(r.tokens, token)
+	return token, nil
+}
+
+func (r *mockRefreshTokenRepository) GetByHash(hash string) (models.RefreshToken, error) {
+	for _, token := range r.tokens {
+		if token.TokenHash == hash {
+			return token, nil
+		}
+	}
+	return models.RefreshToken{}, models.ErrorRefreshTokenNotFound
+}
+
+func (r *mockRefreshTokenRepository) MarkUsed(id int, usedAt time.Time) (bool, error) {
+	token := &r.tokens[id-1]
+	if token.UsedAt != nil {
+		return false, nil
+	}
+	token.UsedAt = &usedAt
+	return true, nil
+}
+
+func (r *mockRefreshTokenRepository) RevokeFamily(familyID string, revokedAt time.Time) error {
+	for i := range r.tokens {
+		if r.tokens[i].FamilyID == familyID && r.tokens[i].RevokedAt == nil {
+			r.tokens[i].RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
+
+type mockSessionRepository struct {
+	sessions map[string]models.Session
+}
+
+func newMockSessionRepository() *mockSessionRepository {
+	return &mockSessionRepository{sessions: make(map[string]models.Session)}
+}
+
+func (r *mockSessionRepository) Create(session models.Session) error {
+	r.sessions[session.ID] = session
+	return nil
+}
+
+func (r *mockSessionRepository) GetByID(id string) (models.Session, error) {
+	session, ok := r.sessions[id]
+	if !ok {
+		return models.Session{}, models.ErrorSessionNotFound
+	}
+	return session, nil
+}
+
+func (r *mockSessionRepository) GetActiveByUser(userID int, now time.Time) ([]models.Session, error) {
+	sessions := []models.Session{}
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil && session.ExpiresAt.After(now) {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *mockSessionRepository) Touch(id string, lastSeenAt, expiresAt time.Time) error {
+	if session, ok := r.sessions[id]; ok && session.RevokedAt == nil {
+		session.LastSeenAt, session.ExpiresAt = lastSeenAt, expiresAt
+		r.sessions[id] = session
+	}
+	return nil
+}
+
+func (r *mockSessionRepository) Revoke(id string, revokedAt time.Time) error {
+	if session, ok := r.sessions[id]; ok && session.RevokedAt == nil {
+		session.RevokedAt = &revokedAt
+		r.sessions[id] = session
+	}
+	return nil
+}
+
+func (r *mockSessionRepository) RevokeByUser(userID int, revokedAt time.Time) error {
+	for id, session := range r.sessions {
+		if session.UserID == userID {
+			if err := r.Revoke(id, revokedAt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type mockRevokedTokenRepository struct {
+	tokens map[string]models.RevokedToken
+}
+
+func (r *mockRevokedTokenRepository) Revoke(token models.RevokedToken) error {
+	if _, ok := r.tokens[token.JTI]; !ok {
+		r.tokens[token.JTI] = token
+	}
+	return nil
+}
+
+func (r *mockRevokedTokenRepository) IsRevoked(jti string) (bool, error) {
+	_, ok := r.tokens[jti]
+	return ok, nil
+}
+
+func (r *mockRevokedTokenRepository) GetActive(now time.Time) ([]models.RevokedToken, error) {
+	tokens := []models.RevokedToken{}
+	for _, token := range r.tokens {
+		if token.ExpiresAt.After(now) {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+func (r *mockRevokedTokenRepository) DeleteExpired(now time.Time) (int64, error) {
+	var deleted int64
+	for jti, token := range r.tokens {
+		if !token.ExpiresAt.After(now) {
+			delete(r.tokens, jti)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+type mockClientRepository struct {
+	clients map[string]models.Client
+}
+
+func (r *mockClientRepository) GetAll() ([]models.Client, error) {
+	clients := []models.Client{}
+	for _, client := range r.clients {
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+func (r *mockClientRepository) GetByID(id string) (models.Client, error) {
+	client, ok := r.clients[id]
+	if !ok {
+		return models.Client{}, models.ErrorClientNotFound
+	}
+	return client, nil
+}
+
+func (r *mockClientRepository) Create(client models.Client) (models.Client, error) {
+	if _, ok := r.clients[client.ID]; ok {
+		return models.Client{}, models.ErrorClientExists
+	}
+	r.clients[client.ID] = client
+	return client, nil
+}
+
+func (r *mockClientRepository) Update(client models.Client) (models.Client, error) {
+	stored, ok := r.clients[client.ID]
+	if !ok {
+		return models.Client{}, models.ErrorClientNotFound
+	}
+	client.SecretHash = stored.SecretHash
+	r.clients[client.ID] = client
+	return client, nil
+}
+
+func (r *mockClientRepository) UpdateSecret(id, secretHash string) error {
+	client, ok := r.clients[id]
+	if !ok {
+		return models.ErrorClientNotFound
+	}
+	client.SecretHash = secretHash
+	r.clients[id] = client
+	return nil
+}
+
+func (r *mockClientRepository) Delete(id string) error {
+	if _, ok := r.clients[id]; !ok {
+		return models.ErrorClientNotFound
+	}
+	delete(r.clients, id)
+	return nil
+}
+
+type mockAuthorizationCodeRepository struct {
+	codes map[string]models.AuthorizationCode
+}
+
+func (r *mockAuthorizationCodeRepository) Create(code models.AuthorizationCode) error {
+	r.codes[code.CodeHash] = code
+	return nil
+}
+
+func (r *mockAuthorizationCodeRepository) GetByHash(hash string) (models.AuthorizationCode, error) {
+	code, ok := r.codes[hash]
+	if !ok {
+		return models.AuthorizationCode{}, models.ErrorAuthorizationCodeNotFound
+	}
+	return code, nil
+}
+
+func (r *mockAuthorizationCodeRepository) MarkUsed(hash string, usedAt time.Time) (bool, error) {
+	code, ok := r.codes[hash]
+	if !ok || code.UsedAt != nil {
+		return false, nil
+	}
+	code.UsedAt = &usedAt
+	r.codes[hash] = code
+	return true, nil
+}
+
+type mockDeviceCodeRepository struct {
+	codes map[string]models.DeviceCode
+}
+
+func (r *mockDeviceCodeRepository) Create(code models.DeviceCode) error {
+	for _, stored := range r.codes {
+		if stored.UserCode == code.UserCode {
+			return models.ErrorUserCodeExists
+		}
+	}
+	r.codes[code.DeviceCodeHash] = code
+	return nil
+}
+
+func (r *mockDeviceCodeRepository) GetByDeviceCodeHash(hash string) (models.DeviceCode, error) {
+	code, ok := r.codes[hash]
+	if !ok {
+		return models.DeviceCode{}, models.ErrorDeviceCodeNotFound
+	}
+	return code, nil
+}
+
+func (r *mockDeviceCodeRepository) GetByUserCode(userCode string) (models.DeviceCode, error) {
+	for _, code := range r.codes {
+		if code.UserCode == userCode {
+			return code, nil
+		}
+	}
+	return models.DeviceCode{}, models.ErrorDeviceCodeNotFound
+}
+
+func (r *mockDeviceCodeRepository) RecordPoll(hash string, polledAt time.Time, interval int) error {
+	if code, ok := r.codes[hash]; ok {
+		code.LastPolledAt, code.Interval = &polledAt, interval
+		r.codes[hash] = code
+	}
+	return nil
+}
+
+func (r *mockDeviceCodeRepository) SetStatus(hash, from, to string, userID int) (bool, error) {
+	code, ok := r.codes[hash]
+	if !ok || code.Status != from {
+		return false, nil
+	}
+	code.Status, code.UserID = to, userID
+	r.codes[hash] = code
+	return true, nil
+}
+
+// testEnv - сценарии использования поверх репозиториев в памяти
+// и связки из одного ключа HS256.
+type testEnv struct {
+	users    *mockUserRepository
+	refresh  *mockRefreshTokenRepository
+	sessions *mockSessionRepository
+	clients  *mockClientRepository
+	codes    *mockAuthorizationCodeRepository
+	devices  *mockDeviceCodeRepository
+	signer   *auth.Signer
+	tokens   *TokenUseCase
+	userUC   *UserUseCase
+	oauth    *OAuthUseCase
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	logger.Logger = zap.NewNop()
+	// Минимальные параметры Argon2id, чтобы хеширование не замедляло тесты.
+	models.PasswordHasher = password.NewHashers(password.NewArgon2id(password.Argon2Params{
+		Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 16,
+	}))
+
+	ring, err := auth.LoadKeyRing(auth.KeyConfig{Secret: "0123456789abcdef0123456789abcdef"})
+	require.NoError(t, err)
+	revocations, err := NewRevocationStore(&mockRevokedTokenRepository{tokens: make(map[string]models.RevokedToken)})
+	require.NoError(t, err)
+
+	env := &testEnv{
+		users:    newMockUserRepository(),
+		refresh:  &mockRefreshTokenRepository{},
+		sessions: newMockSessionRepository(),
+		clients:  &mockClientRepository{clients: make(map[string]models.Client)},
+		codes:    &mockAuthorizationCodeRepository{codes: make(map[string]models.AuthorizationCode)},
+		devices:  &mockDeviceCodeRepository{codes: make(map[string]models.DeviceCode)},
+		signer:   auth.NewSigner(ring, testTokenConfig),
+	}
+	env.tokens = NewTokenUseCase(env.users, env.refresh, env.clients, env.sessions, revocations,
+		NewTokenVersionStore(env.users, 0), nil, env.signer, auth.NewVerifier(ring, testTokenConfig), auth.LifetimeConfig{})
+	env.userUC = NewUserUseCase(env.users, env.sessions, env.tokens, password.Policy{MinLength: 1})
+	env.oauth = NewOAuthUseCase(NewClientUseCase(env.clients), env.codes, env.devices, env.users, env.tokens)
+	return env
+}
+
+// createUser заводит пользователя с ролью role и паролем "secret".
+func (env *testEnv) createUser(t *testing.T, email, role string) models.User {
+	user := models.User{Name: "Test", Email: email, Password: "secret", Role: role}
+	require.NoError(t, user.HashPassword())
+	user, err := env.users.Create(user)
+	require.NoError(t, err)
+	return user
+}
+
+func (env *testEnv) login(t *testing.T, email string) TokenPair {
+	pair, err := env.userUC.Authenticate(LoginParams{Email: email, Password: "secret"})
+	require.NoError(t, err)
+	return pair
+}
+
+// browserSession открывает браузерную сессию пользователя email.
+func (env *testEnv) browserSession(t *testing.T, email string) models.Session {
+	_, session, err := env.userUC.StartBrowserSession(LoginParams{Email: email, Password: "secret"})
+	require.NoError(t, err)
+	return session
+}
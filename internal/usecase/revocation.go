@@ -0,0 +1,114 @@
+package usecase
+
+import (
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/internal/repository"
+	"github.com/fire9900/auth/pkg/logger"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const revocationCheckTTL = 30 * time.Second
+
+// RevocationStore - отозванные токены с кешем в памяти. Отзывы через этот
+// экземпляр сразу попадают в кеш; токены, отозванные другим экземпляром
+// сервиса, находятся запросом в базу, а отрицательный ответ кешируется
+// на revocationCheckTTL.
+type RevocationStore struct {
+	repo    repository.RevokedTokenRepository
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+	checked map[string]time.Time
+	now     func() time.Time
+}
+
+func NewRevocationStore(repo repository.RevokedTokenRepository) (*RevocationStore, error) {
+	s := &RevocationStore{
+		repo:    repo,
+		revoked: make(map[string]time.Time),
+		checked: make(map[string]time.Time),
+		now:     time.Now,
+	}
+
+	tokens, err := repo.GetActive(s.now())
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		s.revoked[token.JTI] = token.ExpiresAt
+	}
+	return s, nil
+}
+
+func (s *RevocationStore) Revoke(jti string, expiresAt time.Time) error {
+	err := s.repo.Revoke(models.RevokedToken{
+		JTI:       jti,
+		ExpiresAt: expiresAt,
+		RevokedAt: s.now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.revoked[jti] = expiresAt
+	delete(s.checked, jti)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *RevocationStore) IsRevoked(jti string) (bool, error) {
+	now := s.now()
+
+	s.mu.RLock()
+	_, revoked := s.revoked[jti]
+	checkedUntil, checked := s.checked[jti]
+	s.mu.RUnlock()
+
+	if revoked {
+		return true, nil
+	}
+	if checked && now.Before(checkedUntil) {
+		return false, nil
+	}
+
+	revoked, err := s.repo.IsRevoked(jti)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	if revoked {
+		s.revoked[jti] = now.Add(revocationCheckTTL)
+	} else {
+		s.checked[jti] = now.Add(revocationCheckTTL)
+	}
+	s.mu.Unlock()
+	return revoked, nil
+}
+
+// Cleanup удаляет из кеша и базы записи о токенах, срок которых уже истек.
+func (s *RevocationStore) Cleanup() {
+	now := s.now()
+
+	s.mu.Lock()
+	for jti, expiresAt := range s.revoked {
+		if !now.Before(expiresAt) {
+			delete(s.revoked, jti)
+		}
+	}
+	for jti, until := range s.checked {
+		if !now.Before(until) {
+			delete(s.checked, jti)
+		}
+	}
+	s.mu.Unlock()
+
+	deleted, err := s.repo.DeleteExpired(now)
+	if err != nil {
+		return
+	}
+	logger.Logger.Debug("Очищены истекшие отозванные токены",
+		zap.Int64("количество", deleted))
+}
@@ -0,0 +1,218 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/fire9900/auth/pkg/logger"
+	"go.uber.org/zap"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TokenTypeAccessToken - идентификатор типа токена access_token (RFC 8693, 3).
+// Сервис обменивает только access токены и выпускает только их.
+const TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+
+// TokenExchangeRequest - параметры grant token-exchange (RFC 8693, 2.1).
+// SubjectToken - токен пользователя, от имени которого действует клиент.
+// Необязательный ActorToken - токен самого клиента или его пользователя:
+// с ним выпускается делегированный токен с claim act, без него -
+// токен, неотличимый от токена пользователя (имперсонация).
+// JKT - отпечаток ключа DPoP запроса: новый токен привязывается к нему.
+type TokenExchangeRequest struct {
+	ClientID           string
+	SubjectToken       string
+	SubjectTokenType   string
+	ActorToken         string
+	ActorTokenType     string
+	Audience           string
+	Scope              string
+	RequestedTokenType string
+	JKT                string
+}
+
+// ExchangeToken обменивает токен пользователя на токен для другого сервиса.
+// Обменять можно токен, выпущенный самому клиенту или адресованный ему как
+// аудитории, а также токен входа по паролю, аудиторию которого клиенту
+// разрешено обменивать (ExchangeSubjectAudiences). Новый токен адресован только аудитории Audience, которую
+// клиенту разрешено запрашивать, его области доступа не шире областей
+// исходного токена, и он не живет дольше исходного токена.
+func (uc *OAuthUseCase) ExchangeToken(req TokenExchangeRequest) (TokenPair, error) {
+	client, err := uc.client(req.ClientID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if client.Public || !client.AllowsGrant(models.GrantTokenExchange) {
+		return TokenPair{}, models.ErrorUnauthorizedClient
+	}
+	if req.SubjectTokenType != TokenTypeAccessToken {
+		return TokenPair{}, fmt.Errorf("%w: неподдерживаемый subject_token_type", models.ErrorInvalidRequest)
+	}
+	if req.RequestedTokenType != "" && req.RequestedTokenType != TokenTypeAccessToken {
+		return TokenPair{}, fmt.Errorf("%w: неподдерживаемый requested_token_type", models.ErrorInvalidRequest)
+	}
+	if req.Audience == "" || !client.AllowsAudience(req.Audience) {
+		return TokenPair{}, models.ErrorInvalidTarget
+	}
+
+	subject, err := uc.tokens.ValidateExchangeToken(req.SubjectToken)
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("%w: недействительный subject_token: %v", models.ErrorInvalidRequest, err)
+	}
+	if subject.IsClient() {
+		return TokenPair{}, fmt.Errorf("%w: subject_token выпущен клиенту, а не пользователю", models.ErrorInvalidRequest)
+	}
+	// Иначе любой клиент с правом обмена превратил бы перехваченный
+	// чужой токен в токен для своих аудиторий.
+	if subject.ClientID != client.ID && !slices.Contains(subject.Audience, client.ID) &&
+		(subject.ClientID != "" || !slices.ContainsFunc(subject.Audience, client.AllowsSubjectAudience)) {
+		return TokenPair{}, fmt.Errorf("%w: subject_token выпущен другому клиенту", models.ErrorInvalidRequest)
+	}
+	// Привязанный к ключу токен обменивает только владелец ключа: иначе
+	// украденный DPoP токен можно было бы обменять на обычный Bearer токен.
+	if subject.Confirmation != nil && subject.Confirmation.JKT != req.JKT {
+		return TokenPair{}, fmt.Errorf("%w: %v", models.ErrorInvalidRequest, auth.ErrorDPoPBinding)
+	}
+
+	actor, err := uc.exchangeActor(req, client, subject)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	scopes, err := exchangeScopes(client, subject, req.Scope)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	user, err := uc.users.GetByID(subject.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrorUserNotFound) {
+			return TokenPair{}, fmt.Errorf("%w: пользователь subject_token не найден", models.ErrorInvalidRequest)
+		}
+		return TokenPair{}, err
+	}
+
+	logger.Logger.Info("Обмен токена",
+		zap.String("client_id", client.ID),
+		zap.Int("user_id", user.ID),
+		zap.String("audience", req.Audience),
+		zap.Bool("delegation", req.ActorToken != ""))
+	return uc.tokens.IssueExchangedToken(user, client, subject, ExchangeOptions{
+		Audience: req.Audience,
+		Scopes:   scopes,
+		Actor:    actor,
+		JKT:      req.JKT,
+	})
+}
+
+// exchangeActor строит claim act. Токен актора должен представлять
+// запрашивающего клиента: чужой токен нельзя подставить как актора.
+// Цепочка act исходного токена сохраняется и при имперсонации, чтобы
+// повторный обмен не скрывал, кто действовал раньше.
+func (uc *OAuthUseCase) exchangeActor(req TokenExchangeRequest, client models.Client, subject *auth.Claims) (*auth.Actor, error) {
+	if req.ActorToken == "" {
+		if req.ActorTokenType != "" {
+			return nil, fmt.Errorf("%w: actor_token_type без actor_token", models.ErrorInvalidRequest)
+		}
+		return subject.Actor, nil
+	}
+	if req.ActorTokenType != TokenTypeAccessToken {
+		return nil, fmt.Errorf("%w: неподдерживаемый actor_token_type", models.ErrorInvalidRequest)
+	}
+
+	actor, err := uc.tokens.ValidateExchangeToken(req.ActorToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: недействительный actor_token: %v", models.ErrorInvalidRequest, err)
+	}
+	if actor.ClientID != client.ID {
+		return nil, fmt.Errorf("%w: actor_token выпущен другому клиенту", models.ErrorInvalidRequest)
+	}
+
+	act := &auth.Actor{Subject: actor.Subject, Actor: subject.Actor}
+	if !actor.IsClient() {
+		act.ClientID = actor.ClientID
+	}
+	return act, nil
+}
+
+// exchangeScopes сужает области доступа: запрошенная область должна быть
+// и в исходном токене, и среди областей клиента. Пустой scope означает
+// все области исходного токена, доступные клиенту.
+func exchangeScopes(client models.Client, subject *auth.Claims, scope string) ([]string, error) {
+	available := []string{}
+	for _, s := range subject.Scopes() {
+		if client.AllowsScope(s) {
+			available = append(available, s)
+		}
+	}
+	if scope == "" {
+		return available, nil
+	}
+
+	requested := strings.Fields(scope)
+	for _, s := range requested {
+		if !slices.Contains(available, s) {
+			return nil, models.ErrorInvalidScope
+		}
+	}
+	return grantedScopes(available, requested), nil
+}
+
+// ExchangeOptions - параметры токена, выпускаемого обменом.
+type ExchangeOptions struct {
+	Audience string
+	Scopes   []string
+	Actor    *auth.Actor
+	JKT      string
+}
+
+// IssueExchangedToken выпускает access токен пользователя для клиента,
+// выполнившего обмен. Токен остается в сессии исходного токена и не может
+// пережить его. Refresh токен не выдается: по истечении клиент повторяет обмен.
+func (uc *TokenUseCase) IssueExchangedToken(user models.User, client models.Client, subject *auth.Claims, opts ExchangeOptions) (TokenPair, error) {
+	ttl := uc.lifetimesFor(user.Role, client.ID).Access
+	if remaining := subject.ExpiresAt.Sub(uc.now()); remaining < ttl {
+		ttl = remaining
+	}
+	if ttl < time.Second {
+		return TokenPair{}, fmt.Errorf("%w: срок действия subject_token истекает", models.ErrorInvalidRequest)
+	}
+
+	accessToken, expiresIn, err := uc.signer.GenerateAccessToken(auth.TokenParams{
+		UserID:       user.ID,
+		SessionID:    subject.SessionID,
+		TokenVersion: subject.TokenVersion,
+		ClientID:     client.ID,
+		Roles:        user.Roles(),
+		Scopes:       opts.Scopes,
+		Audience:     opts.Audience,
+		Actor:        opts.Actor,
+		JKT:          opts.JKT,
+		TTL:          ttl,
+	})
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("ошибка генерации access токена: %w", err)
+	}
+
+	return TokenPair{
+		AccessToken: accessToken,
+		Scope:       strings.Join(opts.Scopes, " "),
+		ExpiresIn:   expiresIn,
+	}, nil
+}
+
+// ValidateExchangeToken проверяет токен, предъявленный для обмена: access
+// токен любой аудитории, выпущенный этим сервисом и не отозванный.
+func (uc *TokenUseCase) ValidateExchangeToken(token string) (*auth.Claims, error) {
+	claims, err := uc.verifier.ValidateExchangeToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.checkAccessToken(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
@@ -0,0 +1,174 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/fire9900/auth/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exchangeEnv заводит пользователя, клиента gateway, которому разрешен обмен
+// токенов входа для api на токены для orders, и токен пользователя,
+// выпущенный gateway.
+func exchangeEnv(t *testing.T) (*testEnv, TokenPair) {
+	env := newTestEnv(t)
+	user := env.createUser(t, "user@example.com", "user")
+	for _, client := range []models.Client{
+		{ID: "gateway", Scopes: []string{"openid", "profile"}, ExchangeAudiences: []string{"orders"}, ExchangeSubjectAudiences: []string{"api"}},
+		{ID: "spa", Scopes: []string{"openid", "profile", "email"}, ExchangeAudiences: []string{"gateway"}},
+		{ID: "orders", Scopes: []string{"profile"}},
+	} {
+		client.GrantTypes = []string{models.GrantTokenExchange, models.GrantClientCredentials}
+		env.clients.clients[client.ID] = client
+	}
+
+	subject, err := env.tokens.IssuePair(user, IssueOptions{ClientID: "gateway", Scopes: []string{"openid", "profile", "email"}})
+	require.NoError(t, err)
+	return env, subject
+}
+
+func exchangeRequest(clientID, subjectToken, audience string) TokenExchangeRequest {
+	return TokenExchangeRequest{
+		ClientID:         clientID,
+		SubjectToken:     subjectToken,
+		SubjectTokenType: TokenTypeAccessToken,
+		Audience:         audience,
+	}
+}
+
+func TestOAuthUseCase_ExchangeToken(t *testing.T) {
+	env, subject := exchangeEnv(t)
+
+	pair, err := env.oauth.ExchangeToken(exchangeRequest("gateway", subject.AccessToken, "orders"))
+	require.NoError(t, err)
+	assert.Equal(t, "openid profile", pair.Scope, "только общие области токена и клиента")
+	assert.Empty(t, pair.RefreshToken)
+
+	claims, err := env.tokens.ValidateExchangeToken(pair.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"orders"}, []string(claims.Audience))
+	assert.Equal(t, "gateway", claims.ClientID)
+	assert.Nil(t, claims.Actor, "без actor_token выпускается токен имперсонации")
+	_, err = env.tokens.ValidateAccessToken(pair.AccessToken)
+	assert.Error(t, err, "токен для orders не принимается API сервиса")
+}
+
+func TestOAuthUseCase_ExchangeTokenAudience(t *testing.T) {
+	env, subject := exchangeEnv(t)
+
+	for _, audience := range []string{"", "billing", "api"} {
+		_, err := env.oauth.ExchangeToken(exchangeRequest("gateway", subject.AccessToken, audience))
+		assert.ErrorIs(t, err, models.ErrorInvalidTarget, audience)
+	}
+}
+
+func TestOAuthUseCase_ExchangeTokenScopes(t *testing.T) {
+	env, subject := exchangeEnv(t)
+
+	tests := []struct {
+		name  string
+		scope string
+		err   error
+		want  string
+	}{
+		{"Narrowed", "profile", nil, "profile"},
+		{"Not allowed to client", "email", models.ErrorInvalidScope, ""},
+		{"Not in subject token", "users:read", models.ErrorInvalidScope, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := exchangeRequest("gateway", subject.AccessToken, "orders")
+			req.Scope = tt.scope
+			pair, err := env.oauth.ExchangeToken(req)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, pair.Scope)
+		})
+	}
+}
+
+func TestOAuthUseCase_ExchangeTokenSubject(t *testing.T) {
+	env, subject := exchangeEnv(t)
+	user, err := env.users.GetByID(1)
+	require.NoError(t, err)
+	login := env.login(t, "user@example.com")
+	spa, err := env.tokens.IssuePair(user, IssueOptions{ClientID: "spa", Scopes: []string{"profile"}})
+	require.NoError(t, err)
+	client, err := env.oauth.ClientCredentials("gateway", "", "")
+	require.NoError(t, err)
+
+	t.Run("Token of another client", func(t *testing.T) {
+		_, err := env.oauth.ExchangeToken(exchangeRequest("gateway", spa.AccessToken, "orders"))
+		assert.ErrorIs(t, err, models.ErrorInvalidRequest, "политика аудиторий не открывает токены других клиентов")
+	})
+
+	t.Run("Login token", func(t *testing.T) {
+		pair, err := env.oauth.ExchangeToken(exchangeRequest("gateway", login.AccessToken, "orders"))
+		require.NoError(t, err, "gateway принимает токены входа для api")
+		claims, err := env.tokens.ValidateExchangeToken(pair.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"orders"}, []string(claims.Audience))
+		assert.Equal(t, "1", claims.Subject)
+
+		_, err = env.oauth.ExchangeToken(exchangeRequest("spa", login.AccessToken, "gateway"))
+		assert.ErrorIs(t, err, models.ErrorInvalidRequest, "spa не разрешено обменивать токены входа")
+	})
+
+	t.Run("Token addressed to the client", func(t *testing.T) {
+		forGateway, err := env.oauth.ExchangeToken(exchangeRequest("spa", spa.AccessToken, "gateway"))
+		require.NoError(t, err)
+		pair, err := env.oauth.ExchangeToken(exchangeRequest("gateway", forGateway.AccessToken, "orders"))
+		require.NoError(t, err)
+		assert.Equal(t, "profile", pair.Scope)
+	})
+
+	t.Run("Client token", func(t *testing.T) {
+		_, err := env.oauth.ExchangeToken(exchangeRequest("gateway", client.AccessToken, "orders"))
+		assert.ErrorIs(t, err, models.ErrorInvalidRequest)
+	})
+
+	t.Run("Revoked token", func(t *testing.T) {
+		require.NoError(t, env.tokens.RevokeToken(subject.AccessToken, "gateway"))
+		_, err := env.oauth.ExchangeToken(exchangeRequest("gateway", subject.AccessToken, "orders"))
+		assert.ErrorIs(t, err, models.ErrorInvalidRequest)
+	})
+}
+
+func TestOAuthUseCase_ExchangeTokenActor(t *testing.T) {
+	env, subject := exchangeEnv(t)
+	gateway, err := env.oauth.ClientCredentials("gateway", "", "")
+	require.NoError(t, err)
+	orders, err := env.oauth.ClientCredentials("orders", "", "")
+	require.NoError(t, err)
+
+	t.Run("Delegation", func(t *testing.T) {
+		req := exchangeRequest("gateway", subject.AccessToken, "orders")
+		req.ActorToken, req.ActorTokenType = gateway.AccessToken, TokenTypeAccessToken
+		pair, err := env.oauth.ExchangeToken(req)
+		require.NoError(t, err)
+
+		claims, err := env.tokens.ValidateExchangeToken(pair.AccessToken)
+		require.NoError(t, err)
+		require.NotNil(t, claims.Actor)
+		assert.Equal(t, "gateway", claims.Actor.Subject)
+		assert.Equal(t, "1", claims.Subject)
+	})
+
+	t.Run("Actor token of another client", func(t *testing.T) {
+		req := exchangeRequest("gateway", subject.AccessToken, "orders")
+		req.ActorToken, req.ActorTokenType = orders.AccessToken, TokenTypeAccessToken
+		_, err := env.oauth.ExchangeToken(req)
+		assert.ErrorIs(t, err, models.ErrorInvalidRequest)
+	})
+
+	t.Run("Actor token type without token", func(t *testing.T) {
+		req := exchangeRequest("gateway", subject.AccessToken, "orders")
+		req.ActorTokenType = TokenTypeAccessToken
+		_, err := env.oauth.ExchangeToken(req)
+		assert.ErrorIs(t, err, models.ErrorInvalidRequest)
+	})
+}
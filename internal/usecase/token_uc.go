@@ -0,0 +1,488 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/internal/repository"
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/fire9900/auth/pkg/logger"
+	"go.uber.org/zap"
+	"slices"
+	"strings"
+	"time"
+)
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	IDToken      string
+	Scope        string
+	ExpiresIn    int64
+}
+
+// IssueOptions - параметры новой сессии, от которых зависят сроки жизни токенов.
+// При обновлении токенов они сохраняются из исходной сессии.
+// Scopes сужает области доступа роли пользователя: nil означает все области
+// роли и используется только при входе по паролю, пустой срез - ни одной области.
+// Nonce и AuthTime попадают в ID токен, который выпускается только при входе;
+// нулевой AuthTime означает текущее время. Непустой SessionID задает
+// заранее выбранный идентификатор семейства refresh токенов. Непустой JKT
+// привязывает пару к ключу DPoP клиента.
+type IssueOptions struct {
+	ClientID   string
+	RememberMe bool
+	Scopes     []string
+	Nonce      string
+	AuthTime   time.Time
+	SessionID  string
+	JKT        string
+}
+
+// TokenUseCase выпускает пары токенов и ведет серверный учет refresh токенов:
+// каждый refresh токен одноразовый, а его повторное предъявление отзывает
+// все семейство, к которому он относится. opaque задан, только если сервис
+// выпускает непрозрачные токены; signer и verifier тогда уже работают с ним.
+// Сессии входа по паролю отзываются и продлеваются вместе со своим семейством.
+// versions отклоняет токены пользователя, выпущенные до увеличения его версии.
+type TokenUseCase struct {
+	users       repository.UserRepository
+	refresh     repository.RefreshTokenRepository
+	clients     repository.ClientRepository
+	sessions    repository.SessionRepository
+	revocations *RevocationStore
+	versions    *TokenVersionStore
+	opaque      *OpaqueTokenStore
+	signer      *auth.Signer
+	verifier    *auth.Verifier
+	lifetimes   auth.LifetimeConfig
+	now         func() time.Time
+}
+
+func NewTokenUseCase(users repository.UserRepository, refresh repository.RefreshTokenRepository,
+	clients repository.ClientRepository, sessions repository.SessionRepository, revocations *RevocationStore,
+	versions *TokenVersionStore, opaque *OpaqueTokenStore, signer *auth.Signer, verifier *auth.Verifier,
+	lifetimes auth.LifetimeConfig) *TokenUseCase {
+	return &TokenUseCase{
+		users:       users,
+		refresh:     refresh,
+		clients:     clients,
+		sessions:    sessions,
+		revocations: revocations,
+		versions:    versions,
+		opaque:      opaque,
+		signer:      signer,
+		verifier:    verifier,
+		lifetimes:   lifetimes,
+		now:         time.Now,
+	}
+}
+
+// ValidateAccessToken проверяет подпись и тип токена, а также то,
+// что токен не был отозван и выпущен в текущей версии токенов пользователя.
+func (uc *TokenUseCase) ValidateAccessToken(accessToken string) (*auth.Claims, error) {
+	return uc.ValidateAccessTokenFor(accessToken, "")
+}
+
+// ValidateAccessTokenFor - то же, что ValidateAccessToken, но для получателя
+// audience; пустое значение означает аудиторию по умолчанию.
+func (uc *TokenUseCase) ValidateAccessTokenFor(accessToken, audience string) (*auth.Claims, error) {
+	claims, err := uc.verifier.ValidateAccessTokenFor(accessToken, audience)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.checkAccessToken(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// checkAccessToken проверяет серверное состояние access токена: отзыв
+// по jti и версию токенов пользователя.
+func (uc *TokenUseCase) checkAccessToken(claims *auth.Claims) error {
+	revoked, err := uc.revocations.IsRevoked(claims.ID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return models.ErrorTokenRevoked
+	}
+
+	current, err := uc.versions.IsCurrent(claims)
+	if err != nil {
+		return err
+	}
+	if !current {
+		return models.ErrorTokenRevoked
+	}
+	return nil
+}
+
+// Introspect проверяет токен любого типа с учетом серверного состояния:
+// отзыва access токенов, использования или отзыва refresh токенов
+// и версии токенов пользователя.
+func (uc *TokenUseCase) Introspect(token string) (*auth.Claims, error) {
+	claims, err := uc.verifier.Inspect(token)
+	if err != nil {
+		return nil, err
+	}
+
+	switch claims.TokenType {
+	case auth.TokenTypeAccess:
+		if err := uc.checkAccessToken(claims); err != nil {
+			return nil, err
+		}
+	case auth.TokenTypeRefresh:
+		current, err := uc.versions.IsCurrent(claims)
+		if err != nil {
+			return nil, err
+		}
+		if !current {
+			return nil, models.ErrorRefreshTokenRevoked
+		}
+		stored, err := uc.refresh.GetByHash(auth.HashToken(token))
+		if err != nil {
+			return nil, err
+		}
+		if stored.RevokedAt != nil {
+			return nil, models.ErrorRefreshTokenRevoked
+		}
+		if stored.UsedAt != nil {
+			return nil, models.ErrorRefreshTokenReused
+		}
+	}
+	return claims, nil
+}
+
+// RevokeToken отзывает токен по RFC 7009: access токен - по jti, refresh
+// токен - вместе со всем семейством. Клиент clientID отзывает только
+// выпущенные ему токены: чужие токены, в том числе токены входа по паролю,
+// как и невалидные и истекшие, не отзываются, и для них возвращается nil,
+// чтобы ответ не раскрывал, чей это токен.
+func (uc *TokenUseCase) RevokeToken(token, clientID string) error {
+	claims, err := uc.verifier.Inspect(token)
+	if err != nil {
+		return nil
+	}
+	if claims.ClientID != clientID {
+		logger.Logger.Warn("Попытка отзыва токена другого клиента",
+			zap.String("client_id", clientID),
+			zap.String("token_client_id", claims.ClientID))
+		return nil
+	}
+
+	switch claims.TokenType {
+	case auth.TokenTypeAccess:
+		return uc.revocations.Revoke(claims.ID, claims.ExpiresAt.Time)
+	case auth.TokenTypeRefresh:
+		stored, err := uc.refresh.GetByHash(auth.HashToken(token))
+		if err != nil {
+			if errors.Is(err, models.ErrorRefreshTokenNotFound) {
+				return nil
+			}
+			return err
+		}
+		return uc.RevokeSession(stored.FamilyID, uc.now())
+	}
+	return nil
+}
+
+// Revoke отзывает access токен и все семейство refresh токенов его сессии.
+func (uc *TokenUseCase) Revoke(claims *auth.Claims) error {
+	if err := uc.revocations.Revoke(claims.ID, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+	if claims.SessionID == "" {
+		return nil
+	}
+	return uc.RevokeSession(claims.SessionID, uc.now())
+}
+
+// RevokeSession отзывает семейство refresh токенов familyID и сессию
+// входа с тем же идентификатором, если она есть. В режиме непрозрачных
+// токенов вместе с ним сразу перестают действовать и все access токены сессии.
+func (uc *TokenUseCase) RevokeSession(familyID string, revokedAt time.Time) error {
+	if err := uc.refresh.RevokeFamily(familyID, revokedAt); err != nil {
+		return err
+	}
+	if err := uc.sessions.Revoke(familyID, revokedAt); err != nil {
+		return err
+	}
+	if uc.opaque == nil {
+		return nil
+	}
+	return uc.opaque.RevokeSession(familyID)
+}
+
+// RevokeAll делает недействительными все токены пользователя, увеличивая
+// версию его токенов, и завершает все его сессии. Вызывается при смене
+// пароля, блокировке и выходе со всех устройств.
+func (uc *TokenUseCase) RevokeAll(userID int) error {
+	if err := uc.versions.Bump(userID); err != nil {
+		return err
+	}
+	return uc.sessions.RevokeByUser(userID, uc.now())
+}
+
+// IssuePair выпускает пару токенов и начинает новое семейство refresh токенов.
+// ID токен выпускается при входе по паролю и при запросе области openid,
+// если ключ подписи асимметричный.
+func (uc *TokenUseCase) IssuePair(user models.User, opts IssueOptions) (TokenPair, error) {
+	familyID := opts.SessionID
+	if familyID == "" {
+		var err error
+		if familyID, err = auth.NewTokenID(); err != nil {
+			return TokenPair{}, fmt.Errorf("ошибка генерации семейства токенов: %w", err)
+		}
+	}
+
+	pair, err := uc.issue(user, familyID, opts)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !uc.signer.IDTokensSupported() || opts.Scopes != nil && !slices.Contains(opts.Scopes, "openid") {
+		return pair, nil
+	}
+
+	authTime := opts.AuthTime
+	if authTime.IsZero() {
+		authTime = uc.now()
+	}
+	pair.IDToken, err = uc.signer.GenerateIDToken(auth.IDTokenParams{
+		UserID:   user.ID,
+		Audience: opts.ClientID,
+		Email:    user.Email,
+		Name:     user.Name,
+		Nonce:    opts.Nonce,
+		AuthTime: authTime,
+		TTL:      uc.lifetimesFor(user.Role, opts.ClientID).Access,
+	})
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("ошибка генерации ID токена: %w", err)
+	}
+	return pair, nil
+}
+
+// IssueClientToken выпускает access токен самого клиента (grant
+// client_credentials, RFC 6749, 4.4). Refresh токен не выдается:
+// клиент в любой момент может получить новый токен по своему секрету.
+// Непустой jkt привязывает токен к ключу DPoP клиента.
+func (uc *TokenUseCase) IssueClientToken(client models.Client, scopes []string, jkt string) (TokenPair, error) {
+	granted := grantedScopes(client.Scopes, scopes)
+	accessToken, expiresIn, err := uc.signer.GenerateAccessToken(auth.TokenParams{
+		ClientID: client.ID,
+		Scopes:   granted,
+		JKT:      jkt,
+		TTL:      uc.lifetimesFor("", client.ID).Access,
+	})
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("ошибка генерации access токена: %w", err)
+	}
+
+	return TokenPair{
+		AccessToken: accessToken,
+		Scope:       strings.Join(granted, " "),
+		ExpiresIn:   expiresIn,
+	}, nil
+}
+
+// Refresh обменивает refresh токен на новую пару в том же семействе.
+// Принимаются только токены входа без клиента: токены, выпущенные
+// клиенту, обновляются через RefreshForClient после аутентификации клиента.
+func (uc *TokenUseCase) Refresh(refreshToken string) (TokenPair, error) {
+	return uc.rotate(refreshToken, "", "")
+}
+
+// RefreshForClient работает как Refresh, но принимает только refresh токены,
+// выпущенные клиенту clientID (RFC 6749, раздел 6). jkt - отпечаток ключа
+// из DPoP доказательства запроса: refresh токен, привязанный к ключу,
+// обновляется только с доказательством тем же ключом (RFC 9449, 5).
+func (uc *TokenUseCase) RefreshForClient(refreshToken, clientID, jkt string) (TokenPair, error) {
+	return uc.rotate(refreshToken, clientID, jkt)
+}
+
+func (uc *TokenUseCase) rotate(refreshToken, clientID, jkt string) (TokenPair, error) {
+	claims, err := uc.verifier.ValidateRefreshToken(refreshToken)
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("%w: %v", models.ErrorRefreshTokenInvalid, err)
+	}
+	if claims.Confirmation != nil && claims.Confirmation.JKT != jkt {
+		return TokenPair{}, fmt.Errorf("%w: %v", models.ErrorInvalidGrant, auth.ErrorDPoPBinding)
+	}
+
+	stored, err := uc.refresh.GetByHash(auth.HashToken(refreshToken))
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if stored.RevokedAt != nil {
+		return TokenPair{}, models.ErrorRefreshTokenRevoked
+	}
+	if stored.UserID != claims.UserID {
+		return TokenPair{}, models.ErrorRefreshTokenInvalid
+	}
+	if stored.ClientID != clientID {
+		return TokenPair{}, models.ErrorTokenClientMismatch
+	}
+
+	now := uc.now()
+	if stored.UsedAt != nil {
+		return TokenPair{}, uc.revokeReused(stored, now)
+	}
+	marked, err := uc.refresh.MarkUsed(stored.ID, now)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !marked {
+		return TokenPair{}, uc.revokeReused(stored, now)
+	}
+
+	user, err := uc.users.GetByID(stored.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrorUserNotFound) {
+			return TokenPair{}, models.ErrorRefreshTokenInvalid
+		}
+		return TokenPair{}, err
+	}
+	if claims.TokenVersion < user.TokenVersion {
+		return TokenPair{}, models.ErrorRefreshTokenRevoked
+	}
+
+	// Сессия входа по паролю получает все текущие области роли, а семейство
+	// клиента - не больше областей, выданных ему при авторизации.
+	var scopes []string
+	if stored.ClientID != "" {
+		scopes = scopesOf(stored.Scope)
+	}
+	return uc.issue(user, stored.FamilyID, IssueOptions{
+		ClientID:   stored.ClientID,
+		RememberMe: stored.RememberMe,
+		Scopes:     scopes,
+		JKT:        jkt,
+	})
+}
+
+func (uc *TokenUseCase) revokeReused(stored models.RefreshToken, now time.Time) error {
+	logger.Logger.Warn("Повторное использование refresh токена, семейство отзывается",
+		zap.Int("user_id", stored.UserID),
+		zap.String("family_id", stored.FamilyID))
+	if err := uc.RevokeSession(stored.FamilyID, now); err != nil {
+		return err
+	}
+	return models.ErrorRefreshTokenReused
+}
+
+func (uc *TokenUseCase) issue(user models.User, familyID string, opts IssueOptions) (TokenPair, error) {
+	if user.Suspended {
+		return TokenPair{}, models.ErrorUserSuspended
+	}
+
+	lifetimes := uc.lifetimesFor(user.Role, opts.ClientID)
+	scopes := grantedScopes(user.Scopes(), opts.Scopes)
+	params := auth.TokenParams{
+		UserID:       user.ID,
+		SessionID:    familyID,
+		TokenVersion: user.TokenVersion,
+		ClientID:     opts.ClientID,
+		Roles:        user.Roles(),
+		Scopes:       scopes,
+		JKT:          opts.JKT,
+		TTL:          lifetimes.Access,
+	}
+	accessToken, expiresIn, err := uc.signer.GenerateAccessToken(params)
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("ошибка генерации access токена: %w", err)
+	}
+
+	params.TTL = lifetimes.RefreshTTL(opts.RememberMe)
+	refreshToken, refreshExpiresAt, err := uc.signer.GenerateRefreshToken(params)
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("ошибка генерации refresh токена: %w", err)
+	}
+
+	now := uc.now()
+	expiresAt := time.Unix(refreshExpiresAt, 0)
+	_, err = uc.refresh.Create(models.RefreshToken{
+		FamilyID:   familyID,
+		TokenHash:  auth.HashToken(refreshToken),
+		UserID:     user.ID,
+		ClientID:   opts.ClientID,
+		RememberMe: opts.RememberMe,
+		Scope:      scopeString(scopes),
+		ExpiresAt:  expiresAt,
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	// У семейств, начатых не входом по паролю, сессии нет, и Touch ничего
+	// не меняет. Время последней активности не критично для выдачи токенов.
+	if err := uc.sessions.Touch(familyID, now, expiresAt); err != nil {
+		logger.Logger.Warn("Не удалось обновить время активности сессии",
+			zap.String("session_id", familyID),
+			zap.Error(err))
+	}
+
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(scopes, " "),
+		ExpiresIn:    expiresIn,
+	}, nil
+}
+
+// lifetimesFor дополняет сроки жизни из конфигурации сроками,
+// заданными зарегистрированному клиенту. Настройки клиента в базе
+// имеют приоритет над файлом переопределений.
+func (uc *TokenUseCase) lifetimesFor(role, clientID string) auth.Lifetimes {
+	lifetimes := uc.lifetimes.Resolve(role, clientID)
+	if clientID == "" {
+		return lifetimes
+	}
+
+	client, err := uc.clients.GetByID(clientID)
+	if err != nil {
+		if !errors.Is(err, models.ErrorClientNotFound) {
+			logger.Logger.Warn("Не удалось получить сроки жизни токенов клиента",
+				zap.String("client_id", clientID),
+				zap.Error(err))
+		}
+		return lifetimes
+	}
+	if client.AccessTokenTTL > 0 {
+		lifetimes.Access = time.Duration(client.AccessTokenTTL) * time.Second
+	}
+	if client.RefreshTokenTTL > 0 {
+		lifetimes.Refresh = time.Duration(client.RefreshTokenTTL) * time.Second
+	}
+	if client.RememberMeTTL > 0 {
+		lifetimes.RememberMe = time.Duration(client.RememberMeTTL) * time.Second
+	}
+	return lifetimes
+}
+
+// grantedScopes оставляет из запрошенных областей только разрешенные.
+// nil означает, что области не сужались, и выдаются все разрешенные.
+func grantedScopes(allowed, requested []string) []string {
+	if requested == nil {
+		return allowed
+	}
+	granted := []string{}
+	for _, scope := range requested {
+		if slices.Contains(allowed, scope) && !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	return granted
+}
+
+// scopeString и scopesOf переводят области доступа в колонку scope
+// и обратно. Пустая строка означает ни одной области, а не все области:
+// клиенту без областей нельзя выдать области роли пользователя.
+func scopeString(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+func scopesOf(scope string) []string {
+	return append([]string{}, strings.Fields(scope)...)
+}
@@ -0,0 +1,85 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/fire9900/auth/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenUseCase_RefreshReuse(t *testing.T) {
+	env := newTestEnv(t)
+	env.createUser(t, "user@example.com", "user")
+	first := env.login(t, "user@example.com")
+
+	second, err := env.tokens.Refresh(first.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	third, err := env.tokens.Refresh(second.RefreshToken)
+	require.NoError(t, err)
+
+	_, err = env.tokens.Refresh(first.RefreshToken)
+	assert.ErrorIs(t, err, models.ErrorRefreshTokenReused)
+
+	_, err = env.tokens.Refresh(third.RefreshToken)
+	assert.ErrorIs(t, err, models.ErrorRefreshTokenRevoked, "повторное использование отзывает все семейство")
+	require.Len(t, env.refresh.tokens, 3)
+	for _, token := range env.refresh.tokens {
+		assert.NotNil(t, token.RevokedAt)
+	}
+}
+
+func TestTokenUseCase_RefreshClientToken(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser(t, "user@example.com", "user")
+	pair, err := env.tokens.IssuePair(user, IssueOptions{ClientID: "console", Scopes: []string{"profile"}})
+	require.NoError(t, err)
+
+	_, err = env.tokens.Refresh(pair.RefreshToken)
+	assert.ErrorIs(t, err, models.ErrorTokenClientMismatch, "токен клиента обновляется только с аутентификацией клиента")
+	_, err = env.tokens.RefreshForClient(pair.RefreshToken, "other", "")
+	assert.ErrorIs(t, err, models.ErrorTokenClientMismatch)
+
+	refreshed, err := env.tokens.RefreshForClient(pair.RefreshToken, "console", "")
+	require.NoError(t, err, "отклоненные попытки не расходуют токен")
+	assert.Equal(t, "profile", refreshed.Scope)
+}
+
+func TestTokenUseCase_RevokeToken(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser(t, "user@example.com", "user")
+	login := env.login(t, "user@example.com")
+	own, err := env.tokens.IssuePair(user, IssueOptions{ClientID: "gateway"})
+	require.NoError(t, err)
+
+	t.Run("First-party token", func(t *testing.T) {
+		require.NoError(t, env.tokens.RevokeToken(login.AccessToken, "gateway"))
+		require.NoError(t, env.tokens.RevokeToken(login.RefreshToken, "gateway"))
+
+		_, err := env.tokens.ValidateAccessToken(login.AccessToken)
+		assert.NoError(t, err, "клиент не может отозвать токен входа по паролю")
+		_, err = env.tokens.Introspect(login.RefreshToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Another client's token", func(t *testing.T) {
+		require.NoError(t, env.tokens.RevokeToken(own.RefreshToken, "spa"))
+		_, err := env.tokens.Introspect(own.RefreshToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Own tokens", func(t *testing.T) {
+		require.NoError(t, env.tokens.RevokeToken(own.AccessToken, "gateway"))
+		_, err := env.tokens.ValidateAccessToken(own.AccessToken)
+		assert.ErrorIs(t, err, models.ErrorTokenRevoked)
+
+		require.NoError(t, env.tokens.RevokeToken(own.RefreshToken, "gateway"))
+		_, err = env.tokens.RefreshForClient(own.RefreshToken, "gateway", "")
+		assert.ErrorIs(t, err, models.ErrorRefreshTokenRevoked)
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		assert.NoError(t, env.tokens.RevokeToken("garbage", "gateway"))
+	})
+}
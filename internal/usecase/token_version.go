@@ -0,0 +1,105 @@
+package usecase
+
+import (
+	"errors"
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/internal/repository"
+	"github.com/fire9900/auth/pkg/auth"
+	"sync"
+	"time"
+)
+
+// DefaultTokenVersionCacheTTL - сколько версия токенов пользователя живет
+// в кеше. Увеличение версии через этот экземпляр сервиса видно сразу,
+// а через другой экземпляр - не позже чем через это время.
+const DefaultTokenVersionCacheTTL = 30 * time.Second
+
+type cachedVersion struct {
+	version int
+	until   time.Time
+}
+
+// TokenVersionStore проверяет claim ver токенов пользователей по версии
+// из учетной записи и кеширует ее, чтобы не обращаться к базе на каждый запрос.
+type TokenVersionStore struct {
+	users    repository.UserRepository
+	cacheTTL time.Duration
+	mu       sync.RWMutex
+	cache    map[int]cachedVersion
+	now      func() time.Time
+}
+
+func NewTokenVersionStore(users repository.UserRepository, cacheTTL time.Duration) *TokenVersionStore {
+	if cacheTTL <= 0 {
+		cacheTTL = DefaultTokenVersionCacheTTL
+	}
+	return &TokenVersionStore{
+		users:    users,
+		cacheTTL: cacheTTL,
+		cache:    make(map[int]cachedVersion),
+		now:      time.Now,
+	}
+}
+
+// IsCurrent сообщает, что токен выпущен в текущей версии токенов
+// пользователя. У токенов клиента версии нет, они всегда актуальны.
+// Токен удаленного пользователя считается устаревшим.
+func (s *TokenVersionStore) IsCurrent(claims *auth.Claims) (bool, error) {
+	if claims.IsClient() {
+		return true, nil
+	}
+	version, err := s.Current(claims.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrorUserNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return claims.TokenVersion >= version, nil
+}
+
+func (s *TokenVersionStore) Current(userID int) (int, error) {
+	s.mu.RLock()
+	cached, ok := s.cache[userID]
+	s.mu.RUnlock()
+	if ok && s.now().Before(cached.until) {
+		return cached.version, nil
+	}
+
+	version, err := s.users.GetTokenVersion(userID)
+	if err != nil {
+		return 0, err
+	}
+	s.remember(userID, version)
+	return version, nil
+}
+
+// Bump увеличивает версию токенов пользователя: все выпущенные ему ранее
+// access и refresh токены перестают приниматься.
+func (s *TokenVersionStore) Bump(userID int) error {
+	version, err := s.users.BumpTokenVersion(userID)
+	if err != nil {
+		return err
+	}
+	s.remember(userID, version)
+	return nil
+}
+
+// Cleanup удаляет из кеша истекшие версии.
+func (s *TokenVersionStore) Cleanup() {
+	now := s.now()
+
+	s.mu.Lock()
+	for userID, cached := range s.cache {
+		if !now.Before(cached.until) {
+			delete(s.cache, userID)
+		}
+	}
+	s.mu.Unlock()
+}
+
+func (s *TokenVersionStore) remember(userID, version int) {
+	s.mu.Lock()
+	s.cache[userID] = cachedVersion{version: version, until: s.now().Add(s.cacheTTL)}
+	s.mu.Unlock()
+}
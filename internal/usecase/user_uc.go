@@ -1,9 +1,10 @@
 package usecase
 
 import (
-	"fmt"
 	"github.com/fire9900/auth/internal/models"
 	"github.com/fire9900/auth/internal/repository"
+	"github.com/fire9900/auth/pkg/auth"
+	"github.com/fire9900/auth/pkg/password"
 )
 
 type UseCase interface {
@@ -12,17 +13,31 @@ type UseCase interface {
 	GetUserByEmail(email string) (models.User, error)
 	CreateUser(user models.User) (models.User, error)
 	UpdateUser(id int, user models.User) (models.User, error)
+	UpdatePassword(claims *auth.Claims, id int, newPassword string) (models.User, error)
+	PasswordPolicy() password.Policy
 	DeleteUser(id int) error
 	CheckPassword(id int, password string) bool
-	Authenticate(email string, password string) (string, string, int64, error)
+	Authenticate(params LoginParams) (TokenPair, error)
+	StartBrowserSession(params LoginParams) (string, models.Session, error)
+	BrowserSession(secret string) (models.Session, error)
+	Refresh(refreshToken string) (TokenPair, error)
+	Logout(claims *auth.Claims) error
+	LogoutAll(claims *auth.Claims) error
+	SuspendUser(id int) error
+	Sessions(userID int) ([]models.Session, error)
+	RevokeSession(userID int, sessionID string) error
 }
 
 type UserUseCase struct {
-	repo repository.UserRepository
+	repo     repository.UserRepository
+	sessions repository.SessionRepository
+	tokens   *TokenUseCase
+	policy   password.Policy
 }
 
-func NewUserUseCase(repo repository.UserRepository) *UserUseCase {
-	return &UserUseCase{repo: repo}
+func NewUserUseCase(repo repository.UserRepository, sessions repository.SessionRepository,
+	tokens *TokenUseCase, policy password.Policy) *UserUseCase {
+	return &UserUseCase{repo: repo, sessions: sessions, tokens: tokens, policy: policy}
 }
 
 func (uc *UserUseCase) GetAllUsers() ([]models.User, error) {
@@ -37,9 +52,12 @@ func (uc *UserUseCase) GetUserByEmail(email string) (models.User, error) {
 	return uc.repo.GetByEmail(email)
 }
 
+// CreateUser проверяет пароль по политике паролей: при нарушениях
+// возвращается *password.PolicyError со списком всех нарушений.
 func (uc *UserUseCase) CreateUser(user models.User) (models.User, error) {
-	if user.Password == "" {
-		return models.User{}, fmt.Errorf("пароль не может быть пустым")
+	err := uc.policy.Validate(user.Password, password.UserInputs{Email: user.Email, Name: user.Name})
+	if err != nil {
+		return models.User{}, err
 	}
 
 	if err := user.HashPassword(); err != nil {
@@ -50,8 +68,61 @@ func (uc *UserUseCase) CreateUser(user models.User) (models.User, error) {
 	return uc.repo.Create(user)
 }
 
+// UpdateUser сохраняет пользователя. Если вместе с ним сменился пароль,
+// все выпущенные ранее токены пользователя отзываются.
 func (uc *UserUseCase) UpdateUser(id int, user models.User) (models.User, error) {
-	return uc.repo.Update(id, user)
+	stored, err := uc.repo.GetByID(id)
+	if err != nil {
+		return models.User{}, err
+	}
+	updated, err := uc.repo.Update(id, user)
+	if err != nil {
+		return models.User{}, err
+	}
+	if updated.Password != stored.Password {
+		if err := uc.tokens.RevokeAll(id); err != nil {
+			return models.User{}, err
+		}
+	}
+	return updated, nil
+}
+
+// UpdatePassword проверяет новый пароль по политике паролей, сохраняет
+// его хеш и отзывает все токены пользователя. Пароль меняет сам
+// пользователь или администратор с областью users:write; сброса пароля
+// по ссылке в сервисе нет, и такой поток должен вызывать этот метод,
+// чтобы политика применялась и к нему.
+func (uc *UserUseCase) UpdatePassword(claims *auth.Claims, id int, newPassword string) (models.User, error) {
+	if claims.UserID != id && !claims.HasScope("users:write") {
+		return models.User{}, models.ErrorForbidden
+	}
+	user, err := uc.repo.GetByID(id)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	err = uc.policy.Validate(newPassword, password.UserInputs{Email: user.Email, Name: user.Name})
+	if err != nil {
+		return models.User{}, err
+	}
+	user.Password = newPassword
+	if err := user.HashPassword(); err != nil {
+		return models.User{}, err
+	}
+	return uc.UpdateUser(id, user)
+}
+
+func (uc *UserUseCase) PasswordPolicy() password.Policy {
+	return uc.policy
+}
+
+// SuspendUser блокирует пользователя: новые входы запрещаются, а все
+// выпущенные ему токены и сессии сразу перестают действовать.
+func (uc *UserUseCase) SuspendUser(id int) error {
+	if err := uc.repo.Suspend(id); err != nil {
+		return err
+	}
+	return uc.tokens.RevokeAll(id)
 }
 
 func (uc *UserUseCase) DeleteUser(id int) error {
@@ -0,0 +1,150 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/fire9900/auth/internal/models"
+	"github.com/fire9900/auth/pkg/password"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserUseCase_UpdatePasswordRevokesTokens(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser(t, "user@example.com", "user")
+	first := env.login(t, "user@example.com")
+	second := env.login(t, "user@example.com")
+
+	claims, err := env.tokens.ValidateAccessToken(first.AccessToken)
+	require.NoError(t, err)
+	_, err = env.userUC.UpdatePassword(claims, user.ID, "new secret")
+	require.NoError(t, err)
+	assert.Equal(t, 1, env.users.users[user.ID].TokenVersion)
+
+	for _, pair := range []TokenPair{first, second} {
+		_, err := env.tokens.ValidateAccessToken(pair.AccessToken)
+		assert.ErrorIs(t, err, models.ErrorTokenRevoked, "токены прежней версии не принимаются")
+		_, err = env.userUC.Refresh(pair.RefreshToken)
+		assert.ErrorIs(t, err, models.ErrorRefreshTokenRevoked)
+	}
+	sessions, err := env.userUC.Sessions(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	_, err = env.userUC.Authenticate(LoginParams{Email: "user@example.com", Password: "secret"})
+	assert.ErrorIs(t, err, models.ErrorWrongPassword)
+	pair, err := env.userUC.Authenticate(LoginParams{Email: "user@example.com", Password: "new secret"})
+	require.NoError(t, err)
+	_, err = env.tokens.ValidateAccessToken(pair.AccessToken)
+	assert.NoError(t, err, "токены новой версии действуют")
+}
+
+func TestUserUseCase_UpdateUserKeepsTokens(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser(t, "user@example.com", "user")
+	pair := env.login(t, "user@example.com")
+
+	user.Name = "Renamed"
+	updated, err := env.userUC.UpdateUser(user.ID, user)
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", updated.Name)
+	assert.Zero(t, env.users.users[user.ID].TokenVersion)
+
+	_, err = env.tokens.ValidateAccessToken(pair.AccessToken)
+	assert.NoError(t, err, "изменение без смены пароля не завершает сессии")
+	_, err = env.userUC.Refresh(pair.RefreshToken)
+	assert.NoError(t, err)
+}
+
+func TestUserUseCase_UpdatePasswordOwnership(t *testing.T) {
+	env := newTestEnv(t)
+	victim := env.createUser(t, "victim@example.com", "user")
+	env.createUser(t, "attacker@example.com", "user")
+	env.createUser(t, "admin@example.com", "admin")
+
+	attacker, err := env.tokens.ValidateAccessToken(env.login(t, "attacker@example.com").AccessToken)
+	require.NoError(t, err)
+	_, err = env.userUC.UpdatePassword(attacker, victim.ID, "attacker password")
+	assert.ErrorIs(t, err, models.ErrorForbidden)
+	assert.True(t, env.userUC.CheckPassword(victim.ID, "secret"), "чужой пароль не меняется")
+	assert.Zero(t, env.users.users[victim.ID].TokenVersion)
+
+	admin, err := env.tokens.ValidateAccessToken(env.login(t, "admin@example.com").AccessToken)
+	require.NoError(t, err)
+	_, err = env.userUC.UpdatePassword(admin, victim.ID, "admin password")
+	require.NoError(t, err)
+	assert.True(t, env.userUC.CheckPassword(victim.ID, "admin password"))
+}
+
+func TestUserUseCase_SuspendUser(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser(t, "user@example.com", "user")
+	other := env.createUser(t, "other@example.com", "user")
+	phone := env.login(t, "user@example.com")
+	laptop := env.login(t, "user@example.com")
+	otherPair := env.login(t, "other@example.com")
+
+	require.NoError(t, env.userUC.SuspendUser(user.ID))
+	assert.Equal(t, 1, env.users.users[user.ID].TokenVersion)
+
+	for _, pair := range []TokenPair{phone, laptop} {
+		_, err := env.tokens.ValidateAccessTokenFor(pair.AccessToken, "api")
+		assert.ErrorIs(t, err, models.ErrorTokenRevoked, "токены прежней версии не принимаются")
+		_, err = env.userUC.Refresh(pair.RefreshToken)
+		assert.ErrorIs(t, err, models.ErrorRefreshTokenRevoked)
+	}
+	sessions, err := env.userUC.Sessions(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	_, err = env.userUC.Authenticate(LoginParams{Email: "user@example.com", Password: "secret"})
+	assert.ErrorIs(t, err, models.ErrorUserSuspended, "заблокированный пользователь не входит")
+	_, _, err = env.userUC.StartBrowserSession(LoginParams{Email: "user@example.com", Password: "secret"})
+	assert.ErrorIs(t, err, models.ErrorUserSuspended)
+
+	_, err = env.tokens.ValidateAccessToken(otherPair.AccessToken)
+	assert.NoError(t, err, "блокировка не затрагивает других пользователей")
+	assert.Zero(t, env.users.users[other.ID].TokenVersion)
+
+	assert.ErrorIs(t, env.userUC.SuspendUser(100), models.ErrorUserNotFound)
+}
+
+// breachedPasswords - база утечек в памяти: пароль и число его вхождений.
+type breachedPasswords map[string]int
+
+func (b breachedPasswords) Occurrences(password string) (int, error) {
+	return b[password], nil
+}
+
+func (b breachedPasswords) Close() error {
+	return nil
+}
+
+func TestUserUseCase_BreachedPasswords(t *testing.T) {
+	env := newTestEnv(t)
+	env.userUC.policy = password.Policy{
+		MinLength:       1,
+		MaxLength:       64,
+		RejectBreached:  true,
+		BreachThreshold: 2,
+		Breaches:        breachedPasswords{"hunter2": 3, "rare": 1},
+	}
+
+	_, err := env.userUC.CreateUser(models.User{Name: "Test", Email: "user@example.com", Password: "hunter2"})
+	var policyErr *password.PolicyError
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, password.ViolationBreached, policyErr.Violations[0].Code)
+	assert.Empty(t, env.users.users)
+
+	user, err := env.userUC.CreateUser(models.User{Name: "Test", Email: "user@example.com", Password: "rare"})
+	require.NoError(t, err, "пароль встречается реже порога")
+
+	pair, err := env.userUC.Authenticate(LoginParams{Email: "user@example.com", Password: "rare"})
+	require.NoError(t, err)
+	claims, err := env.tokens.ValidateAccessToken(pair.AccessToken)
+	require.NoError(t, err)
+	_, err = env.userUC.UpdatePassword(claims, user.ID, "hunter2")
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, password.ViolationBreached, policyErr.Violations[0].Code)
+	assert.True(t, env.userUC.CheckPassword(user.ID, "rare"))
+}
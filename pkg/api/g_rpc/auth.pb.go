@@ -22,8 +22,15 @@ const (
 )
 
 type TokenRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Token string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	// Ожидаемая аудитория токена; пустое значение - аудитория по умолчанию.
+	Audience string `protobuf:"bytes,2,opt,name=audience,proto3" json:"audience,omitempty"`
+	// DPoP доказательство из запроса к ресурсу, его метод и URL без query.
+	// Токен, привязанный к ключу (cnf.jkt), принимается только с доказательством.
+	DpopProof     string `protobuf:"bytes,3,opt,name=dpop_proof,json=dpopProof,proto3" json:"dpop_proof,omitempty"`
+	HttpMethod    string `protobuf:"bytes,4,opt,name=http_method,json=httpMethod,proto3" json:"http_method,omitempty"`
+	HttpUrl       string `protobuf:"bytes,5,opt,name=http_url,json=httpUrl,proto3" json:"http_url,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -65,6 +72,34 @@ func (x *TokenRequest) GetToken() string {
 	return ""
 }
 
+func (x *TokenRequest) GetAudience() string {
+	if x != nil {
+		return x.Audience
+	}
+	return ""
+}
+
+func (x *TokenRequest) GetDpopProof() string {
+	if x != nil {
+		return x.DpopProof
+	}
+	return ""
+}
+
+func (x *TokenRequest) GetHttpMethod() string {
+	if x != nil {
+		return x.HttpMethod
+	}
+	return ""
+}
+
+func (x *TokenRequest) GetHttpUrl() string {
+	if x != nil {
+		return x.HttpUrl
+	}
+	return ""
+}
+
 type TokenResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Valid         bool                   `protobuf:"varint,1,opt,name=valid,proto3" json:"valid,omitempty"`
@@ -117,6 +152,8 @@ func (x *TokenResponse) GetError() string {
 	return ""
 }
 
+// Для токена клиента (client_credentials) user_id не заполняется,
+// а error сообщает, что у токена нет пользователя.
 type UserIDResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	UserId        int32                  `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
@@ -169,22 +206,140 @@ func (x *UserIDResponse) GetError() string {
 	return ""
 }
 
+type ClaimsResponse struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	UserId    int32                  `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Roles     []string               `protobuf:"bytes,2,rep,name=roles,proto3" json:"roles,omitempty"`
+	Scopes    []string               `protobuf:"bytes,3,rep,name=scopes,proto3" json:"scopes,omitempty"`
+	ExpiresAt int64                  `protobuf:"varint,4,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	TokenId   string                 `protobuf:"bytes,5,opt,name=token_id,json=tokenId,proto3" json:"token_id,omitempty"`
+	Error     string                 `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+	// sub токена: id пользователя или client_id для токена клиента.
+	Subject       string `protobuf:"bytes,7,opt,name=subject,proto3" json:"subject,omitempty"`
+	ClientId      string `protobuf:"bytes,8,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClaimsResponse) Reset() {
+	*x = ClaimsResponse{}
+	mi := &file_internal_api_auth_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClaimsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClaimsResponse) ProtoMessage() {}
+
+func (x *ClaimsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_api_auth_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClaimsResponse.ProtoReflect.Descriptor instead.
+func (*ClaimsResponse) Descriptor() ([]byte, []int) {
+	return file_internal_api_auth_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ClaimsResponse) GetUserId() int32 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *ClaimsResponse) GetRoles() []string {
+	if x != nil {
+		return x.Roles
+	}
+	return nil
+}
+
+func (x *ClaimsResponse) GetScopes() []string {
+	if x != nil {
+		return x.Scopes
+	}
+	return nil
+}
+
+func (x *ClaimsResponse) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
+func (x *ClaimsResponse) GetTokenId() string {
+	if x != nil {
+		return x.TokenId
+	}
+	return ""
+}
+
+func (x *ClaimsResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *ClaimsResponse) GetSubject() string {
+	if x != nil {
+		return x.Subject
+	}
+	return ""
+}
+
+func (x *ClaimsResponse) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
 var File_internal_api_auth_proto protoreflect.FileDescriptor
 
 const file_internal_api_auth_proto_rawDesc = "" +
 	"\n" +
-	"\x17internal/api/auth.proto\x12\x04auth\"$\n" +
+	"\x17internal/api/auth.proto\x12\x04auth\"\x9b\x01\n" +
 	"\fTokenRequest\x12\x14\n" +
-	"\x05token\x18\x01 \x01(\tR\x05token\";\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12\x1a\n" +
+	"\baudience\x18\x02 \x01(\tR\baudience\x12\x1d\n" +
+	"\n" +
+	"dpop_proof\x18\x03 \x01(\tR\tdpopProof\x12\x1f\n" +
+	"\vhttp_method\x18\x04 \x01(\tR\n" +
+	"httpMethod\x12\x19\n" +
+	"\bhttp_url\x18\x05 \x01(\tR\ahttpUrl\";\n" +
 	"\rTokenResponse\x12\x14\n" +
 	"\x05valid\x18\x01 \x01(\bR\x05valid\x12\x14\n" +
 	"\x05error\x18\x02 \x01(\tR\x05error\"?\n" +
 	"\x0eUserIDResponse\x12\x17\n" +
 	"\auser_id\x18\x01 \x01(\x05R\x06userId\x12\x14\n" +
-	"\x05error\x18\x02 \x01(\tR\x05error2\x82\x01\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"\xde\x01\n" +
+	"\x0eClaimsResponse\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x05R\x06userId\x12\x14\n" +
+	"\x05roles\x18\x02 \x03(\tR\x05roles\x12\x16\n" +
+	"\x06scopes\x18\x03 \x03(\tR\x06scopes\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x04 \x01(\x03R\texpiresAt\x12\x19\n" +
+	"\btoken_id\x18\x05 \x01(\tR\atokenId\x12\x14\n" +
+	"\x05error\x18\x06 \x01(\tR\x05error\x12\x18\n" +
+	"\asubject\x18\a \x01(\tR\asubject\x12\x1b\n" +
+	"\tclient_id\x18\b \x01(\tR\bclientId2\xbb\x01\n" +
 	"\vAuthService\x12:\n" +
 	"\rValidateToken\x12\x12.auth.TokenRequest\x1a\x13.auth.TokenResponse\"\x00\x127\n" +
-	"\tGetUserID\x12\x12.auth.TokenRequest\x1a\x14.auth.UserIDResponse\"\x00B$Z\"github.com/fire9900/auth/pkg/g_rpcb\x06proto3"
+	"\tGetUserID\x12\x12.auth.TokenRequest\x1a\x14.auth.UserIDResponse\"\x00\x127\n" +
+	"\tGetClaims\x12\x12.auth.TokenRequest\x1a\x14.auth.ClaimsResponse\"\x00B$Z\"github.com/fire9900/auth/pkg/g_rpcb\x06proto3"
 
 var (
 	file_internal_api_auth_proto_rawDescOnce sync.Once
@@ -198,19 +353,22 @@ func file_internal_api_auth_proto_rawDescGZIP() []byte {
 	return file_internal_api_auth_proto_rawDescData
 }
 
-var file_internal_api_auth_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_internal_api_auth_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
 var file_internal_api_auth_proto_goTypes = []any{
 	(*TokenRequest)(nil),   // 0: auth.TokenRequest
 	(*TokenResponse)(nil),  // 1: auth.TokenResponse
 	(*UserIDResponse)(nil), // 2: auth.UserIDResponse
+	(*ClaimsResponse)(nil), // 3: auth.ClaimsResponse
 }
 var file_internal_api_auth_proto_depIdxs = []int32{
 	0, // 0: auth.AuthService.ValidateToken:input_type -> auth.TokenRequest
 	0, // 1: auth.AuthService.GetUserID:input_type -> auth.TokenRequest
-	1, // 2: auth.AuthService.ValidateToken:output_type -> auth.TokenResponse
-	2, // 3: auth.AuthService.GetUserID:output_type -> auth.UserIDResponse
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
+	0, // 2: auth.AuthService.GetClaims:input_type -> auth.TokenRequest
+	1, // 3: auth.AuthService.ValidateToken:output_type -> auth.TokenResponse
+	2, // 4: auth.AuthService.GetUserID:output_type -> auth.UserIDResponse
+	3, // 5: auth.AuthService.GetClaims:output_type -> auth.ClaimsResponse
+	3, // [3:6] is the sub-list for method output_type
+	0, // [0:3] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -227,7 +385,7 @@ func file_internal_api_auth_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_api_auth_proto_rawDesc), len(file_internal_api_auth_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   3,
+			NumMessages:   4,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenCodec - формат самодостаточных токенов. Encode подписывает или
+// шифрует claims, Decode проверяет подпись (MAC) и восстанавливает claims.
+// Проверку издателя, аудитории, сроков и типа токена делает Verifier
+// одинаково для всех форматов.
+type TokenCodec interface {
+	Encode(claims *Claims) (string, error)
+	Decode(token string) (*Claims, error)
+}
+
+// jwtCodec - формат JWT (RFC 7519) с kid и typ в заголовке.
+type jwtCodec struct {
+	keys *KeyRing
+}
+
+// NewJWTCodec создает формат JWT над связкой ключей. Это формат по умолчанию
+// для Signer и Verifier.
+func NewJWTCodec(keys *KeyRing) TokenCodec {
+	return &jwtCodec{keys: keys}
+}
+
+func (c *jwtCodec) Encode(claims *Claims) (string, error) {
+	key := c.keys.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	token.Header["typ"] = tokenHeaderTypes[claims.TokenType]
+	return token.SignedString(key.signKey)
+}
+
+// Decode проверяет подпись и соответствие заголовка typ типу токена.
+// Claims проверяет Verifier, поэтому парсер их не валидирует.
+func (c *jwtCodec) Decode(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, c.keyFunc, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, ErrorInvalidToken
+	}
+	if headerType, ok := tokenHeaderTypes[claims.TokenType]; !ok || token.Header["typ"] != headerType {
+		return nil, ErrorTokenType
+	}
+	return claims, nil
+}
+
+// keyFunc выбирает ключ по заголовку kid. Токены без kid, выпущенные до
+// появления связки ключей, проверяются активным ключом. Алгоритм токена
+// обязан совпадать с алгоритмом выбранного ключа.
+func (c *jwtCodec) keyFunc(token *jwt.Token) (interface{}, error) {
+	key := c.keys.Active()
+	if kid, ok := token.Header["kid"]; ok {
+		id, isString := kid.(string)
+		if !isString {
+			return nil, ErrorUnknownKey
+		}
+		if key, ok = c.keys.Lookup(id); !ok {
+			return nil, ErrorUnknownKey
+		}
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrorKeyAlgorithmType
+	}
+	return key.verifyKey, nil
+}
+
+// UseCodec переключает Signer на выпуск токенов в формате codec.
+// ID токены по-прежнему выпускаются как JWT.
+func (s *Signer) UseCodec(codec TokenCodec) {
+	s.codec = codec
+}
+
+// UseCodec переключает Verifier на прием токенов в формате codec. Токены
+// других форматов, кроме непрозрачных при заданном TokenStore, отклоняются.
+func (v *Verifier) UseCodec(codec TokenCodec) {
+	v.codec = codec
+}
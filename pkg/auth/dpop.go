@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrorDPoPProof   = errors.New("Недействительное DPoP доказательство")
+	ErrorDPoPReplay  = errors.New("DPoP доказательство уже использовано")
+	ErrorDPoPBinding = errors.New("Токен не соответствует ключу DPoP")
+)
+
+const (
+	// SchemeDPoP - схема заголовка Authorization для токенов, привязанных к ключу.
+	SchemeDPoP = "DPoP"
+	// DefaultDPoPWindow - допустимое расхождение iat доказательства с часами сервиса.
+	DefaultDPoPWindow = time.Minute
+
+	dpopProofType = "dpop+jwt"
+)
+
+// DPoPAlgorithms - алгоритмы подписи доказательств. Симметричные алгоритмы
+// не допускаются: доказательство подписывает закрытый ключ клиента.
+var DPoPAlgorithms = []string{"ES256", "RS256", "EdDSA"}
+
+// Confirmation - claim cnf (RFC 7800). JKT - отпечаток (RFC 7638) ключа,
+// к которому привязан токен (RFC 9449, 6.1).
+type Confirmation struct {
+	JKT string `json:"jkt"`
+}
+
+// dpopClaims - claims доказательства DPoP (RFC 9449, 4.2).
+type dpopClaims struct {
+	Method          string `json:"htm"`
+	URL             string `json:"htu"`
+	AccessTokenHash string `json:"ath,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// ReplayCache запоминает jti предъявленных доказательств, чтобы перехваченное
+// доказательство нельзя было предъявить повторно.
+type ReplayCache interface {
+	// Remember запоминает ключ до expiresAt и сообщает, встречался ли он раньше.
+	Remember(key string, expiresAt time.Time) bool
+}
+
+// MemoryReplayCache - ReplayCache в памяти процесса. Записи живут, пока
+// доказательство может пройти проверку iat, и удаляются при следующих обращениях.
+type MemoryReplayCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return &MemoryReplayCache{seen: make(map[string]time.Time), now: time.Now}
+}
+
+func (c *MemoryReplayCache) Remember(key string, expiresAt time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if now.Sub(c.lastSweep) > time.Minute {
+		for k, exp := range c.seen {
+			if now.After(exp) {
+				delete(c.seen, k)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if exp, ok := c.seen[key]; ok && !now.After(exp) {
+		return true
+	}
+	c.seen[key] = expiresAt
+	return false
+}
+
+// DPoPRequest - запрос к ресурсу, в котором предъявлен токен: схема
+// заголовка Authorization, заголовок DPoP, метод и URL запроса.
+type DPoPRequest struct {
+	Scheme string
+	Proof  string
+	Method string
+	URL    string
+}
+
+// DPoPVerifier проверяет доказательства владения ключом (RFC 9449).
+// window ограничивает расхождение iat доказательства с часами сервиса.
+type DPoPVerifier struct {
+	replay ReplayCache
+	window time.Duration
+	now    func() time.Time
+}
+
+func NewDPoPVerifier(replay ReplayCache, window time.Duration) *DPoPVerifier {
+	if window <= 0 {
+		window = DefaultDPoPWindow
+	}
+	return &DPoPVerifier{replay: replay, window: window, now: time.Now}
+}
+
+// VerifyProof проверяет доказательство для запроса method к requestURL и
+// возвращает отпечаток ключа, которым оно подписано. Непустой accessToken
+// означает запрос к ресурсу: доказательство должно содержать его хеш (ath).
+func (d *DPoPVerifier) VerifyProof(proof, method, requestURL, accessToken string) (string, error) {
+	if proof == "" {
+		return "", ErrorDPoPProof
+	}
+
+	var publicKey interface{}
+	claims := &dpopClaims{}
+	token, err := jwt.ParseWithClaims(proof, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Header["typ"] != dpopProofType {
+			return nil, ErrorDPoPProof
+		}
+		key, err := proofKey(token.Header["jwk"])
+		if err != nil {
+			return nil, err
+		}
+		publicKey = key
+		return key, nil
+	}, jwt.WithValidMethods(DPoPAlgorithms), jwt.WithoutClaimsValidation())
+	if err != nil || !token.Valid {
+		return "", errors.Join(ErrorDPoPProof, err)
+	}
+
+	now := d.now()
+	if claims.ID == "" || claims.IssuedAt == nil {
+		return "", ErrorDPoPProof
+	}
+	if iat := claims.IssuedAt.Time; iat.Before(now.Add(-d.window)) || iat.After(now.Add(d.window)) {
+		return "", ErrorDPoPProof
+	}
+	if claims.Method != method || !sameResource(claims.URL, requestURL) {
+		return "", ErrorDPoPProof
+	}
+	if accessToken != "" && claims.AccessTokenHash != tokenHash(accessToken) {
+		return "", ErrorDPoPProof
+	}
+
+	jkt, err := thumbprint(publicKey)
+	if err != nil {
+		return "", errors.Join(ErrorDPoPProof, err)
+	}
+	if d.replay.Remember(jkt+":"+claims.ID, claims.IssuedAt.Add(2*d.window)) {
+		return "", ErrorDPoPReplay
+	}
+	return jkt, nil
+}
+
+// VerifyBinding проверяет, что токен предъявлен так, как выпущен: токен,
+// привязанный к ключу, - только по схеме DPoP с доказательством этим ключом,
+// а обычный Bearer токен - без схемы DPoP. Nil DPoPVerifier означает,
+// что DPoP не поддерживается и привязанные токены не принимаются.
+func (d *DPoPVerifier) VerifyBinding(claims *Claims, accessToken string, req DPoPRequest) error {
+	dpop := strings.EqualFold(req.Scheme, SchemeDPoP)
+	if claims.Confirmation == nil {
+		if dpop {
+			return ErrorDPoPBinding
+		}
+		return nil
+	}
+	if !dpop || d == nil {
+		return ErrorDPoPBinding
+	}
+
+	jkt, err := d.VerifyProof(req.Proof, req.Method, req.URL, accessToken)
+	if err != nil {
+		return err
+	}
+	if jkt != claims.Confirmation.JKT {
+		return ErrorDPoPBinding
+	}
+	return nil
+}
+
+// proofKey извлекает открытый ключ из заголовка jwk доказательства.
+// Заголовок с закрытой частью ключа (d) отклоняется.
+func proofKey(header interface{}) (interface{}, error) {
+	members, ok := header.(map[string]interface{})
+	if !ok {
+		return nil, ErrorDPoPProof
+	}
+	if _, private := members["d"]; private {
+		return nil, ErrorDPoPProof
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return nil, ErrorDPoPProof
+	}
+	var jwk JWK
+	if err := json.Unmarshal(data, &jwk); err != nil {
+		return nil, ErrorDPoPProof
+	}
+	return jwk.publicKey()
+}
+
+// sameResource сравнивает htu с URL запроса без query и fragment
+// (RFC 9449, 4.3): схема и хост сравниваются без учета регистра.
+func sameResource(htu, requestURL string) bool {
+	a, errA := url.Parse(htu)
+	b, errB := url.Parse(requestURL)
+	if errA != nil || errB != nil || a.Host == "" {
+		return false
+	}
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host) && a.EscapedPath() == b.EscapedPath()
+}
+
+// tokenHash - значение ath: base64url от SHA-256 access токена.
+func tokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return encodeSegment(sum[:])
+}
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dpopProofParams struct {
+	method      string
+	url         string
+	accessToken string
+	issuedAt    time.Time
+	jti         string
+	typ         string
+}
+
+func signDPoPProof(t *testing.T, key *ecdsa.PrivateKey, p dpopProofParams) string {
+	jwk, ok := publicJWK(&key.PublicKey)
+	require.True(t, ok)
+	if p.typ == "" {
+		p.typ = dpopProofType
+	}
+	if p.jti == "" {
+		var err error
+		p.jti, err = NewTokenID()
+		require.NoError(t, err)
+	}
+	claims := &dpopClaims{
+		Method:           p.method,
+		URL:              p.url,
+		RegisteredClaims: jwt.RegisteredClaims{ID: p.jti, IssuedAt: jwt.NewNumericDate(p.issuedAt)},
+	}
+	if p.accessToken != "" {
+		claims.AccessTokenHash = tokenHash(p.accessToken)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = p.typ
+	token.Header["jwk"] = jwk
+	proof, err := token.SignedString(key)
+	require.NoError(t, err)
+	return proof
+}
+
+func TestDPoPVerifier_VerifyProof(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jkt, err := thumbprint(&key.PublicKey)
+	require.NoError(t, err)
+	now := time.Now()
+	const tokenURL = "https://auth.test/oauth2/token"
+
+	tests := []struct {
+		name        string
+		params      dpopProofParams
+		method      string
+		url         string
+		accessToken string
+		wantErr     error
+	}{
+		{name: "Valid", params: dpopProofParams{method: "POST", url: tokenURL, issuedAt: now}, method: "POST", url: tokenURL},
+		{name: "Query ignored", params: dpopProofParams{method: "POST", url: tokenURL, issuedAt: now}, method: "POST", url: tokenURL + "?a=1"},
+		{name: "Host case ignored", params: dpopProofParams{method: "POST", url: "https://AUTH.test/oauth2/token", issuedAt: now}, method: "POST", url: tokenURL},
+		{name: "Method mismatch", params: dpopProofParams{method: "GET", url: tokenURL, issuedAt: now}, method: "POST", url: tokenURL, wantErr: ErrorDPoPProof},
+		{name: "URL mismatch", params: dpopProofParams{method: "POST", url: "https://auth.test/api/v1/me", issuedAt: now}, method: "POST", url: tokenURL, wantErr: ErrorDPoPProof},
+		{name: "Stale", params: dpopProofParams{method: "POST", url: tokenURL, issuedAt: now.Add(-5 * time.Minute)}, method: "POST", url: tokenURL, wantErr: ErrorDPoPProof},
+		{name: "From future", params: dpopProofParams{method: "POST", url: tokenURL, issuedAt: now.Add(5 * time.Minute)}, method: "POST", url: tokenURL, wantErr: ErrorDPoPProof},
+		{name: "Wrong typ", params: dpopProofParams{method: "POST", url: tokenURL, issuedAt: now, typ: "JWT"}, method: "POST", url: tokenURL, wantErr: ErrorDPoPProof},
+		{name: "Missing ath", params: dpopProofParams{method: "GET", url: "https://auth.test/api/v1/me", issuedAt: now}, method: "GET", url: "https://auth.test/api/v1/me", accessToken: "token", wantErr: ErrorDPoPProof},
+		{name: "With ath", params: dpopProofParams{method: "GET", url: "https://auth.test/api/v1/me", issuedAt: now, accessToken: "token"}, method: "GET", url: "https://auth.test/api/v1/me", accessToken: "token"},
+		{name: "Foreign ath", params: dpopProofParams{method: "GET", url: "https://auth.test/api/v1/me", issuedAt: now, accessToken: "other"}, method: "GET", url: "https://auth.test/api/v1/me", accessToken: "token", wantErr: ErrorDPoPProof},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := NewDPoPVerifier(NewMemoryReplayCache(), time.Minute)
+			got, err := verifier.VerifyProof(signDPoPProof(t, key, tt.params), tt.method, tt.url, tt.accessToken)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, jkt, got)
+		})
+	}
+
+	t.Run("Replay", func(t *testing.T) {
+		verifier := NewDPoPVerifier(NewMemoryReplayCache(), time.Minute)
+		proof := signDPoPProof(t, key, dpopProofParams{method: "POST", url: tokenURL, issuedAt: now})
+		_, err := verifier.VerifyProof(proof, "POST", tokenURL, "")
+		require.NoError(t, err)
+		_, err = verifier.VerifyProof(proof, "POST", tokenURL, "")
+		assert.ErrorIs(t, err, ErrorDPoPReplay)
+	})
+
+	t.Run("Symmetric signature", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, &dpopClaims{Method: "POST", URL: tokenURL,
+			RegisteredClaims: jwt.RegisteredClaims{ID: "jti", IssuedAt: jwt.NewNumericDate(now)}})
+		token.Header["typ"] = dpopProofType
+		proof, err := token.SignedString([]byte("0123456789abcdef0123456789abcdef"))
+		require.NoError(t, err)
+		_, err = NewDPoPVerifier(NewMemoryReplayCache(), time.Minute).VerifyProof(proof, "POST", tokenURL, "")
+		assert.ErrorIs(t, err, ErrorDPoPProof)
+	})
+
+	t.Run("Private key in header", func(t *testing.T) {
+		_, err := proofKey(map[string]interface{}{"kty": "EC", "crv": "P-256", "x": "x", "y": "y", "d": "d"})
+		assert.ErrorIs(t, err, ErrorDPoPProof)
+	})
+}
+
+func TestDPoPVerifier_VerifyBinding(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jkt, err := thumbprint(&key.PublicKey)
+	require.NoError(t, err)
+	const resource = "https://auth.test/api/v1/me"
+
+	ring := newTestKeyRing(t)
+	signer := NewSigner(ring, testTokenConfig)
+	bound, _, err := signer.GenerateAccessToken(TokenParams{UserID: 1, JKT: jkt})
+	require.NoError(t, err)
+	bearer, _, err := signer.GenerateAccessToken(TokenParams{UserID: 1})
+	require.NoError(t, err)
+
+	verifier := NewVerifier(ring, testTokenConfig)
+	boundClaims, err := verifier.ValidateAccessToken(bound)
+	require.NoError(t, err)
+	require.NotNil(t, boundClaims.Confirmation)
+	assert.Equal(t, jkt, boundClaims.Confirmation.JKT)
+	bearerClaims, err := verifier.ValidateAccessToken(bearer)
+	require.NoError(t, err)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	proof := func(key *ecdsa.PrivateKey, token string) string {
+		return signDPoPProof(t, key, dpopProofParams{method: "GET", url: resource, issuedAt: time.Now(), accessToken: token})
+	}
+
+	dpop := NewDPoPVerifier(NewMemoryReplayCache(), time.Minute)
+	tests := []struct {
+		name     string
+		verifier *DPoPVerifier
+		claims   *Claims
+		token    string
+		req      DPoPRequest
+		wantErr  error
+	}{
+		{name: "Bound with proof", verifier: dpop, claims: boundClaims, token: bound,
+			req: DPoPRequest{Scheme: "DPoP", Proof: proof(key, bound), Method: "GET", URL: resource}},
+		{name: "Bound as bearer", verifier: dpop, claims: boundClaims, token: bound,
+			req: DPoPRequest{Scheme: "Bearer", Proof: proof(key, bound), Method: "GET", URL: resource}, wantErr: ErrorDPoPBinding},
+		{name: "Bound without proof", verifier: dpop, claims: boundClaims, token: bound,
+			req: DPoPRequest{Scheme: "DPoP", Method: "GET", URL: resource}, wantErr: ErrorDPoPProof},
+		{name: "Bound with foreign key", verifier: dpop, claims: boundClaims, token: bound,
+			req: DPoPRequest{Scheme: "DPoP", Proof: proof(otherKey, bound), Method: "GET", URL: resource}, wantErr: ErrorDPoPBinding},
+		{name: "Bound without DPoP support", claims: boundClaims, token: bound,
+			req: DPoPRequest{Scheme: "DPoP", Proof: proof(key, bound), Method: "GET", URL: resource}, wantErr: ErrorDPoPBinding},
+		{name: "Bearer", verifier: dpop, claims: bearerClaims, token: bearer, req: DPoPRequest{Scheme: "Bearer"}},
+		{name: "Bearer as DPoP", verifier: dpop, claims: bearerClaims, token: bearer,
+			req: DPoPRequest{Scheme: "DPoP", Proof: proof(key, bearer), Method: "GET", URL: resource}, wantErr: ErrorDPoPBinding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.verifier.VerifyBinding(tt.claims, tt.token, tt.req)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"strconv"
+	"time"
+)
+
+// ErrorSymmetricIDToken - ID токен нельзя подписать секретом сервиса:
+// проверяющая сторона не найдет его в JWKS, а получив секрет, смогла бы
+// подделывать access и refresh токены.
+var ErrorSymmetricIDToken = errors.New("ID токен подписывается только ключом RS256, ES256 или EdDSA")
+
+// IDTokenClaims - claims ID токена OpenID Connect. ID токен не несет
+// token_type и заголовок at+jwt, поэтому не принимается как access токен.
+type IDTokenClaims struct {
+	Email    string           `json:"email,omitempty"`
+	Name     string           `json:"name,omitempty"`
+	Nonce    string           `json:"nonce,omitempty"`
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// IDTokenParams - данные ID токена. Audience - client_id клиента,
+// пустое значение означает аудиторию access токенов из TokenConfig.
+type IDTokenParams struct {
+	UserID   int
+	Audience string
+	Email    string
+	Name     string
+	Nonce    string
+	AuthTime time.Time
+	TTL      time.Duration
+}
+
+func (s *Signer) GenerateIDToken(params IDTokenParams) (string, error) {
+	key := s.keys.Active()
+	if key.Symmetric() {
+		return "", ErrorSymmetricIDToken
+	}
+
+	audience := params.Audience
+	if audience == "" {
+		audience = s.config.Audience
+	}
+	ttl := params.TTL
+	if ttl <= 0 {
+		ttl = DefaultAccessTTL
+	}
+	now := time.Now()
+
+	claims := &IDTokenClaims{
+		Email:    params.Email,
+		Name:     params.Name,
+		Nonce:    params.Nonce,
+		AuthTime: jwt.NewNumericDate(params.AuthTime),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.config.Issuer,
+			Subject:   strconv.Itoa(params.UserID),
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey)
+}
+
+// IDTokensSupported сообщает, что активный ключ асимметричный и выданный
+// ID токен можно проверить по JWKS.
+func (s *Signer) IDTokensSupported() bool {
+	return !s.keys.Active().Symmetric()
+}
+
+// IDTokensSupported сообщает, что OpenID Connect доступен: см. Signer.IDTokensSupported.
+func (v *Verifier) IDTokensSupported() bool {
+	return !v.keys.Active().Symmetric()
+}
+
+// SigningAlgorithm - алгоритм, которым сейчас подписываются токены.
+func (v *Verifier) SigningAlgorithm() string {
+	return v.keys.Active().Method.Alg()
+}
+
+func (v *Verifier) Issuer() string {
+	return v.config.Issuer
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner_GenerateIDToken(t *testing.T) {
+	ring := newTestECKeyRing(t)
+	signer := NewSigner(ring, testTokenConfig)
+	verifier := NewVerifier(ring, testTokenConfig)
+	authTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	token, err := signer.GenerateIDToken(IDTokenParams{
+		UserID:   7,
+		Audience: "web",
+		Email:    "user@example.com",
+		Name:     "User",
+		Nonce:    "n-0S6_WzA2Mj",
+		AuthTime: authTime,
+	})
+	require.NoError(t, err)
+
+	claims := &IDTokenClaims{}
+	_, err = jwt.ParseWithClaims(token, claims, (&jwtCodec{keys: ring}).keyFunc,
+		jwt.WithIssuer(testTokenConfig.Issuer), jwt.WithAudience("web"))
+	require.NoError(t, err)
+	assert.Equal(t, "7", claims.Subject)
+	assert.Equal(t, "user@example.com", claims.Email)
+	assert.Equal(t, "User", claims.Name)
+	assert.Equal(t, "n-0S6_WzA2Mj", claims.Nonce)
+	assert.True(t, claims.AuthTime.Time.Equal(authTime))
+
+	t.Run("not accepted as access token", func(t *testing.T) {
+		_, err := verifier.ValidateAccessTokenFor(token, "web")
+		assert.Error(t, err)
+	})
+
+	t.Run("not signed with the service secret", func(t *testing.T) {
+		signer := NewSigner(newTestKeyRing(t), testTokenConfig)
+		assert.False(t, signer.IDTokensSupported())
+		_, err := signer.GenerateIDToken(IDTokenParams{UserID: 7, Audience: "web"})
+		assert.ErrorIs(t, err, ErrorSymmetricIDToken)
+	})
+}
@@ -3,61 +3,285 @@ package auth
 import (
 	"errors"
 	"github.com/golang-jwt/jwt/v5"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
 var (
 	ErrorInvalidToken = errors.New("Неактуальный токен")
-	secretKey         = []byte("q4t7w!z%C*F-JaN19RgUkXp2s5v8y/B?E")
+	ErrorUnknownKey   = errors.New("Неизвестный ключ подписи токена")
+	ErrorTokenType    = errors.New("Неверный тип токена")
+	ErrorNoUser       = errors.New("Токен выпущен клиенту, а не пользователю")
 )
 
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+// Значения заголовка typ: для access токенов по RFC 9068, чтобы токены
+// разных типов различались еще до разбора claims.
+var tokenHeaderTypes = map[string]string{
+	TokenTypeAccess:  "at+jwt",
+	TokenTypeRefresh: "refresh+jwt",
+}
+
 type Claims struct {
-	UserID int `json:"user_id"`
+	UserID       int           `json:"user_id"`
+	TokenType    string        `json:"token_type"`
+	SessionID    string        `json:"sid,omitempty"`
+	TokenVersion int           `json:"ver,omitempty"`
+	ClientID     string        `json:"client_id,omitempty"`
+	Roles        []string      `json:"roles,omitempty"`
+	Scope        string        `json:"scope,omitempty"`
+	Actor        *Actor        `json:"act,omitempty"`
+	Confirmation *Confirmation `json:"cnf,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateAccessToken(userID int) (string, int64, error) {
-	expirationTime := time.Now().Add(15 * time.Minute)
+// Actor - claim act (RFC 8693, 4.1): сторона, действующая от имени субъекта
+// токена. Вложенный Actor описывает предыдущих участников цепочки делегирования.
+type Actor struct {
+	Subject  string `json:"sub"`
+	ClientID string `json:"client_id,omitempty"`
+	Actor    *Actor `json:"act,omitempty"`
+}
+
+// Scopes возвращает области доступа из claim scope (через пробел, как в RFC 8693).
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// IsClient сообщает, что токен представляет сам клиент (grant
+// client_credentials): у него нет пользователя, а sub равен client_id.
+func (c *Claims) IsClient() bool {
+	return c.UserID == 0
+}
+
+// subject - значение sub: id пользователя или client_id для токенов клиента.
+func (c *Claims) subject() string {
+	if c.IsClient() {
+		return c.ClientID
+	}
+	return strconv.Itoa(c.UserID)
+}
+
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes(), scope)
+}
+
+// TokenConfig задает регистрируемые claims: Issuer попадает в iss всех токенов,
+// Audience - в aud access токенов. Refresh токены адресованы самому сервису
+// (aud = Issuer), поэтому их не примет ни один потребитель access токенов.
+// Leeway - допустимое расхождение часов при проверке exp, nbf и iat.
+type TokenConfig struct {
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+}
+
+// TokenParams - данные, из которых собираются claims выпускаемого токена.
+// SessionID связывает access токен с семейством refresh токенов.
+// Нулевой UserID означает токен самого клиента ClientID.
+// Audience и Actor используются только в access токенах: пустой Audience
+// означает аудиторию по умолчанию из TokenConfig.
+// Непустой JKT привязывает токены к ключу клиента по DPoP (claim cnf).
+// TokenVersion - версия токенов пользователя на момент выпуска (claim ver).
+// Нулевой TTL означает срок жизни по умолчанию для типа токена.
+type TokenParams struct {
+	UserID       int
+	SessionID    string
+	TokenVersion int
+	ClientID     string
+	Roles        []string
+	Scopes       []string
+	Audience     string
+	Actor        *Actor
+	JKT          string
+	TTL          time.Duration
+}
+
+type Signer struct {
+	keys   *KeyRing
+	config TokenConfig
+	codec  TokenCodec
+	store  TokenStore
+}
+
+func NewSigner(keys *KeyRing, config TokenConfig) *Signer {
+	return &Signer{keys: keys, config: config, codec: NewJWTCodec(keys)}
+}
+
+// GenerateAccessToken выпускает access токен. Роли и области доступа
+// попадают только в access токен: refresh токен их не несет, и при
+// обновлении они берутся заново из учетной записи.
+func (s *Signer) GenerateAccessToken(params TokenParams) (string, int64, error) {
+	audience := params.Audience
+	if audience == "" {
+		audience = s.config.Audience
+	}
+	return s.generate(params, TokenTypeAccess, audience, DefaultAccessTTL)
+}
+
+func (s *Signer) GenerateRefreshToken(params TokenParams) (string, int64, error) {
+	return s.generate(params, TokenTypeRefresh, s.config.Issuer, DefaultRefreshTTL)
+}
+
+func (s *Signer) generate(params TokenParams, tokenType, audience string, defaultTTL time.Duration) (string, int64, error) {
+	ttl := params.TTL
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	now := time.Now()
+	expirationTime := now.Add(ttl)
+
+	tokenID, err := NewTokenID()
+	if err != nil {
+		return "", 0, err
+	}
 
 	claim := &Claims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-		},
+		UserID:       params.UserID,
+		TokenType:    tokenType,
+		SessionID:    params.SessionID,
+		TokenVersion: params.TokenVersion,
+		ClientID:     params.ClientID,
+	}
+	if tokenType == TokenTypeAccess {
+		claim.Roles = params.Roles
+		claim.Scope = strings.Join(params.Scopes, " ")
+		claim.Actor = params.Actor
+	}
+	if params.JKT != "" {
+		claim.Confirmation = &Confirmation{JKT: params.JKT}
+	}
+	claim.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:    s.config.Issuer,
+		Subject:   claim.subject(),
+		Audience:  jwt.ClaimStrings{audience},
+		ExpiresAt: jwt.NewNumericDate(expirationTime),
+		NotBefore: jwt.NewNumericDate(now),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ID:        tokenID,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claim)
-	tokenString, err := token.SignedString(secretKey)
+	if s.store != nil {
+		tokenString, err := s.store.StoreToken(claim)
+		return tokenString, expirationTime.Unix(), err
+	}
+	tokenString, err := s.codec.Encode(claim)
 	return tokenString, expirationTime.Unix(), err
 }
 
-func GenerateRefreshToken(userID int) (string, error) {
-	expirationTime := time.Now().Add(7 * 24 * time.Hour)
+type Verifier struct {
+	keys   *KeyRing
+	config TokenConfig
+	codec  TokenCodec
+	store  TokenStore
+}
 
-	claim := &Claims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-		},
+func NewVerifier(keys *KeyRing, config TokenConfig) *Verifier {
+	return &Verifier{keys: keys, config: config, codec: NewJWTCodec(keys)}
+}
+
+// ValidateAccessToken принимает только access токены: refresh токен
+// не может использоваться для доступа к API.
+func (v *Verifier) ValidateAccessToken(tokenString string) (*Claims, error) {
+	return v.validate(tokenString, TokenTypeAccess, v.config.Audience)
+}
+
+// ValidateAccessTokenFor проверяет access токен для конкретного получателя.
+// Пустой audience означает аудиторию по умолчанию из TokenConfig.
+func (v *Verifier) ValidateAccessTokenFor(tokenString, audience string) (*Claims, error) {
+	if audience == "" {
+		audience = v.config.Audience
 	}
+	return v.validate(tokenString, TokenTypeAccess, audience)
+}
+
+// ValidateRefreshToken принимает только refresh токены: access токен
+// не может использоваться для обновления пары токенов.
+func (v *Verifier) ValidateRefreshToken(tokenString string) (*Claims, error) {
+	return v.validate(tokenString, TokenTypeRefresh, v.config.Issuer)
+}
+
+// ValidateExchangeToken принимает access токен для любой аудитории. Нужен
+// обмену токенов (RFC 8693): сервис может обменять токен, выпущенный для него
+// самого, на токен для следующего сервиса в цепочке.
+func (v *Verifier) ValidateExchangeToken(tokenString string) (*Claims, error) {
+	return v.validate(tokenString, TokenTypeAccess, "")
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claim)
-	return token.SignedString(secretKey)
+// Inspect проверяет подпись, издателя и сроки токена любого типа без
+// ограничения аудитории. Предназначен для интроспекции: решение о том,
+// подходит ли токен получателю, принимает сам получатель по aud и token_type.
+func (v *Verifier) Inspect(tokenString string) (*Claims, error) {
+	return v.validate(tokenString, "", "")
 }
 
-func ValidateToken(tokenString string) (*Claims, error) {
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return secretKey, nil
-	})
+// validate проверяет токен. Пустой tokenType допускает любой известный тип,
+// пустой audience отключает проверку аудитории. Формат токена проверяет
+// подпись, а claims проверяются одинаково для всех форматов.
+func (v *Verifier) validate(tokenString, tokenType, audience string) (*Claims, error) {
+	if v.store != nil && IsOpaqueToken(tokenString) {
+		return v.resolve(tokenString, tokenType, audience)
+	}
 
+	claims, err := v.codec.Decode(tokenString)
 	if err != nil {
 		return nil, err
 	}
+	if err := jwt.NewValidator(v.options(audience)...).Validate(claims); err != nil {
+		return nil, err
+	}
 
-	if !token.Valid {
+	if tokenType != "" && claims.TokenType != tokenType {
+		return nil, ErrorTokenType
+	}
+	if _, ok := tokenHeaderTypes[claims.TokenType]; !ok {
+		return nil, ErrorTokenType
+	}
+
+	if !claims.consistent() {
 		return nil, ErrorInvalidToken
 	}
 
 	return claims, nil
 }
+
+func (v *Verifier) options(audience string) []jwt.ParserOption {
+	options := []jwt.ParserOption{
+		jwt.WithIssuer(v.config.Issuer),
+		jwt.WithLeeway(v.config.Leeway),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	}
+	if audience != "" {
+		options = append(options, jwt.WithAudience(audience))
+	}
+	return options
+}
+
+// consistent проверяет claims, без которых токен не выпускается: jti, nbf
+// и sub, совпадающий с пользователем или клиентом токена.
+func (c *Claims) consistent() bool {
+	return c.ID != "" && c.NotBefore != nil && c.Subject != "" && c.Subject == c.subject()
+}
+
+// JWKS возвращает открытые ключи для офлайн-проверки токенов другими сервисами,
+// включая выведенные из оборота ключи, которыми еще подписаны живые токены.
+func (v *Verifier) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range v.keys.Keys() {
+		if jwk, ok := key.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testTokenConfig = TokenConfig{
+	Issuer:   "https://auth.test",
+	Audience: "api",
+	Leeway:   time.Second,
+}
+
+func newTestKeyRing(t *testing.T) *KeyRing {
+	key, err := newKey("test", "HS256", []byte("0123456789abcdef0123456789abcdef"), nil)
+	require.NoError(t, err)
+	return NewKeyRing(key)
+}
+
+// newTestECKeyRing - связка из одного ключа ES256, которым можно подписывать ID токены.
+func newTestECKeyRing(t *testing.T) *KeyRing {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	key, err := newKey("", "ES256", nil, encodePrivateKey(t, private))
+	require.NoError(t, err)
+	return NewKeyRing(key)
+}
+
+func TestVerifier_TokenTypes(t *testing.T) {
+	ring := newTestKeyRing(t)
+	signer, verifier := NewSigner(ring, testTokenConfig), NewVerifier(ring, testTokenConfig)
+
+	accessToken, _, err := signer.GenerateAccessToken(TokenParams{UserID: 1})
+	require.NoError(t, err)
+	refreshToken, _, err := signer.GenerateRefreshToken(TokenParams{UserID: 1})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		token    string
+		validate func(string) (*Claims, error)
+		wantErr  bool
+	}{
+		{name: "Access as access", token: accessToken, validate: verifier.ValidateAccessToken},
+		{name: "Refresh as refresh", token: refreshToken, validate: verifier.ValidateRefreshToken},
+		{name: "Refresh as access", token: refreshToken, validate: verifier.ValidateAccessToken, wantErr: true},
+		{name: "Access as refresh", token: accessToken, validate: verifier.ValidateRefreshToken, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := tt.validate(tt.token)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, 1, claims.UserID)
+		})
+	}
+}
+
+func TestVerifier_RegisteredClaims(t *testing.T) {
+	ring := newTestKeyRing(t)
+	signer := NewSigner(ring, testTokenConfig)
+
+	accessToken, _, err := signer.GenerateAccessToken(TokenParams{UserID: 9})
+	require.NoError(t, err)
+
+	claims, err := NewVerifier(ring, testTokenConfig).ValidateAccessToken(accessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "https://auth.test", claims.Issuer)
+	assert.Equal(t, "9", claims.Subject)
+	assert.Equal(t, jwt.ClaimStrings{"api"}, claims.Audience)
+	assert.NotEmpty(t, claims.ID)
+	assert.NotNil(t, claims.IssuedAt)
+	assert.NotNil(t, claims.NotBefore)
+
+	tests := []struct {
+		name     string
+		config   TokenConfig
+		audience string
+		wantErr  error
+	}{
+		{name: "Foreign issuer", config: TokenConfig{Issuer: "https://other.test", Audience: "api"}, wantErr: jwt.ErrTokenInvalidIssuer},
+		{name: "Foreign audience", config: TokenConfig{Issuer: "https://auth.test", Audience: "billing"}, wantErr: jwt.ErrTokenInvalidAudience},
+		{name: "Requested audience", config: testTokenConfig, audience: "billing", wantErr: jwt.ErrTokenInvalidAudience},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewVerifier(ring, tt.config).ValidateAccessTokenFor(accessToken, tt.audience)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestVerifier_Leeway(t *testing.T) {
+	ring := newTestKeyRing(t)
+	key := ring.Active()
+
+	sign := func(notBefore time.Time) string {
+		claims := &Claims{
+			UserID:    1,
+			TokenType: TokenTypeAccess,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    testTokenConfig.Issuer,
+				Subject:   "1",
+				Audience:  jwt.ClaimStrings{testTokenConfig.Audience},
+				ExpiresAt: jwt.NewNumericDate(notBefore.Add(time.Minute)),
+				NotBefore: jwt.NewNumericDate(notBefore),
+				IssuedAt:  jwt.NewNumericDate(notBefore),
+				ID:        "jti",
+			},
+		}
+		token := jwt.NewWithClaims(key.Method, claims)
+		token.Header["kid"] = key.ID
+		token.Header["typ"] = "at+jwt"
+		signed, err := token.SignedString(key.signKey)
+		require.NoError(t, err)
+		return signed
+	}
+
+	config := testTokenConfig
+	config.Leeway = 10 * time.Second
+	verifier := NewVerifier(ring, config)
+
+	_, err := verifier.ValidateAccessToken(sign(time.Now().Add(5 * time.Second)))
+	assert.NoError(t, err, "расхождение часов в пределах leeway допустимо")
+
+	_, err = verifier.ValidateAccessToken(sign(time.Now().Add(time.Minute)))
+	assert.ErrorIs(t, err, jwt.ErrTokenNotValidYet)
+}
+
+func TestVerifier_TokenTypeWithSharedAudience(t *testing.T) {
+	ring := newTestKeyRing(t)
+	config := TokenConfig{Issuer: "https://auth.test", Audience: "https://auth.test"}
+	signer, verifier := NewSigner(ring, config), NewVerifier(ring, config)
+
+	refreshToken, _, err := signer.GenerateRefreshToken(TokenParams{UserID: 1})
+	require.NoError(t, err)
+
+	_, err = verifier.ValidateAccessToken(refreshToken)
+	assert.ErrorIs(t, err, ErrorTokenType)
+}
+
+func TestVerifier_ClientToken(t *testing.T) {
+	ring := newTestKeyRing(t)
+	signer := NewSigner(ring, testTokenConfig)
+	verifier := NewVerifier(ring, testTokenConfig)
+
+	token, _, err := signer.GenerateAccessToken(TokenParams{ClientID: "worker"})
+	require.NoError(t, err)
+
+	claims, err := verifier.ValidateAccessToken(token)
+	require.NoError(t, err)
+	assert.True(t, claims.IsClient())
+	assert.Equal(t, "worker", claims.Subject)
+
+	t.Run("without client id", func(t *testing.T) {
+		token, _, err := signer.GenerateAccessToken(TokenParams{})
+		require.NoError(t, err)
+		_, err = verifier.ValidateAccessToken(token)
+		assert.ErrorIs(t, err, ErrorInvalidToken)
+	})
+}
+
+func TestSigner_ExchangedToken(t *testing.T) {
+	ring := newTestKeyRing(t)
+	signer := NewSigner(ring, testTokenConfig)
+	verifier := NewVerifier(ring, testTokenConfig)
+
+	actor := &Actor{Subject: "gateway", Actor: &Actor{Subject: "7", ClientID: "spa"}}
+	token, _, err := signer.GenerateAccessToken(TokenParams{
+		UserID:   7,
+		ClientID: "gateway",
+		Scopes:   []string{"orders:read"},
+		Audience: "orders",
+		Actor:    actor,
+	})
+	require.NoError(t, err)
+
+	_, err = verifier.ValidateAccessToken(token)
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidAudience)
+
+	claims, err := verifier.ValidateAccessTokenFor(token, "orders")
+	require.NoError(t, err)
+	assert.Equal(t, actor, claims.Actor)
+	assert.Equal(t, "7", claims.Subject)
+
+	claims, err = verifier.ValidateExchangeToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, jwt.ClaimStrings{"orders"}, claims.Audience)
+
+	t.Run("Refresh token has no actor", func(t *testing.T) {
+		token, _, err := signer.GenerateRefreshToken(TokenParams{UserID: 7, Actor: actor})
+		require.NoError(t, err)
+		claims, err := verifier.ValidateRefreshToken(token)
+		require.NoError(t, err)
+		assert.Nil(t, claims.Actor)
+	})
+}
+
+func TestSigner_TokenVersion(t *testing.T) {
+	ring := newTestKeyRing(t)
+	signer := NewSigner(ring, testTokenConfig)
+	verifier := NewVerifier(ring, testTokenConfig)
+
+	accessToken, _, err := signer.GenerateAccessToken(TokenParams{UserID: 7, TokenVersion: 3})
+	require.NoError(t, err)
+	refreshToken, _, err := signer.GenerateRefreshToken(TokenParams{UserID: 7, TokenVersion: 3})
+	require.NoError(t, err)
+
+	claims, err := verifier.ValidateAccessToken(accessToken)
+	require.NoError(t, err)
+	assert.Equal(t, 3, claims.TokenVersion)
+
+	claims, err = verifier.ValidateRefreshToken(refreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, 3, claims.TokenVersion)
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type ringKey struct {
+	key      *Key
+	retireAt time.Time
+}
+
+// KeyRing хранит активный ключ подписи и выведенные из оборота ключи,
+// которые еще принимаются при проверке, пока не истекут выданные ими токены.
+type KeyRing struct {
+	mu     sync.RWMutex
+	active *Key
+	keys   map[string]ringKey
+	now    func() time.Time
+}
+
+func NewKeyRing(active *Key) *KeyRing {
+	return &KeyRing{
+		active: active,
+		keys:   map[string]ringKey{active.ID: {key: active}},
+		now:    time.Now,
+	}
+}
+
+// AddRetired добавляет ключ, который используется только для проверки до retireAt.
+func (r *KeyRing) AddRetired(key *Key, retireAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if key.ID == r.active.ID {
+		return
+	}
+	r.keys[key.ID] = ringKey{key: key, retireAt: retireAt}
+}
+
+func (r *KeyRing) Active() *Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.active
+}
+
+// Lookup ищет ключ по kid. Ключи с наступившим сроком вывода не возвращаются.
+func (r *KeyRing) Lookup(kid string) (*Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entry, ok := r.keys[kid]
+	if !ok || r.expired(entry) {
+		return nil, false
+	}
+	return entry.key, true
+}
+
+// Replace атомарно подменяет содержимое связки, например после перечитывания хранилища.
+func (r *KeyRing) Replace(other *KeyRing) {
+	other.mu.RLock()
+	active, keys := other.active, make(map[string]ringKey, len(other.keys))
+	for kid, entry := range other.keys {
+		keys[kid] = entry
+	}
+	other.mu.RUnlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active, r.keys = active, keys
+}
+
+// Keys возвращает все ключи, пригодные для проверки, начиная с активного.
+func (r *KeyRing) Keys() []*Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	keys := make([]*Key, 0, len(r.keys))
+	for _, entry := range r.keys {
+		if !r.expired(entry) {
+			keys = append(keys, entry.key)
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i] == r.active || keys[j] == r.active {
+			return keys[i] == r.active
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys
+}
+
+func (r *KeyRing) expired(entry ringKey) bool {
+	return !entry.retireAt.IsZero() && !r.now().Before(entry.retireAt)
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyRing_Retired(t *testing.T) {
+	first, err := newKey("first", "HS256", []byte("0123456789abcdef0123456789abcdef"), nil)
+	require.NoError(t, err)
+	second, err := newKey("second", "HS256", []byte("fedcba9876543210fedcba9876543210"), nil)
+	require.NoError(t, err)
+
+	now := time.Now()
+	oldToken, _, err := NewSigner(NewKeyRing(first), testTokenConfig).GenerateAccessToken(TokenParams{UserID: 1})
+	require.NoError(t, err)
+
+	ring := NewKeyRing(second)
+	ring.AddRetired(first, now.Add(time.Hour))
+	ring.now = func() time.Time { return now }
+	signer, verifier := NewSigner(ring, testTokenConfig), NewVerifier(ring, testTokenConfig)
+
+	newToken, _, err := signer.GenerateAccessToken(TokenParams{UserID: 2})
+	require.NoError(t, err)
+
+	_, err = verifier.ValidateAccessToken(oldToken)
+	assert.NoError(t, err, "ключ в выводе из оборота должен принимать старые токены")
+	_, err = verifier.ValidateAccessToken(newToken)
+	assert.NoError(t, err)
+
+	now = now.Add(2 * time.Hour)
+	_, err = verifier.ValidateAccessToken(oldToken)
+	assert.ErrorIs(t, err, ErrorUnknownKey)
+	_, err = verifier.ValidateAccessToken(newToken)
+	assert.NoError(t, err)
+}
+
+func TestRotateKeystore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keystore.json")
+
+	firstKID, err := RotateKeystore(path, "ES256", time.Hour)
+	require.NoError(t, err)
+	ring, err := LoadKeyRing(KeyConfig{KeystoreFile: path})
+	require.NoError(t, err)
+	token, _, err := NewSigner(ring, testTokenConfig).GenerateAccessToken(TokenParams{UserID: 7})
+	require.NoError(t, err)
+
+	secondKID, err := RotateKeystore(path, "", time.Hour)
+	require.NoError(t, err)
+	assert.NotEqual(t, firstKID, secondKID)
+
+	ring, err = LoadKeyRing(KeyConfig{KeystoreFile: path})
+	require.NoError(t, err)
+	assert.Equal(t, secondKID, ring.Active().ID)
+	assert.Len(t, NewVerifier(ring, testTokenConfig).JWKS().Keys, 2)
+
+	claims, err := NewVerifier(ring, testTokenConfig).ValidateAccessToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, 7, claims.UserID)
+}
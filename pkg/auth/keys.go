@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"os"
+	"strings"
+	"time"
+)
+
+const minSecretLength = 32
+
+var (
+	ErrorNoSigningKey     = errors.New("Не задан ключ подписи токенов")
+	ErrorWeakSigningKey   = errors.New("Ключ подписи токенов короче 32 байт")
+	ErrorUnsupportedAlg   = errors.New("Неподдерживаемый алгоритм подписи токенов")
+	ErrorKeyAlgorithmType = errors.New("Тип ключа не соответствует алгоритму подписи")
+)
+
+// KeyConfig описывает, откуда брать ключ подписи: из значения переменной
+// окружения, из файла с секретом или из файла хранилища ключей.
+// Источники проверяются в порядке Secret, SecretFile, PrivateKeyFile, KeystoreFile.
+// Algorithm задает алгоритм подписи: HS256 (по умолчанию), RS256, ES256 или EdDSA.
+type KeyConfig struct {
+	Algorithm      string
+	Secret         string
+	SecretFile     string
+	PrivateKeyFile string
+	KeystoreFile   string
+}
+
+// Key - ключ подписи вместе с алгоритмом. Для HS256 ключ подписи и проверки
+// совпадают, для асимметричных алгоритмов проверка идет по открытому ключу.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// LoadKeyRing загружает ключи подписи. Одиночный ключ из переменной окружения
+// или файла образует связку из одного ключа; хранилище ключей может содержать
+// активный ключ и выведенные из оборота ключи для проверки старых токенов.
+func LoadKeyRing(cfg KeyConfig) (*KeyRing, error) {
+	var key *Key
+	var err error
+	switch {
+	case cfg.Secret != "":
+		key, err = newKey("", cfg.Algorithm, []byte(cfg.Secret), nil)
+	case cfg.SecretFile != "":
+		data, readErr := os.ReadFile(cfg.SecretFile)
+		if readErr != nil {
+			return nil, fmt.Errorf("ошибка чтения файла ключа: %w", readErr)
+		}
+		key, err = newKey("", cfg.Algorithm, []byte(strings.TrimSpace(string(data))), nil)
+	case cfg.PrivateKeyFile != "":
+		data, readErr := os.ReadFile(cfg.PrivateKeyFile)
+		if readErr != nil {
+			return nil, fmt.Errorf("ошибка чтения файла закрытого ключа: %w", readErr)
+		}
+		key, err = newKey("", cfg.Algorithm, nil, data)
+	case cfg.KeystoreFile != "":
+		ks, readErr := readKeystore(cfg.KeystoreFile)
+		if readErr != nil {
+			return nil, readErr
+		}
+		return ks.keyRing(cfg.Algorithm, time.Now())
+	default:
+		return nil, ErrorNoSigningKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyRing(key), nil
+}
+
+func newKey(kid, alg string, secret, privatePEM []byte) (*Key, error) {
+	if alg == "" {
+		alg = jwt.SigningMethodHS256.Alg()
+	}
+
+	key := &Key{ID: kid}
+	switch alg {
+	case jwt.SigningMethodHS256.Alg():
+		if len(secret) == 0 {
+			return nil, ErrorNoSigningKey
+		}
+		if len(secret) < minSecretLength {
+			return nil, ErrorWeakSigningKey
+		}
+		key.Method = jwt.SigningMethodHS256
+		key.signKey, key.verifyKey = secret, secret
+	case jwt.SigningMethodRS256.Alg():
+		if len(privatePEM) == 0 {
+			return nil, ErrorNoSigningKey
+		}
+		private, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка разбора RSA ключа: %w", err)
+		}
+		if private.N.BitLen() < 2048 {
+			return nil, ErrorWeakSigningKey
+		}
+		key.Method = jwt.SigningMethodRS256
+		key.signKey, key.verifyKey = private, &private.PublicKey
+	case jwt.SigningMethodES256.Alg():
+		if len(privatePEM) == 0 {
+			return nil, ErrorNoSigningKey
+		}
+		private, err := jwt.ParseECPrivateKeyFromPEM(privatePEM)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка разбора EC ключа: %w", err)
+		}
+		if private.Curve != elliptic.P256() {
+			return nil, ErrorKeyAlgorithmType
+		}
+		key.Method = jwt.SigningMethodES256
+		key.signKey, key.verifyKey = private, &private.PublicKey
+	case jwt.SigningMethodEdDSA.Alg():
+		if len(privatePEM) == 0 {
+			return nil, ErrorNoSigningKey
+		}
+		private, err := jwt.ParseEdPrivateKeyFromPEM(privatePEM)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка разбора Ed25519 ключа: %w", err)
+		}
+		signer, ok := private.(ed25519.PrivateKey)
+		if !ok {
+			return nil, ErrorKeyAlgorithmType
+		}
+		key.Method = jwt.SigningMethodEdDSA
+		key.signKey, key.verifyKey = signer, signer.Public()
+	default:
+		return nil, ErrorUnsupportedAlg
+	}
+
+	if key.ID == "" {
+		kid, err := thumbprint(key.verifyKey)
+		if err != nil {
+			return nil, err
+		}
+		key.ID = kid
+	}
+	return key, nil
+}
+
+// Symmetric сообщает, что ключ нельзя публиковать в JWKS.
+func (k *Key) Symmetric() bool {
+	_, ok := k.verifyKey.([]byte)
+	return ok
+}
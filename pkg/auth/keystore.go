@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var ErrorActiveKeyNotFound = errors.New("Активный ключ не найден в хранилище")
+
+type keystoreKey struct {
+	KID            string     `json:"kid"`
+	Alg            string     `json:"alg"`
+	Secret         string     `json:"secret,omitempty"`
+	PrivateKey     string     `json:"private_key,omitempty"`
+	PrivateKeyFile string     `json:"private_key_file,omitempty"`
+	RetireAt       *time.Time `json:"retire_at,omitempty"`
+}
+
+// keystore - файл с ключами подписи. ActiveKID указывает ключ, которым
+// подписываются новые токены; если он не задан, активным считается первый ключ.
+// Остальные ключи используются только для проверки до RetireAt.
+type keystore struct {
+	ActiveKID string        `json:"active_kid,omitempty"`
+	Keys      []keystoreKey `json:"keys"`
+}
+
+func readKeystore(path string) (keystore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return keystore{}, fmt.Errorf("ошибка чтения хранилища ключей: %w", err)
+	}
+
+	var ks keystore
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return keystore{}, fmt.Errorf("ошибка разбора хранилища ключей: %w", err)
+	}
+	return ks, nil
+}
+
+func writeKeystore(path string, ks keystore) error {
+	data, err := json.MarshalIndent(ks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации хранилища ключей: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".keystore-*")
+	if err != nil {
+		return fmt.Errorf("ошибка записи хранилища ключей: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка записи хранилища ключей: %w", err)
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка записи хранилища ключей: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ошибка записи хранилища ключей: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (ks keystore) activeIndex() int {
+	if ks.ActiveKID == "" {
+		if len(ks.Keys) == 0 {
+			return -1
+		}
+		return 0
+	}
+	for i, k := range ks.Keys {
+		if k.KID == ks.ActiveKID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (ks keystore) keyRing(defaultAlg string, now time.Time) (*KeyRing, error) {
+	idx := ks.activeIndex()
+	if idx < 0 {
+		if len(ks.Keys) == 0 {
+			return nil, ErrorNoSigningKey
+		}
+		return nil, ErrorActiveKeyNotFound
+	}
+
+	active, err := ks.Keys[idx].load(defaultAlg)
+	if err != nil {
+		return nil, err
+	}
+	ring := NewKeyRing(active)
+
+	for i, k := range ks.Keys {
+		if i == idx || (k.RetireAt != nil && !now.Before(*k.RetireAt)) {
+			continue
+		}
+		key, err := k.load(defaultAlg)
+		if err != nil {
+			return nil, err
+		}
+		var retireAt time.Time
+		if k.RetireAt != nil {
+			retireAt = *k.RetireAt
+		}
+		ring.AddRetired(key, retireAt)
+	}
+	return ring, nil
+}
+
+func (k keystoreKey) load(defaultAlg string) (*Key, error) {
+	alg := k.Alg
+	if alg == "" {
+		alg = defaultAlg
+	}
+
+	pemData := []byte(k.PrivateKey)
+	if k.PrivateKeyFile != "" {
+		data, err := os.ReadFile(k.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения файла закрытого ключа %s: %w", k.KID, err)
+		}
+		pemData = data
+	}
+
+	return newKey(k.KID, alg, []byte(k.Secret), pemData)
+}
+
+// RotateKeystore генерирует новый ключ алгоритма alg, делает его активным,
+// а прежнему активному ключу назначает вывод из оборота через retireAfter.
+// Ключи с наступившим сроком вывода удаляются из файла. Возвращает kid нового ключа.
+func RotateKeystore(path, alg string, retireAfter time.Duration) (string, error) {
+	ks, err := readKeystore(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	if idx := ks.activeIndex(); idx >= 0 {
+		if alg == "" {
+			alg = ks.Keys[idx].Alg
+		}
+		retireAt := now.Add(retireAfter)
+		ks.Keys[idx].RetireAt = &retireAt
+	}
+
+	next, err := generateKeystoreKey(alg)
+	if err != nil {
+		return "", err
+	}
+
+	keys := []keystoreKey{next}
+	for _, k := range ks.Keys {
+		if k.RetireAt == nil || now.Before(*k.RetireAt) {
+			keys = append(keys, k)
+		}
+	}
+	ks.ActiveKID, ks.Keys = next.KID, keys
+
+	if err := writeKeystore(path, ks); err != nil {
+		return "", err
+	}
+	return next.KID, nil
+}
+
+func generateKeystoreKey(alg string) (keystoreKey, error) {
+	if alg == "" {
+		alg = "HS256"
+	}
+
+	var private interface{}
+	var secret []byte
+	var err error
+	switch alg {
+	case "HS256":
+		secret = make([]byte, 48)
+		if _, err = rand.Read(secret); err == nil {
+			secret = []byte(encodeSegment(secret))
+		}
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return keystoreKey{}, ErrorUnsupportedAlg
+	}
+	if err != nil {
+		return keystoreKey{}, fmt.Errorf("ошибка генерации ключа: %w", err)
+	}
+
+	k := keystoreKey{Alg: alg, Secret: string(secret)}
+	if private != nil {
+		der, err := x509.MarshalPKCS8PrivateKey(private)
+		if err != nil {
+			return keystoreKey{}, fmt.Errorf("ошибка сериализации ключа: %w", err)
+		}
+		k.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	}
+
+	key, err := k.load(alg)
+	if err != nil {
+		return keystoreKey{}, err
+	}
+	k.KID = key.ID
+	return k, nil
+}
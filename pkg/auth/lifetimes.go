@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	DefaultAccessTTL     = 15 * time.Minute
+	DefaultRefreshTTL    = 7 * 24 * time.Hour
+	DefaultRememberMeTTL = 30 * 24 * time.Hour
+)
+
+// Lifetimes - сроки жизни токенов. Нулевое поле в переопределении
+// означает "взять значение уровнем выше".
+type Lifetimes struct {
+	Access     time.Duration
+	Refresh    time.Duration
+	RememberMe time.Duration
+}
+
+// LifetimeConfig - сроки жизни по умолчанию и их переопределения для ролей
+// и клиентов. Переопределение клиента важнее переопределения роли.
+type LifetimeConfig struct {
+	Default Lifetimes
+	Roles   map[string]Lifetimes
+	Clients map[string]Lifetimes
+}
+
+// Resolve возвращает сроки жизни для пользователя с ролью role,
+// входящего через клиента clientID.
+func (c LifetimeConfig) Resolve(role, clientID string) Lifetimes {
+	result := Lifetimes{
+		Access:     DefaultAccessTTL,
+		Refresh:    DefaultRefreshTTL,
+		RememberMe: DefaultRememberMeTTL,
+	}.merge(c.Default)
+	if override, ok := c.Roles[role]; ok {
+		result = result.merge(override)
+	}
+	if override, ok := c.Clients[clientID]; ok && clientID != "" {
+		result = result.merge(override)
+	}
+	return result
+}
+
+// RefreshTTL выбирает срок refresh токена с учетом флага "запомнить меня".
+func (l Lifetimes) RefreshTTL(rememberMe bool) time.Duration {
+	if rememberMe && l.RememberMe > 0 {
+		return l.RememberMe
+	}
+	return l.Refresh
+}
+
+// Longest возвращает наибольший срок жизни токенов с учетом всех
+// переопределений ролей и клиентов: столько нужно принимать выведенный
+// из оборота ключ подписи, чтобы не сломать уже выданные токены.
+func (c LifetimeConfig) Longest() time.Duration {
+	longest := c.Resolve("", "").Longest()
+	for _, override := range c.Roles {
+		longest = max(longest, override.Longest())
+	}
+	for _, override := range c.Clients {
+		longest = max(longest, override.Longest())
+	}
+	return longest
+}
+
+// Longest возвращает наибольший из сроков жизни.
+func (l Lifetimes) Longest() time.Duration {
+	return max(l.Access, l.Refresh, l.RememberMe)
+}
+
+func (l Lifetimes) merge(override Lifetimes) Lifetimes {
+	if override.Access > 0 {
+		l.Access = override.Access
+	}
+	if override.Refresh > 0 {
+		l.Refresh = override.Refresh
+	}
+	if override.RememberMe > 0 {
+		l.RememberMe = override.RememberMe
+	}
+	return l
+}
+
+type lifetimesFile struct {
+	Roles   map[string]lifetimesEntry `json:"roles"`
+	Clients map[string]lifetimesEntry `json:"clients"`
+}
+
+type lifetimesEntry struct {
+	Access     string `json:"access"`
+	Refresh    string `json:"refresh"`
+	RememberMe string `json:"remember_me"`
+}
+
+// LoadLifetimeOverrides читает переопределения сроков жизни из JSON файла вида
+// {"roles": {"admin": {"access": "5m", "refresh": "8h"}}, "clients": {...}}.
+func LoadLifetimeOverrides(path string, config *LifetimeConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения сроков жизни токенов: %w", err)
+	}
+
+	var file lifetimesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("ошибка разбора сроков жизни токенов: %w", err)
+	}
+
+	if config.Roles, err = parseLifetimes(file.Roles); err != nil {
+		return err
+	}
+	if config.Clients, err = parseLifetimes(file.Clients); err != nil {
+		return err
+	}
+	return nil
+}
+
+func parseLifetimes(entries map[string]lifetimesEntry) (map[string]Lifetimes, error) {
+	result := make(map[string]Lifetimes, len(entries))
+	for name, entry := range entries {
+		var lifetimes Lifetimes
+		for _, field := range []struct {
+			value  string
+			target *time.Duration
+		}{
+			{entry.Access, &lifetimes.Access},
+			{entry.Refresh, &lifetimes.Refresh},
+			{entry.RememberMe, &lifetimes.RememberMe},
+		} {
+			if field.value == "" {
+				continue
+			}
+			d, err := time.ParseDuration(field.value)
+			if err != nil {
+				return nil, fmt.Errorf("неверный срок жизни токенов для %s: %w", name, err)
+			}
+			*field.target = d
+		}
+		result[name] = lifetimes
+	}
+	return result, nil
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifetimeConfig_Resolve(t *testing.T) {
+	config := LifetimeConfig{
+		Default: Lifetimes{Access: 10 * time.Minute},
+		Roles: map[string]Lifetimes{
+			"admin": {Access: 5 * time.Minute, Refresh: 8 * time.Hour, RememberMe: 8 * time.Hour},
+		},
+		Clients: map[string]Lifetimes{
+			"console": {Access: 2 * time.Minute},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		role        string
+		clientID    string
+		rememberMe  bool
+		wantAccess  time.Duration
+		wantRefresh time.Duration
+	}{
+		{name: "Default", role: "user", wantAccess: 10 * time.Minute, wantRefresh: DefaultRefreshTTL},
+		{name: "Remember me", role: "user", rememberMe: true, wantAccess: 10 * time.Minute, wantRefresh: DefaultRememberMeTTL},
+		{name: "Admin", role: "admin", rememberMe: true, wantAccess: 5 * time.Minute, wantRefresh: 8 * time.Hour},
+		{name: "Admin via client", role: "admin", clientID: "console", wantAccess: 2 * time.Minute, wantRefresh: 8 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := config.Resolve(tt.role, tt.clientID)
+			assert.Equal(t, tt.wantAccess, got.Access)
+			assert.Equal(t, tt.wantRefresh, got.RefreshTTL(tt.rememberMe))
+		})
+	}
+}
+
+func TestLoadLifetimeOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lifetimes.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"roles": {"admin": {"access": "5m", "refresh": "8h"}},
+		"clients": {"mobile": {"remember_me": "2160h"}}
+	}`), 0o600))
+
+	var config LifetimeConfig
+	require.NoError(t, LoadLifetimeOverrides(path, &config))
+	assert.Equal(t, Lifetimes{Access: 5 * time.Minute, Refresh: 8 * time.Hour}, config.Roles["admin"])
+	assert.Equal(t, Lifetimes{RememberMe: 2160 * time.Hour}, config.Clients["mobile"])
+}
+
+func TestLifetimeConfig_Longest(t *testing.T) {
+	assert.Equal(t, DefaultRememberMeTTL, LifetimeConfig{}.Longest())
+
+	config := LifetimeConfig{
+		Roles:   map[string]Lifetimes{"admin": {Refresh: 8 * time.Hour}},
+		Clients: map[string]Lifetimes{"mobile": {RememberMe: 2160 * time.Hour}},
+	}
+	assert.Equal(t, 2160*time.Hour, config.Longest())
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryTokenStore - TokenStore в памяти для тестов.
+type memoryTokenStore map[string]Claims
+
+func (m memoryTokenStore) StoreToken(claims *Claims) (string, error) {
+	token, err := NewOpaqueToken(claims.TokenType)
+	if err != nil {
+		return "", err
+	}
+	m[token] = *claims
+	return token, nil
+}
+
+func (m memoryTokenStore) ResolveToken(token string) (*Claims, error) {
+	claims, ok := m[token]
+	if !ok {
+		return nil, ErrorInvalidToken
+	}
+	return &claims, nil
+}
+
+func newOpaqueTestPair(t *testing.T, store memoryTokenStore) (*Signer, *Verifier) {
+	ring := newTestKeyRing(t)
+	signer := NewSigner(ring, testTokenConfig)
+	verifier := NewVerifier(ring, testTokenConfig)
+	signer.UseTokenStore(store)
+	verifier.UseTokenStore(store)
+	return signer, verifier
+}
+
+func TestOpaqueTokens(t *testing.T) {
+	store := memoryTokenStore{}
+	signer, verifier := newOpaqueTestPair(t, store)
+
+	access, _, err := signer.GenerateAccessToken(TokenParams{UserID: 7, SessionID: "family", Scopes: []string{"openid"}})
+	require.NoError(t, err)
+	refresh, _, err := signer.GenerateRefreshToken(TokenParams{UserID: 7, SessionID: "family"})
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(access, "at_"))
+	assert.True(t, strings.HasPrefix(refresh, "rt_"))
+	assert.NotContains(t, access, ".")
+	assert.Len(t, store, 2)
+
+	claims, err := verifier.ValidateAccessToken(access)
+	require.NoError(t, err)
+	assert.Equal(t, 7, claims.UserID)
+	assert.Equal(t, "family", claims.SessionID)
+	assert.True(t, claims.HasScope("openid"))
+
+	// Как и у JWT, refresh токен адресован самому сервису и отклоняется
+	// еще по aud, а при общей аудитории - по типу.
+	_, err = verifier.ValidateAccessToken(refresh)
+	assert.Error(t, err)
+	_, err = verifier.ValidateRefreshToken(access)
+	assert.Error(t, err)
+	_, err = verifier.Inspect(refresh)
+	assert.NoError(t, err)
+	_, err = verifier.ValidateAccessTokenFor(access, "billing")
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidAudience)
+
+	_, err = verifier.ValidateRefreshToken(refresh)
+	assert.NoError(t, err)
+
+	t.Run("Deleted from store", func(t *testing.T) {
+		delete(store, access)
+		_, err := verifier.ValidateAccessToken(access)
+		assert.ErrorIs(t, err, ErrorInvalidToken)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		token, _, err := signer.GenerateAccessToken(TokenParams{UserID: 7})
+		require.NoError(t, err)
+		claims := store[token]
+		claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		store[token] = claims
+
+		_, err = verifier.ValidateAccessToken(token)
+		assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+	})
+
+	t.Run("Prefix of another type", func(t *testing.T) {
+		token, _, err := signer.GenerateAccessToken(TokenParams{UserID: 7})
+		require.NoError(t, err)
+		forged := "rt_" + strings.TrimPrefix(token, "at_")
+		store[forged] = store[token]
+
+		_, err = verifier.ValidateAccessToken(forged)
+		assert.ErrorIs(t, err, ErrorInvalidToken)
+	})
+
+	t.Run("JWT still accepted", func(t *testing.T) {
+		token, _, err := NewSigner(newTestKeyRing(t), testTokenConfig).GenerateAccessToken(TokenParams{UserID: 7})
+		require.NoError(t, err)
+		_, err = verifier.ValidateAccessToken(token)
+		assert.NoError(t, err)
+	})
+
+	t.Run("ID token stays JWT", func(t *testing.T) {
+		signer := NewSigner(newTestECKeyRing(t), testTokenConfig)
+		signer.UseTokenStore(store)
+		token, err := signer.GenerateIDToken(IDTokenParams{UserID: 7, Audience: "spa"})
+		require.NoError(t, err)
+		assert.False(t, IsOpaqueToken(token))
+		assert.Equal(t, 2, strings.Count(token, "."))
+	})
+}
+
+func TestOpaqueTokens_WithoutStore(t *testing.T) {
+	_, verifier := newOpaqueTestPair(t, memoryTokenStore{})
+	signer, _ := newOpaqueTestPair(t, memoryTokenStore{})
+
+	token, _, err := signer.GenerateAccessToken(TokenParams{UserID: 7})
+	require.NoError(t, err)
+	_, err = verifier.ValidateAccessToken(token)
+	assert.ErrorIs(t, err, ErrorInvalidToken)
+
+	_, err = NewVerifier(newTestKeyRing(t), testTokenConfig).ValidateAccessToken(token)
+	assert.Error(t, err)
+}
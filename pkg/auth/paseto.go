@@ -0,0 +1,341 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20"
+	"strings"
+	"time"
+)
+
+const (
+	TokenFormatPasetoPublic = "v4.public"
+	TokenFormatPasetoLocal  = "v4.local"
+)
+
+var ErrorPasetoFormat = errors.New("Неверный формат PASETO токена")
+
+// pasetoTimeClaims - claims, которые PASETO хранит как дату RFC 3339, а не как число.
+var pasetoTimeClaims = []string{"exp", "nbf", "iat"}
+
+// pasetoFooter - открытый футер токена. В нем только kid: по нему выбирается
+// ключ, а версия и назначение ключа заданы заголовком и не обсуждаются.
+type pasetoFooter struct {
+	KID string `json:"kid"`
+}
+
+// pasetoCodec - формат PASETO v4. v4.public подписывает токены Ed25519,
+// v4.local шифрует их XChaCha20 с аутентификацией BLAKE2b. В отличие от JWT,
+// алгоритм определяется версией в заголовке токена и ключом, а не полем alg.
+type pasetoCodec struct {
+	header string
+	keys   *KeyRing
+}
+
+// NewPasetoCodec создает формат PASETO v4.public или v4.local над связкой
+// ключей. Для v4.public нужны ключи EdDSA, для v4.local - секреты HS256,
+// из которых выводятся ключи шифрования.
+func NewPasetoCodec(format string, keys *KeyRing) (TokenCodec, error) {
+	codec := &pasetoCodec{header: format + ".", keys: keys}
+	switch format {
+	case TokenFormatPasetoPublic, TokenFormatPasetoLocal:
+	default:
+		return nil, ErrorUnsupportedAlg
+	}
+	if _, err := codec.keyMaterial(keys.Active(), true); err != nil {
+		return nil, err
+	}
+	return codec, nil
+}
+
+// PasetoAlgorithm - алгоритм связки ключей, которого требует формат PASETO.
+func PasetoAlgorithm(format string) string {
+	if format == TokenFormatPasetoPublic {
+		return "EdDSA"
+	}
+	return "HS256"
+}
+
+func (c *pasetoCodec) Encode(claims *Claims) (string, error) {
+	payload, err := pasetoPayload(claims)
+	if err != nil {
+		return "", err
+	}
+
+	key := c.keys.Active()
+	footer, err := json.Marshal(pasetoFooter{KID: key.ID})
+	if err != nil {
+		return "", fmt.Errorf("ошибка сериализации футера: %w", err)
+	}
+	material, err := c.keyMaterial(key, true)
+	if err != nil {
+		return "", err
+	}
+
+	var body []byte
+	if c.header == TokenFormatPasetoPublic+"." {
+		body = pasetoSign(material.(ed25519.PrivateKey), payload, footer)
+	} else {
+		body, err = pasetoEncrypt(material.([]byte), payload, footer)
+		if err != nil {
+			return "", err
+		}
+	}
+	return c.header + b64(body) + "." + b64(footer), nil
+}
+
+func (c *pasetoCodec) Decode(token string) (*Claims, error) {
+	if !strings.HasPrefix(token, c.header) {
+		return nil, ErrorPasetoFormat
+	}
+	parts := strings.Split(strings.TrimPrefix(token, c.header), ".")
+	if len(parts) > 2 {
+		return nil, ErrorPasetoFormat
+	}
+	body, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrorPasetoFormat
+	}
+
+	var footer []byte
+	key := c.keys.Active()
+	if len(parts) == 2 {
+		if footer, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+			return nil, ErrorPasetoFormat
+		}
+		var f pasetoFooter
+		if err := json.Unmarshal(footer, &f); err != nil {
+			return nil, ErrorPasetoFormat
+		}
+		var ok bool
+		if key, ok = c.keys.Lookup(f.KID); !ok {
+			return nil, ErrorUnknownKey
+		}
+	}
+	material, err := c.keyMaterial(key, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload []byte
+	if c.header == TokenFormatPasetoPublic+"." {
+		payload, err = pasetoVerify(material.(ed25519.PublicKey), body, footer)
+	} else {
+		payload, err = pasetoDecrypt(material.([]byte), body, footer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pasetoClaims(payload)
+}
+
+// keyMaterial проверяет, что ключ подходит формату, и возвращает ключ
+// подписи (sign) или проверки. Ключ v4.local выводится из секрета через
+// HKDF, чтобы один и тот же секрет не использовался как ключ HMAC и PASETO.
+func (c *pasetoCodec) keyMaterial(key *Key, sign bool) (any, error) {
+	if c.header == TokenFormatPasetoPublic+"." {
+		if sign {
+			private, ok := key.signKey.(ed25519.PrivateKey)
+			if !ok {
+				return nil, ErrorKeyAlgorithmType
+			}
+			return private, nil
+		}
+		public, ok := key.verifyKey.(ed25519.PublicKey)
+		if !ok {
+			return nil, ErrorKeyAlgorithmType
+		}
+		return public, nil
+	}
+
+	secret, ok := key.signKey.([]byte)
+	if !ok {
+		return nil, ErrorKeyAlgorithmType
+	}
+	return hkdf.Key(sha256.New, secret, nil, "paseto v4.local", 32)
+}
+
+// pae - Pre-Authentication Encoding из спецификации PASETO: однозначная
+// склейка частей, которые покрываются подписью или MAC.
+func pae(pieces ...[]byte) []byte {
+	out := binary.LittleEndian.AppendUint64(nil, uint64(len(pieces))&^(1<<63))
+	for _, piece := range pieces {
+		out = binary.LittleEndian.AppendUint64(out, uint64(len(piece))&^(1<<63))
+		out = append(out, piece...)
+	}
+	return out
+}
+
+func pasetoSign(key ed25519.PrivateKey, payload, footer []byte) []byte {
+	signature := ed25519.Sign(key, pae([]byte(TokenFormatPasetoPublic+"."), payload, footer, nil))
+	return append(payload, signature...)
+}
+
+func pasetoVerify(key ed25519.PublicKey, body, footer []byte) ([]byte, error) {
+	if len(body) < ed25519.SignatureSize {
+		return nil, ErrorPasetoFormat
+	}
+	payload, signature := body[:len(body)-ed25519.SignatureSize], body[len(body)-ed25519.SignatureSize:]
+	if !ed25519.Verify(key, pae([]byte(TokenFormatPasetoPublic+"."), payload, footer, nil), signature) {
+		return nil, ErrorInvalidToken
+	}
+	return payload, nil
+}
+
+func pasetoEncrypt(key, payload, footer []byte) ([]byte, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("ошибка генерации nonce: %w", err)
+	}
+	return pasetoSeal(key, nonce, payload, footer)
+}
+
+// pasetoSeal шифрует payload с заданным nonce. Вынесена отдельно от
+// pasetoEncrypt для проверки на тестовых векторах спецификации.
+func pasetoSeal(key, nonce, payload, footer []byte) ([]byte, error) {
+	encKey, counterNonce, authKey, err := pasetoLocalKeys(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := chacha20.NewUnauthenticatedCipher(encKey, counterNonce)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка шифрования токена: %w", err)
+	}
+	ciphertext := make([]byte, len(payload))
+	stream.XORKeyStream(ciphertext, payload)
+
+	tag, err := pasetoTag(authKey, nonce, ciphertext, footer)
+	if err != nil {
+		return nil, err
+	}
+	body := append(append(append([]byte{}, nonce...), ciphertext...), tag...)
+	return body, nil
+}
+
+func pasetoDecrypt(key, body, footer []byte) ([]byte, error) {
+	if len(body) < 64 {
+		return nil, ErrorPasetoFormat
+	}
+	nonce, ciphertext, tag := body[:32], body[32:len(body)-32], body[len(body)-32:]
+
+	encKey, counterNonce, authKey, err := pasetoLocalKeys(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	expected, err := pasetoTag(authKey, nonce, ciphertext, footer)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(tag, expected) != 1 {
+		return nil, ErrorInvalidToken
+	}
+
+	stream, err := chacha20.NewUnauthenticatedCipher(encKey, counterNonce)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка расшифровки токена: %w", err)
+	}
+	payload := make([]byte, len(ciphertext))
+	stream.XORKeyStream(payload, ciphertext)
+	return payload, nil
+}
+
+// pasetoLocalKeys выводит из ключа и nonce ключ шифрования, nonce XChaCha20
+// и ключ аутентификации (PASETO v4.local, шаги 3-4).
+func pasetoLocalKeys(key, nonce []byte) (encKey, counterNonce, authKey []byte, err error) {
+	tmp, err := blake2bKeyed(56, key, []byte("paseto-encryption-key"), nonce)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	authKey, err = blake2bKeyed(32, key, []byte("paseto-auth-key-for-aead"), nonce)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return tmp[:32], tmp[32:], authKey, nil
+}
+
+func pasetoTag(authKey, nonce, ciphertext, footer []byte) ([]byte, error) {
+	return blake2bKeyed(32, authKey, pae([]byte(TokenFormatPasetoLocal+"."), nonce, ciphertext, footer, nil))
+}
+
+func blake2bKeyed(size int, key []byte, data ...[]byte) ([]byte, error) {
+	h, err := blake2b.New(size, key)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка BLAKE2b: %w", err)
+	}
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil), nil
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// pasetoPayload сериализует claims, заменяя числовые exp, nbf и iat
+// на даты RFC 3339, как требует спецификация PASETO.
+func pasetoPayload(claims *Claims) ([]byte, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сериализации claims: %w", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("ошибка сериализации claims: %w", err)
+	}
+
+	for _, name := range pasetoTimeClaims {
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		var seconds int64
+		if err := json.Unmarshal(raw, &seconds); err != nil {
+			return nil, fmt.Errorf("ошибка сериализации claim %s: %w", name, err)
+		}
+		fields[name], _ = json.Marshal(time.Unix(seconds, 0).UTC().Format(time.RFC3339))
+	}
+	return json.Marshal(fields)
+}
+
+func pasetoClaims(payload []byte) (*Claims, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, ErrorPasetoFormat
+	}
+
+	for _, name := range pasetoTimeClaims {
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, ErrorPasetoFormat
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, ErrorPasetoFormat
+		}
+		fields[name], _ = json.Marshal(parsed.Unix())
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, ErrorPasetoFormat
+	}
+	claims := &Claims{}
+	if err := json.Unmarshal(data, claims); err != nil {
+		return nil, ErrorPasetoFormat
+	}
+	return claims, nil
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPasetoTestRing(t *testing.T, format string) *KeyRing {
+	if format == TokenFormatPasetoLocal {
+		secret := make([]byte, 32)
+		_, err := rand.Read(secret)
+		require.NoError(t, err)
+		key, err := newKey("", "HS256", secret, nil)
+		require.NoError(t, err)
+		return NewKeyRing(key)
+	}
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, err := newKey("", "EdDSA", nil, encodePrivateKey(t, private))
+	require.NoError(t, err)
+	return NewKeyRing(key)
+}
+
+func newPasetoTestPair(t *testing.T, format string, ring *KeyRing) (*Signer, *Verifier) {
+	codec, err := NewPasetoCodec(format, ring)
+	require.NoError(t, err)
+	signer, verifier := NewSigner(ring, testTokenConfig), NewVerifier(ring, testTokenConfig)
+	signer.UseCodec(codec)
+	verifier.UseCodec(codec)
+	return signer, verifier
+}
+
+// Тестовые векторы 4-E-1 и 4-S-1 из спецификации PASETO.
+func TestPaseto_Vectors(t *testing.T) {
+	key, _ := hex.DecodeString("707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f")
+	body, err := pasetoSeal(key, make([]byte, 32), []byte(`{"data":"this is a secret message","exp":"2022-01-01T00:00:00+00:00"}`), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAQAr68PS4AXe7If_ZgesdkUMvSwscFlAl1pk5HC0e8kApeaqMfGo_7OpBnwJOAbY9V7WU6abu74MmcUE8YWAiaArVI8XJ5hOb_4v9RmDkneN0S92dx0OW4pgy7omxgf3S8c3LlQg", b64(body))
+
+	seed, _ := hex.DecodeString("b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a3774")
+	private := ed25519.NewKeyFromSeed(seed)
+	signed := pasetoSign(private, []byte(`{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`), nil)
+	assert.Equal(t, "eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA", b64(signed))
+
+	payload, err := pasetoVerify(private.Public().(ed25519.PublicKey), signed, nil)
+	require.NoError(t, err)
+	assert.Contains(t, string(payload), "this is a signed message")
+}
+
+func TestPaseto_Tokens(t *testing.T) {
+	for _, format := range []string{TokenFormatPasetoPublic, TokenFormatPasetoLocal} {
+		t.Run(format, func(t *testing.T) {
+			ring := newPasetoTestRing(t, format)
+			signer, verifier := newPasetoTestPair(t, format, ring)
+
+			access, _, err := signer.GenerateAccessToken(TokenParams{UserID: 7, SessionID: "family", Scopes: []string{"openid"}})
+			require.NoError(t, err)
+			refresh, _, err := signer.GenerateRefreshToken(TokenParams{UserID: 7, SessionID: "family"})
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(access, format+"."))
+			if format == TokenFormatPasetoLocal {
+				assert.NotContains(t, access, "family", "v4.local шифрует claims")
+			}
+
+			claims, err := verifier.ValidateAccessToken(access)
+			require.NoError(t, err)
+			assert.Equal(t, 7, claims.UserID)
+			assert.Equal(t, "family", claims.SessionID)
+			assert.True(t, claims.HasScope("openid"))
+			assert.Equal(t, "7", claims.Subject)
+
+			// Проверки claims те же, что и для JWT.
+			_, err = verifier.ValidateAccessToken(refresh)
+			assert.Error(t, err)
+			_, err = verifier.ValidateRefreshToken(access)
+			assert.Error(t, err)
+			_, err = verifier.ValidateAccessTokenFor(access, "billing")
+			assert.ErrorIs(t, err, jwt.ErrTokenInvalidAudience)
+			_, err = verifier.ValidateRefreshToken(refresh)
+			assert.NoError(t, err)
+
+			t.Run("Expired", func(t *testing.T) {
+				expired := *claims
+				expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+				codec, err := NewPasetoCodec(format, ring)
+				require.NoError(t, err)
+				token, err := codec.Encode(&expired)
+				require.NoError(t, err)
+				_, err = verifier.ValidateAccessToken(token)
+				assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+			})
+
+			t.Run("Tampered", func(t *testing.T) {
+				parts := strings.Split(access, ".")
+				body := []byte(parts[2])
+				body[10] ^= 1
+				parts[2] = string(body)
+				_, err := verifier.ValidateAccessToken(strings.Join(parts, "."))
+				assert.Error(t, err)
+			})
+
+			t.Run("Foreign key", func(t *testing.T) {
+				_, foreign := newPasetoTestPair(t, format, newPasetoTestRing(t, format))
+				_, err := foreign.ValidateAccessToken(access)
+				assert.ErrorIs(t, err, ErrorUnknownKey)
+			})
+
+			t.Run("JWT not accepted", func(t *testing.T) {
+				token, _, err := NewSigner(ring, testTokenConfig).GenerateAccessToken(TokenParams{UserID: 7})
+				require.NoError(t, err)
+				_, err = verifier.ValidateAccessToken(token)
+				assert.ErrorIs(t, err, ErrorPasetoFormat)
+			})
+
+			t.Run("ID token stays JWT", func(t *testing.T) {
+				token, err := signer.GenerateIDToken(IDTokenParams{UserID: 7, Audience: "spa"})
+				if format == TokenFormatPasetoLocal {
+					assert.ErrorIs(t, err, ErrorSymmetricIDToken, "ключ v4.local симметричный")
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, 2, strings.Count(token, "."))
+				assert.False(t, strings.HasPrefix(token, "v4."))
+			})
+		})
+	}
+}
+
+func TestPaseto_OtherVersion(t *testing.T) {
+	ring := newPasetoTestRing(t, TokenFormatPasetoLocal)
+	_, err := NewPasetoCodec(TokenFormatPasetoPublic, ring)
+	assert.ErrorIs(t, err, ErrorKeyAlgorithmType)
+
+	_, err = NewPasetoCodec("v3.local", ring)
+	assert.ErrorIs(t, err, ErrorUnsupportedAlg)
+
+	local, _ := newPasetoTestPair(t, TokenFormatPasetoLocal, ring)
+	token, _, err := local.GenerateAccessToken(TokenParams{UserID: 7})
+	require.NoError(t, err)
+
+	_, public := newPasetoTestPair(t, TokenFormatPasetoPublic, newPasetoTestRing(t, TokenFormatPasetoPublic))
+	_, err = public.ValidateAccessToken(token)
+	assert.ErrorIs(t, err, ErrorPasetoFormat)
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+const PKCEMethodS256 = "S256"
+
+// ValidPKCEValue проверяет формат code_verifier и code_challenge (RFC 7636, 4.1):
+// от 43 до 128 символов из набора [A-Za-z0-9-._~].
+func ValidPKCEValue(value string) bool {
+	if len(value) < 43 || len(value) > 128 {
+		return false
+	}
+	for _, r := range value {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case r == '-', r == '.', r == '_', r == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// PKCEChallenge вычисляет code_challenge метода S256.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// VerifyPKCE сравнивает code_verifier с сохраненным code_challenge за постоянное время.
+func VerifyPKCE(challenge, verifier string) bool {
+	if !ValidPKCEValue(verifier) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(PKCEChallenge(verifier)), []byte(challenge)) == 1
+}
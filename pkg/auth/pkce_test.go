@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// Пример из RFC 7636, приложение B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	assert.Equal(t, challenge, PKCEChallenge(verifier))
+	assert.True(t, VerifyPKCE(challenge, verifier))
+	assert.False(t, VerifyPKCE(challenge, strings.Replace(verifier, "d", "e", 1)))
+	assert.False(t, VerifyPKCE(challenge, "short"))
+	assert.False(t, VerifyPKCE(challenge, verifier+"!"))
+}
@@ -2,10 +2,13 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	auth "github.com/fire9900/auth/pkg/api/g_rpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"slices"
+	"time"
 )
 
 type AuthClient struct {
@@ -34,9 +37,33 @@ func (c *AuthClient) Close() {
 }
 
 func (c *AuthClient) ValidateToken(token string) (bool, error) {
-	fmt.Println(token)
+	return c.ValidateTokenFor(token, "")
+}
+
+// ValidateTokenFor проверяет, что токен выпущен для аудитории audience.
+func (c *AuthClient) ValidateTokenFor(token, audience string) (bool, error) {
+	resp, err := c.service.ValidateToken(context.Background(), &auth.TokenRequest{
+		Token:    token,
+		Audience: audience,
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return resp.Valid, nil
+}
+
+// ValidateDPoPToken проверяет токен, предъявленный по схеме DPoP: proof -
+// заголовок DPoP запроса, method и url - метод и URL запроса к сервису.
+// Токен, привязанный к ключу клиента, без доказательства недействителен.
+func (c *AuthClient) ValidateDPoPToken(token, proof, method, url, audience string) (bool, error) {
 	resp, err := c.service.ValidateToken(context.Background(), &auth.TokenRequest{
-		Token: token,
+		Token:      token,
+		Audience:   audience,
+		DpopProof:  proof,
+		HttpMethod: method,
+		HttpUrl:    url,
 	})
 
 	if err != nil {
@@ -47,13 +74,91 @@ func (c *AuthClient) ValidateToken(token string) (bool, error) {
 }
 
 func (c *AuthClient) GetUserID(token string) (int32, error) {
+	return c.GetUserIDFor(token, "")
+}
+
+func (c *AuthClient) GetUserIDFor(token, audience string) (int32, error) {
 	resp, err := c.service.GetUserID(context.Background(), &auth.TokenRequest{
-		Token: token,
+		Token:    token,
+		Audience: audience,
 	})
 
 	if err != nil {
 		return 0, err
 	}
+	if resp.Error != "" {
+		return 0, errors.New(resp.Error)
+	}
 
 	return resp.UserId, nil
 }
+
+// TokenClaims - сведения из access токена, достаточные для авторизации
+// запроса без отдельного обращения за данными пользователя.
+// Для токена клиента UserID равен нулю, а Subject совпадает с ClientID.
+type TokenClaims struct {
+	UserID    int32
+	Subject   string
+	ClientID  string
+	Roles     []string
+	Scopes    []string
+	ExpiresAt time.Time
+	TokenID   string
+}
+
+// IsClient сообщает, что токен представляет сервис, а не пользователя.
+func (c TokenClaims) IsClient() bool {
+	return c.UserID == 0
+}
+
+func (c TokenClaims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+func (c TokenClaims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+func (c *AuthClient) GetClaims(token string) (TokenClaims, error) {
+	return c.GetClaimsFor(token, "")
+}
+
+func (c *AuthClient) GetClaimsFor(token, audience string) (TokenClaims, error) {
+	resp, err := c.service.GetClaims(context.Background(), &auth.TokenRequest{
+		Token:    token,
+		Audience: audience,
+	})
+
+	if err != nil {
+		return TokenClaims{}, err
+	}
+	if resp.Error != "" {
+		return TokenClaims{}, errors.New(resp.Error)
+	}
+
+	return TokenClaims{
+		UserID:    resp.UserId,
+		Subject:   resp.Subject,
+		ClientID:  resp.ClientId,
+		Roles:     resp.Roles,
+		Scopes:    resp.Scopes,
+		ExpiresAt: time.Unix(resp.ExpiresAt, 0),
+		TokenID:   resp.TokenId,
+	}, nil
+}
+
+func (c *AuthClient) HasRole(token, role string) (bool, error) {
+	claims, err := c.GetClaims(token)
+	if err != nil {
+		return false, err
+	}
+	return claims.HasRole(role), nil
+}
+
+func (c *AuthClient) HasScope(token, scope string) (bool, error) {
+	claims, err := c.GetClaims(token)
+	if err != nil {
+		return false, err
+	}
+	return claims.HasScope(scope), nil
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		email TEXT NOT NULL UNIQUE,
+		password TEXT NOT NULL,
+		role TEXT NOT NULL DEFAULT 'user'
+	)`,
+	`CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		family_id TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		user_id INTEGER NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		revoked_at DATETIME,
+		created_at DATETIME NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens (family_id)`,
+	`CREATE TABLE IF NOT EXISTS revoked_tokens (
+		jti TEXT PRIMARY KEY,
+		expires_at DATETIME NOT NULL,
+		revoked_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS authorization_codes (
+		code_hash TEXT PRIMARY KEY,
+		client_id TEXT NOT NULL,
+		user_id INTEGER NOT NULL,
+		redirect_uri TEXT NOT NULL,
+		scope TEXT NOT NULL DEFAULT '',
+		code_challenge TEXT NOT NULL,
+		nonce TEXT NOT NULL DEFAULT '',
+		family_id TEXT NOT NULL,
+		auth_time DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		created_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS clients (
+		id TEXT PRIMARY KEY,
+		secret_hash TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL DEFAULT '',
+		public BOOLEAN NOT NULL DEFAULT 0,
+		first_party BOOLEAN NOT NULL DEFAULT 0,
+		grant_types TEXT NOT NULL DEFAULT '',
+		redirect_uris TEXT NOT NULL DEFAULT '',
+		scopes TEXT NOT NULL DEFAULT '',
+		exchange_audiences TEXT NOT NULL DEFAULT '',
+		exchange_subject_audiences TEXT NOT NULL DEFAULT '',
+		access_token_ttl INTEGER NOT NULL DEFAULT 0,
+		refresh_token_ttl INTEGER NOT NULL DEFAULT 0,
+		remember_me_ttl INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS device_codes (
+		device_code_hash TEXT PRIMARY KEY,
+		user_code TEXT NOT NULL UNIQUE,
+		client_id TEXT NOT NULL,
+		scope TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'pending',
+		user_id INTEGER NOT NULL DEFAULT 0,
+		interval INTEGER NOT NULL,
+		last_polled_at DATETIME,
+		expires_at DATETIME NOT NULL,
+		created_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS opaque_tokens (
+		token_hash TEXT PRIMARY KEY,
+		jti TEXT NOT NULL,
+		token_type TEXT NOT NULL,
+		user_id INTEGER NOT NULL DEFAULT 0,
+		client_id TEXT NOT NULL DEFAULT '',
+		session_id TEXT NOT NULL DEFAULT '',
+		claims TEXT NOT NULL,
+		expires_at DATETIME NOT NULL,
+		created_at DATETIME NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_opaque_tokens_session ON opaque_tokens (session_id)`,
+	`CREATE TABLE IF NOT EXISTS sessions (
+		id TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		client_id TEXT NOT NULL DEFAULT '',
+		device_name TEXT NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		ip TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		last_seen_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		revoked_at DATETIME
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions (user_id)`,
+}
+
+// columns - колонки, добавленные в уже существующие таблицы. SQLite не умеет
+// ADD COLUMN IF NOT EXISTS, поэтому наличие колонки проверяется отдельно.
+var columns = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"refresh_tokens", "client_id", "TEXT NOT NULL DEFAULT ''"},
+	{"refresh_tokens", "remember_me", "BOOLEAN NOT NULL DEFAULT 0"},
+	{"refresh_tokens", "scope", "TEXT NOT NULL DEFAULT ''"},
+	{"clients", "exchange_audiences", "TEXT NOT NULL DEFAULT ''"},
+	{"clients", "first_party", "BOOLEAN NOT NULL DEFAULT 0"},
+	{"clients", "exchange_subject_audiences", "TEXT NOT NULL DEFAULT ''"},
+	{"users", "token_version", "INTEGER NOT NULL DEFAULT 0"},
+	{"users", "suspended", "BOOLEAN NOT NULL DEFAULT 0"},
+}
+
+func Migrate(db *sql.DB) error {
+	for _, migration := range migrations {
+		if _, err := db.Exec(migration); err != nil {
+			return fmt.Errorf("ошибка применения миграции: %w", err)
+		}
+	}
+
+	for _, c := range columns {
+		var exists int
+		err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info($1) WHERE name = $2`, c.table, c.column).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("ошибка проверки колонки %s.%s: %w", c.table, c.column, err)
+		}
+		if exists > 0 {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)); err != nil {
+			return fmt.Errorf("ошибка добавления колонки %s.%s: %w", c.table, c.column, err)
+		}
+	}
+	return nil
+}
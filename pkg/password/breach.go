@@ -0,0 +1,390 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var ErrorInvalidDataset = errors.New("Поврежденная база утекших паролей")
+
+// BreachChecker сообщает, сколько раз пароль встречался в известных утечках.
+// Пароль не покидает сервис: проверяется только его SHA-1 по локальной копии
+// базы Pwned Passwords. Close освобождает открытый файл базы.
+type BreachChecker interface {
+	io.Closer
+	Occurrences(password string) (int, error)
+}
+
+// OpenBreachDataset открывает локальную базу утекших паролей в одном из форматов:
+//   - каталог с файлами диапазонов <первые 5 символов SHA-1>.txt, строки
+//     которых имеют вид SUFFIX:COUNT (как ответы range API);
+//   - файл строк HASH:COUNT, отсортированный по хешу;
+//   - bloom-фильтр, построенный командой pwned import.
+//
+// Вызывающий закрывает базу через Close, когда она больше не нужна.
+func OpenBreachDataset(path string) (BreachChecker, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия базы утекших паролей: %w", err)
+	}
+	if info.IsDir() {
+		return &RangeDirectory{dir: path}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия базы утекших паролей: %w", err)
+	}
+	magic := make([]byte, len(bloomMagic))
+	if _, err := io.ReadFull(file, magic); err == nil && string(magic) == bloomMagic {
+		defer file.Close()
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return ReadBloomFilter(bufio.NewReader(file))
+	}
+	return &SortedFile{file: file, size: info.Size()}, nil
+}
+
+func passwordHash(password string) [sha1.Size]byte {
+	return sha1.Sum([]byte(password))
+}
+
+// parseRecord разбирает строку HASH:COUNT или SUFFIX:COUNT; prefix
+// дополняет суффикс до полного хеша.
+func parseRecord(prefix, line string) ([sha1.Size]byte, int, error) {
+	var sum [sha1.Size]byte
+	hash, count, ok := strings.Cut(strings.TrimSpace(line), ":")
+	if !ok {
+		return sum, 0, ErrorInvalidDataset
+	}
+	if _, err := hex.Decode(sum[:], []byte(prefix+hash)); err != nil || len(prefix)+len(hash) != 2*sha1.Size {
+		return sum, 0, ErrorInvalidDataset
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return sum, 0, ErrorInvalidDataset
+	}
+	return sum, n, nil
+}
+
+// SortedFile ищет хеш в отсортированном файле HASH:COUNT двоичным поиском
+// по смещениям, не загружая файл в память: полный файл занимает десятки ГБ.
+type SortedFile struct {
+	file *os.File
+	size int64
+}
+
+func (f *SortedFile) Occurrences(password string) (int, error) {
+	sum := passwordHash(password)
+	target := []byte(strings.ToUpper(hex.EncodeToString(sum[:])))
+
+	lo, hi := int64(0), f.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, line, err := f.lineAt(mid)
+		if err != nil {
+			return 0, err
+		}
+		if start >= hi || len(line) == 0 {
+			hi = mid
+			continue
+		}
+		if len(line) < len(target) {
+			return 0, ErrorInvalidDataset
+		}
+
+		switch bytes.Compare(bytes.ToUpper(line[:len(target)]), target) {
+		case 0:
+			_, count, err := parseRecord("", string(line))
+			return count, err
+		case -1:
+			lo = start + int64(len(line)) + 1
+		default:
+			hi = mid
+		}
+	}
+	return 0, nil
+}
+
+// lineAt возвращает первую строку, начинающуюся не раньше pos, и ее смещение.
+func (f *SortedFile) lineAt(pos int64) (int64, []byte, error) {
+	start := pos
+	if pos > 0 {
+		start = pos - 1
+	}
+	buf := make([]byte, 256)
+	n, err := f.file.ReadAt(buf, start)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, nil, fmt.Errorf("ошибка чтения базы утекших паролей: %w", err)
+	}
+	buf = buf[:n]
+
+	if pos > 0 {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			return f.size, nil, nil
+		}
+		start += int64(i) + 1
+		buf = buf[i+1:]
+	}
+	if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+		buf = buf[:i]
+	}
+	return start, bytes.TrimRight(buf, "\r"), nil
+}
+
+func (f *SortedFile) Close() error {
+	return f.file.Close()
+}
+
+// RangeDirectory читает файлы диапазонов по первым 5 символам хеша,
+// как их сохраняет загрузчик Pwned Passwords. Файл диапазона невелик,
+// поэтому он читается целиком при каждой проверке.
+type RangeDirectory struct {
+	dir string
+}
+
+func (d *RangeDirectory) Occurrences(password string) (int, error) {
+	sum := passwordHash(password)
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(d.dir, prefix+".txt"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("ошибка чтения базы утекших паролей: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(strings.ToUpper(line), suffix+":") {
+			_, count, err := parseRecord(prefix, line)
+			return count, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("ошибка чтения базы утекших паролей: %w", err)
+	}
+	return 0, nil
+}
+
+// Close ничего не делает: файлы диапазонов открываются на время проверки.
+func (d *RangeDirectory) Close() error {
+	return nil
+}
+
+// ScanDataset перебирает записи базы в формате каталога диапазонов или
+// отсортированного файла и передает их fn.
+func ScanDataset(path string, fn func(sum [sha1.Size]byte, count int) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия базы утекших паролей: %w", err)
+	}
+	if !info.IsDir() {
+		return scanFile(path, "", fn)
+	}
+
+	files, err := filepath.Glob(filepath.Join(path, "*.txt"))
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		prefix := strings.TrimSuffix(filepath.Base(name), ".txt")
+		if len(prefix) != 5 {
+			continue
+		}
+		if err := scanFile(name, prefix, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanFile(path, prefix string, fn func(sum [sha1.Size]byte, count int) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия базы утекших паролей: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		sum, count, err := parseRecord(prefix, scanner.Text())
+		if err != nil {
+			return fmt.Errorf("%w: %s: %q", err, path, scanner.Text())
+		}
+		if err := fn(sum, count); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("ошибка чтения базы утекших паролей: %w", err)
+	}
+	return nil
+}
+
+const bloomMagic = "PWNBLM01"
+
+// BloomFilter - компактная замена полной базы: хранит только хеши,
+// встречавшиеся не меньше MinCount раз. Ложноположительные ответы
+// возможны с заданной при построении вероятностью, ложноотрицательные - нет.
+// Число вхождений фильтр не хранит и для найденного пароля возвращает MinCount.
+type BloomFilter struct {
+	bits     []uint64
+	m        uint64
+	k        uint32
+	minCount uint32
+}
+
+// NewBloomFilter рассчитывает размер фильтра на expected записей
+// с вероятностью ложного срабатывания fpr.
+func NewBloomFilter(expected uint64, fpr float64, minCount int) *BloomFilter {
+	expected = max(expected, 1)
+	m := uint64(math.Ceil(-float64(expected) * math.Log(fpr) / (math.Ln2 * math.Ln2)))
+	m = max(m, 64)
+	k := uint32(max(1, math.Round(float64(m)/float64(expected)*math.Ln2)))
+	return &BloomFilter{
+		bits:     make([]uint64, (m+63)/64),
+		m:        m,
+		k:        k,
+		minCount: uint32(max(minCount, 1)),
+	}
+}
+
+func (b *BloomFilter) MinCount() int {
+	return int(b.minCount)
+}
+
+// Индексы строятся двойным хешированием из самого SHA-1: он уже
+// равномерно распределен, и второй хеш не нужен.
+func (b *BloomFilter) index(sum [sha1.Size]byte, i uint32) uint64 {
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+	return (h1 + uint64(i)*h2) % b.m
+}
+
+func (b *BloomFilter) Add(sum [sha1.Size]byte) {
+	for i := uint32(0); i < b.k; i++ {
+		idx := b.index(sum, i)
+		b.bits[idx/64] |= 1 << (idx % 64)
+	}
+}
+
+func (b *BloomFilter) Contains(sum [sha1.Size]byte) bool {
+	for i := uint32(0); i < b.k; i++ {
+		idx := b.index(sum, i)
+		if b.bits[idx/64]&(1<<(idx%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *BloomFilter) Occurrences(password string) (int, error) {
+	if b.Contains(passwordHash(password)) {
+		return int(b.minCount), nil
+	}
+	return 0, nil
+}
+
+// Close ничего не делает: фильтр целиком загружен в память.
+func (b *BloomFilter) Close() error {
+	return nil
+}
+
+// WriteTo сохраняет фильтр: магическая строка, m, k, MinCount и биты.
+func (b *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, 0, len(bloomMagic)+16)
+	header = append(header, bloomMagic...)
+	header = binary.BigEndian.AppendUint64(header, b.m)
+	header = binary.BigEndian.AppendUint32(header, b.k)
+	header = binary.BigEndian.AppendUint32(header, b.minCount)
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+	written := int64(n)
+
+	buf := make([]byte, 8)
+	for _, word := range b.bits {
+		binary.BigEndian.PutUint64(buf, word)
+		n, err := w.Write(buf)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	header := make([]byte, len(bloomMagic)+16)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(bloomMagic)]) != bloomMagic {
+		return nil, ErrorInvalidDataset
+	}
+	b := &BloomFilter{
+		m:        binary.BigEndian.Uint64(header[len(bloomMagic):]),
+		k:        binary.BigEndian.Uint32(header[len(bloomMagic)+8:]),
+		minCount: binary.BigEndian.Uint32(header[len(bloomMagic)+12:]),
+	}
+	if b.m == 0 || b.k == 0 {
+		return nil, ErrorInvalidDataset
+	}
+
+	b.bits = make([]uint64, (b.m+63)/64)
+	buf := make([]byte, 8)
+	for i := range b.bits {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, ErrorInvalidDataset
+		}
+		b.bits[i] = binary.BigEndian.Uint64(buf)
+	}
+	return b, nil
+}
+
+// BuildBloomFilter строит фильтр из базы в формате каталога диапазонов
+// или отсортированного файла. База читается дважды: сначала считаются
+// записи не реже minCount, чтобы рассчитать размер фильтра.
+func BuildBloomFilter(source string, minCount int, fpr float64) (*BloomFilter, error) {
+	var expected uint64
+	err := ScanDataset(source, func(_ [sha1.Size]byte, count int) error {
+		if count >= minCount {
+			expected++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	filter := NewBloomFilter(expected, fpr, minCount)
+	err = ScanDataset(source, func(sum [sha1.Size]byte, count int) error {
+		if count >= minCount {
+			filter.Add(sum)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
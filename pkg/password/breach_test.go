@@ -0,0 +1,152 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testBreaches = map[string]int{
+	"password":     100,
+	"hunter2":      3,
+	"correcthorse": 1,
+}
+
+// writeTestDataset сохраняет утекшие пароли вместе с сотней случайных
+// хешей в отсортированный файл и в каталог диапазонов.
+func writeTestDataset(t *testing.T) (string, string) {
+	lines := []string{}
+	for pw, count := range testBreaches {
+		sum := sha1.Sum([]byte(pw))
+		lines = append(lines, fmt.Sprintf("%s:%d", strings.ToUpper(hex.EncodeToString(sum[:])), count))
+	}
+	for i := range 100 {
+		sum := sha1.Sum([]byte(fmt.Sprintf("filler-%d", i)))
+		lines = append(lines, fmt.Sprintf("%s:%d", strings.ToUpper(hex.EncodeToString(sum[:])), i+1))
+	}
+	sort.Strings(lines)
+
+	dir := t.TempDir()
+	sorted := filepath.Join(dir, "pwned.txt")
+	require.NoError(t, os.WriteFile(sorted, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600))
+
+	ranges := filepath.Join(dir, "ranges")
+	require.NoError(t, os.Mkdir(ranges, 0o700))
+	byPrefix := map[string][]string{}
+	for _, line := range lines {
+		byPrefix[line[:5]] = append(byPrefix[line[:5]], line[5:])
+	}
+	for prefix, suffixes := range byPrefix {
+		require.NoError(t, os.WriteFile(filepath.Join(ranges, prefix+".txt"), []byte(strings.Join(suffixes, "\n")), 0o600))
+	}
+	return sorted, ranges
+}
+
+func TestBreachDatasets(t *testing.T) {
+	sorted, ranges := writeTestDataset(t)
+
+	for name, path := range map[string]string{"Sorted file": sorted, "Range directory": ranges} {
+		t.Run(name, func(t *testing.T) {
+			checker, err := OpenBreachDataset(path)
+			require.NoError(t, err)
+
+			for pw, count := range testBreaches {
+				got, err := checker.Occurrences(pw)
+				require.NoError(t, err)
+				assert.Equal(t, count, got, pw)
+			}
+			for i := range 100 {
+				got, err := checker.Occurrences(fmt.Sprintf("filler-%d", i))
+				require.NoError(t, err)
+				assert.Equal(t, i+1, got)
+			}
+
+			got, err := checker.Occurrences("k8#Lm2qZ-not-leaked")
+			require.NoError(t, err)
+			assert.Zero(t, got)
+			assert.NoError(t, checker.Close())
+		})
+	}
+}
+
+func TestSortedFile_Close(t *testing.T) {
+	sorted, _ := writeTestDataset(t)
+	checker, err := OpenBreachDataset(sorted)
+	require.NoError(t, err)
+	require.IsType(t, &SortedFile{}, checker)
+
+	require.NoError(t, checker.Close())
+	_, err = checker.Occurrences("password")
+	assert.ErrorIs(t, err, os.ErrClosed, "после Close файл базы не читается")
+}
+
+func TestBloomFilter(t *testing.T) {
+	sorted, ranges := writeTestDataset(t)
+
+	for name, source := range map[string]string{"Sorted file": sorted, "Range directory": ranges} {
+		t.Run(name, func(t *testing.T) {
+			filter, err := BuildBloomFilter(source, 3, 0.001)
+			require.NoError(t, err)
+
+			path := filepath.Join(t.TempDir(), "pwned.bloom")
+			file, err := os.Create(path)
+			require.NoError(t, err)
+			_, err = filter.WriteTo(file)
+			require.NoError(t, err)
+			require.NoError(t, file.Close())
+
+			checker, err := OpenBreachDataset(path)
+			require.NoError(t, err)
+			require.IsType(t, &BloomFilter{}, checker)
+			assert.Equal(t, 3, checker.(*BloomFilter).MinCount())
+
+			got, err := checker.Occurrences("password")
+			require.NoError(t, err)
+			assert.Equal(t, 3, got, "фильтр хранит только порог импорта")
+
+			got, err = checker.Occurrences("hunter2")
+			require.NoError(t, err)
+			assert.Equal(t, 3, got)
+
+			got, err = checker.Occurrences("correcthorse")
+			require.NoError(t, err)
+			assert.Zero(t, got, "записи реже порога в фильтр не попадают")
+		})
+	}
+
+	t.Run("Corrupted filter", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "broken.bloom")
+		require.NoError(t, os.WriteFile(path, []byte(bloomMagic+"short"), 0o600))
+		_, err := OpenBreachDataset(path)
+		assert.ErrorIs(t, err, ErrorInvalidDataset)
+	})
+}
+
+func TestPolicy_Breaches(t *testing.T) {
+	sorted, _ := writeTestDataset(t)
+	checker, err := OpenBreachDataset(sorted)
+	require.NoError(t, err)
+
+	policy := Policy{MinLength: 1, RejectBreached: true, BreachThreshold: 2, Breaches: checker}
+
+	violations, err := policy.Check("hunter2", UserInputs{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{ViolationBreached}, codes(violations))
+
+	violations, err = policy.Check("correcthorse", UserInputs{})
+	require.NoError(t, err)
+	assert.Empty(t, violations, "встречается реже порога")
+
+	policy.RejectBreached = false
+	violations, err = policy.Check("hunter2", UserInputs{})
+	require.NoError(t, err)
+	assert.Empty(t, violations)
+}
@@ -0,0 +1,207 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrorUnknownHash = errors.New("Неизвестный формат хеша пароля")
+	ErrorInvalidHash = errors.New("Поврежденный хеш пароля")
+)
+
+// Hasher хеширует и проверяет пароли в одном формате. Identify сообщает,
+// что хеш записан в этом формате, NeedsRehash - что он записан
+// с устаревшими параметрами и его стоит пересчитать при следующем входе.
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	Identify(encoded string) bool
+	NeedsRehash(encoded string) bool
+}
+
+// Argon2Params - параметры Argon2id: Memory в КиБ, Iterations - число
+// проходов, Parallelism - число потоков.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params - второй рекомендуемый вариант RFC 9106, 4
+// для систем, где 2 ГиБ на хеш слишком много.
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+const argon2Prefix = "$argon2id$"
+
+// Argon2id хранит хеши в формате PHC:
+// $argon2id$v=19$m=65536,t=3,p=4$<соль>$<хеш>, где соль и хеш
+// закодированы в base64 без выравнивания. Параметры хранятся в самом
+// хеше, поэтому их можно менять, не ломая проверку старых паролей.
+type Argon2id struct {
+	Params Argon2Params
+}
+
+func NewArgon2id(params Argon2Params) *Argon2id {
+	return &Argon2id{Params: params}
+}
+
+func (h *Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, h.Params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("ошибка генерации соли: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Params.Iterations, h.Params.Memory, h.Params.Parallelism, h.Params.KeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version,
+		h.Params.Memory, h.Params.Iterations, h.Params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *Argon2id) Verify(password, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2(encoded)
+	if err != nil {
+		return false, err
+	}
+	actual := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return subtle.ConstantTimeCompare(actual, key) == 1, nil
+}
+
+func (h *Argon2id) Identify(encoded string) bool {
+	return strings.HasPrefix(encoded, argon2Prefix)
+}
+
+func (h *Argon2id) NeedsRehash(encoded string) bool {
+	params, _, _, err := decodeArgon2(encoded)
+	if err != nil {
+		return true
+	}
+	return params != h.Params
+}
+
+func decodeArgon2(encoded string) (Argon2Params, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2Params{}, nil, nil, ErrorInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, ErrorInvalidHash
+	}
+
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2Params{}, nil, nil, ErrorInvalidHash
+	}
+	if params.Iterations == 0 || params.Parallelism == 0 {
+		return Argon2Params{}, nil, nil, ErrorInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrorInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2Params{}, nil, nil, ErrorInvalidHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
+
+// Bcrypt проверяет хеши, созданные до перехода на Argon2id. Новые хеши
+// в этом формате не создаются: bcrypt молча обрезает пароль до 72 байт.
+type Bcrypt struct{}
+
+func (Bcrypt) Hash(string) (string, error) {
+	return "", errors.New("bcrypt используется только для проверки старых хешей")
+}
+
+func (Bcrypt) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, ErrorInvalidHash
+	}
+	return true, nil
+}
+
+func (Bcrypt) Identify(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (Bcrypt) NeedsRehash(string) bool {
+	return true
+}
+
+// Hashers хеширует новые пароли основным алгоритмом и проверяет хеши
+// любого из известных форматов. Хеш не основного формата всегда требует
+// пересчета.
+type Hashers struct {
+	primary Hasher
+	legacy  []Hasher
+}
+
+func NewHashers(primary Hasher, legacy ...Hasher) *Hashers {
+	return &Hashers{primary: primary, legacy: legacy}
+}
+
+// DefaultHashers - Argon2id с параметрами по умолчанию и проверка bcrypt.
+func DefaultHashers() *Hashers {
+	return NewHashers(NewArgon2id(DefaultArgon2Params), Bcrypt{})
+}
+
+func (h *Hashers) Hash(password string) (string, error) {
+	return h.primary.Hash(password)
+}
+
+func (h *Hashers) Verify(password, encoded string) (bool, error) {
+	hasher := h.find(encoded)
+	if hasher == nil {
+		return false, ErrorUnknownHash
+	}
+	return hasher.Verify(password, encoded)
+}
+
+func (h *Hashers) Identify(encoded string) bool {
+	return h.find(encoded) != nil
+}
+
+func (h *Hashers) NeedsRehash(encoded string) bool {
+	if !h.primary.Identify(encoded) {
+		return true
+	}
+	return h.primary.NeedsRehash(encoded)
+}
+
+func (h *Hashers) find(encoded string) Hasher {
+	if h.primary.Identify(encoded) {
+		return h.primary
+	}
+	for _, hasher := range h.legacy {
+		if hasher.Identify(encoded) {
+			return hasher
+		}
+	}
+	return nil
+}
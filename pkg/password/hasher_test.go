@@ -0,0 +1,88 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2Params = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2id(t *testing.T) {
+	hasher := NewArgon2id(testArgon2Params)
+
+	encoded, err := hasher.Hash("correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	assert.True(t, hasher.Identify(encoded))
+	assert.False(t, hasher.NeedsRehash(encoded))
+
+	ok, err := hasher.Verify("correct horse", encoded)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = hasher.Verify("wrong horse", encoded)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	other, err := hasher.Hash("correct horse")
+	require.NoError(t, err)
+	assert.NotEqual(t, encoded, other, "соль должна быть случайной")
+
+	t.Run("Long passwords are not truncated", func(t *testing.T) {
+		long := strings.Repeat("a", 72)
+		encoded, err := hasher.Hash(long + "b")
+		require.NoError(t, err)
+		ok, err := hasher.Verify(long+"c", encoded)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("Changed parameters need rehash", func(t *testing.T) {
+		stronger := NewArgon2id(Argon2Params{Memory: 2048, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+		assert.True(t, stronger.NeedsRehash(encoded))
+
+		ok, err := stronger.Verify("correct horse", encoded)
+		require.NoError(t, err)
+		assert.True(t, ok, "параметры берутся из самого хеша")
+	})
+
+	t.Run("Malformed hash", func(t *testing.T) {
+		for _, encoded := range []string{
+			"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA",
+			"$argon2id$v=16$m=1024,t=1,p=1$c2FsdHNhbHQ$aGFzaA",
+			"$argon2id$v=19$m=1024,t=0,p=1$c2FsdHNhbHQ$aGFzaA",
+			"$argon2id$v=19$m=1024,t=1,p=1$!!!$aGFzaA",
+		} {
+			_, err := hasher.Verify("correct horse", encoded)
+			assert.ErrorIs(t, err, ErrorInvalidHash, encoded)
+		}
+	})
+}
+
+func TestHashers(t *testing.T) {
+	hashers := NewHashers(NewArgon2id(testArgon2Params), Bcrypt{})
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	ok, err := hashers.Verify("correct horse", string(legacy))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, hashers.NeedsRehash(string(legacy)))
+
+	ok, err = hashers.Verify("wrong horse", string(legacy))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	encoded, err := hashers.Hash("correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$argon2id$"))
+	assert.False(t, hashers.NeedsRehash(encoded))
+
+	_, err = hashers.Verify("correct horse", "plaintext")
+	assert.ErrorIs(t, err, ErrorUnknownHash)
+}
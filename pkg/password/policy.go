@@ -0,0 +1,330 @@
+package password
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrorPolicyViolation = errors.New("Пароль не соответствует политике")
+
+const (
+	DefaultMinLength = 8
+	DefaultMaxLength = 128
+	DefaultMinScore  = 2
+)
+
+// Коды нарушений политики. Фронтенд выбирает по коду собственный текст,
+// Message - текст по умолчанию.
+const (
+	ViolationTooShort         = "too_short"
+	ViolationTooLong          = "too_long"
+	ViolationMissingLowercase = "missing_lowercase"
+	ViolationMissingUppercase = "missing_uppercase"
+	ViolationMissingDigit     = "missing_digit"
+	ViolationMissingSymbol    = "missing_symbol"
+	ViolationBannedWord       = "banned_word"
+	ViolationSimilarToEmail   = "similar_to_email"
+	ViolationSimilarToName    = "similar_to_name"
+	ViolationTooWeak          = "too_weak"
+	ViolationBreached         = "breached"
+)
+
+// Violation - одно нарушение политики. Limit - порог правила
+// (минимальная длина, требуемая оценка), если он у правила есть.
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Limit   int    `json:"limit,omitempty"`
+}
+
+// PolicyError возвращается, когда пароль нарушает политику, и перечисляет
+// все нарушения сразу, чтобы пользователь исправил их за одну попытку.
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+	return fmt.Sprintf("%s: %s", ErrorPolicyViolation, strings.Join(messages, "; "))
+}
+
+func (e *PolicyError) Unwrap() error {
+	return ErrorPolicyViolation
+}
+
+// Policy - требования к паролю. Длина считается в символах, а не в байтах.
+// MinScore - минимальная оценка стойкости от 0 до 4 (см. Strength).
+// BannedWords не отдаются наружу, чтобы не подсказывать перебор.
+// Breaches - база утекших паролей: пароль, встречавшийся в ней не меньше
+// BreachThreshold раз, отклоняется. nil отключает проверку.
+type Policy struct {
+	MinLength        int           `json:"min_length"`
+	MaxLength        int           `json:"max_length"`
+	RequireLowercase bool          `json:"require_lowercase"`
+	RequireUppercase bool          `json:"require_uppercase"`
+	RequireDigit     bool          `json:"require_digit"`
+	RequireSymbol    bool          `json:"require_symbol"`
+	MinScore         int           `json:"min_score"`
+	RejectSimilar    bool          `json:"reject_similar_to_user"`
+	RejectBreached   bool          `json:"reject_breached"`
+	BreachThreshold  int           `json:"breach_threshold,omitempty"`
+	BannedWords      []string      `json:"-"`
+	Breaches         BreachChecker `json:"-"`
+}
+
+// DefaultPolicy следует рекомендациям NIST SP 800-63B: длина и оценка
+// стойкости вместо обязательных классов символов.
+func DefaultPolicy() Policy {
+	return Policy{
+		MinLength:     DefaultMinLength,
+		MaxLength:     DefaultMaxLength,
+		MinScore:      DefaultMinScore,
+		RejectSimilar: true,
+		BannedWords:   commonWords,
+	}
+}
+
+// UserInputs - данные пользователя, на которые пароль не должен быть похож.
+type UserInputs struct {
+	Email string
+	Name  string
+}
+
+// Validate возвращает *PolicyError со всеми нарушениями, ошибку чтения
+// базы утекших паролей или nil.
+func (p Policy) Validate(password string, inputs UserInputs) error {
+	violations, err := p.Check(password, inputs)
+	if err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return &PolicyError{Violations: violations}
+}
+
+// Check возвращает нарушения политики; пустой список означает, что пароль подходит.
+func (p Policy) Check(password string, inputs UserInputs) ([]Violation, error) {
+	violations := []Violation{}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, Violation{
+			Code:    ViolationTooShort,
+			Message: fmt.Sprintf("Пароль должен содержать не меньше %d символов", p.MinLength),
+			Limit:   p.MinLength,
+		})
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, Violation{
+			Code:    ViolationTooLong,
+			Message: fmt.Sprintf("Пароль должен содержать не больше %d символов", p.MaxLength),
+			Limit:   p.MaxLength,
+		})
+		// Оценивать стойкость слишком длинного пароля незачем.
+		return violations, nil
+	}
+
+	violations = append(violations, p.checkClasses(password)...)
+
+	if word, ok := containsBannedWord(password, p.BannedWords); ok {
+		violations = append(violations, Violation{
+			Code:    ViolationBannedWord,
+			Message: fmt.Sprintf("Пароль содержит запрещенное слово %q", word),
+		})
+	}
+
+	if p.RejectSimilar {
+		if similarToEmail(password, inputs.Email) {
+			violations = append(violations, Violation{
+				Code:    ViolationSimilarToEmail,
+				Message: "Пароль слишком похож на email",
+			})
+		}
+		if similarToName(password, inputs.Name) {
+			violations = append(violations, Violation{
+				Code:    ViolationSimilarToName,
+				Message: "Пароль слишком похож на имя",
+			})
+		}
+	}
+
+	if p.MinScore > 0 {
+		if score := Strength(password, p.dictionary(inputs)...); score < p.MinScore {
+			violations = append(violations, Violation{
+				Code:    ViolationTooWeak,
+				Message: fmt.Sprintf("Пароль слишком простой: оценка %d из 4, нужно не меньше %d", score, p.MinScore),
+				Limit:   p.MinScore,
+			})
+		}
+	}
+
+	if p.RejectBreached && p.Breaches != nil {
+		occurrences, err := p.Breaches.Occurrences(password)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка проверки пароля по базе утечек: %w", err)
+		}
+		if occurrences >= max(p.BreachThreshold, 1) {
+			violations = append(violations, Violation{
+				Code:    ViolationBreached,
+				Message: "Пароль встречается в известных утечках",
+				Limit:   p.BreachThreshold,
+			})
+		}
+	}
+	return violations, nil
+}
+
+func (p Policy) checkClasses(password string) []Violation {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	violations := []Violation{}
+	if p.RequireLowercase && !lower {
+		violations = append(violations, Violation{Code: ViolationMissingLowercase, Message: "Пароль должен содержать строчную букву"})
+	}
+	if p.RequireUppercase && !upper {
+		violations = append(violations, Violation{Code: ViolationMissingUppercase, Message: "Пароль должен содержать заглавную букву"})
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, Violation{Code: ViolationMissingDigit, Message: "Пароль должен содержать цифру"})
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, Violation{Code: ViolationMissingSymbol, Message: "Пароль должен содержать специальный символ"})
+	}
+	return violations
+}
+
+// dictionary - слова, которые оценка стойкости считает легко угадываемыми:
+// запрещенные слова и данные самого пользователя.
+func (p Policy) dictionary(inputs UserInputs) []string {
+	words := append([]string{}, p.BannedWords...)
+	local, domain, _ := strings.Cut(inputs.Email, "@")
+	words = append(words, local, domain)
+	words = append(words, strings.Fields(inputs.Name)...)
+	return words
+}
+
+// LoadBannedWords читает список запрещенных слов: по одному на строку,
+// пустые строки и строки, начинающиеся с #, пропускаются.
+func LoadBannedWords(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия списка запрещенных слов: %w", err)
+	}
+	defer file.Close()
+
+	words := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words = append(words, word)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения списка запрещенных слов: %w", err)
+	}
+	return words, nil
+}
+
+// containsBannedWord ищет запрещенное слово в пароле без учета регистра
+// и с заменой типичных leet-подстановок (p@ssw0rd - это password).
+func containsBannedWord(password string, banned []string) (string, bool) {
+	lowered := strings.ToLower(password)
+	unleeted := unleet(lowered)
+	for _, word := range banned {
+		if len([]rune(word)) < 3 {
+			continue
+		}
+		if strings.Contains(lowered, word) || strings.Contains(unleeted, word) {
+			return word, true
+		}
+	}
+	return "", false
+}
+
+func similarToEmail(password, email string) bool {
+	local, _, _ := strings.Cut(email, "@")
+	return similar(password, email) || similar(password, local)
+}
+
+func similarToName(password, name string) bool {
+	if similar(password, name) {
+		return true
+	}
+	for _, part := range strings.Fields(name) {
+		if similar(password, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// similar считает пароль похожим на значение, если одно содержит другое
+// (для значений от 3 символов) или если они отличаются не больше чем
+// на треть длины пароля по расстоянию Левенштейна.
+func similar(password, value string) bool {
+	p := normalize(password)
+	v := normalize(value)
+	if len([]rune(v)) < 3 || len([]rune(p)) < 3 {
+		return false
+	}
+	if strings.Contains(p, v) || strings.Contains(v, p) {
+		return true
+	}
+	return levenshtein(p, v)*3 <= len([]rune(p))
+}
+
+// normalize оставляет только буквы и цифры в нижнем регистре
+// и снимает leet-подстановки.
+func normalize(s string) string {
+	var b strings.Builder
+	for _, r := range unleet(strings.ToLower(s)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
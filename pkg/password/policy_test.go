@@ -0,0 +1,108 @@
+package password
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func codes(violations []Violation) []string {
+	result := []string{}
+	for _, v := range violations {
+		result = append(result, v.Code)
+	}
+	return result
+}
+
+func TestPolicy_Check(t *testing.T) {
+	policy := DefaultPolicy()
+	inputs := UserInputs{Email: "ivan.petrov@example.com", Name: "Иван Петров"}
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		password string
+		want     []string
+	}{
+		{name: "Strong passphrase", policy: policy, password: "correct horse battery staple", want: []string{}},
+		{name: "Too short", policy: policy, password: "k8#Lm2q", want: []string{ViolationTooShort}},
+		{name: "Too long", policy: Policy{MinLength: 1, MaxLength: 4}, password: "abcdef", want: []string{ViolationTooLong}},
+		{name: "Banned word with leet", policy: policy, password: "xP@ssw0rd!x", want: []string{ViolationBannedWord, ViolationTooWeak}},
+		{name: "Contains email", policy: policy, password: "ivan.petrov-2024", want: []string{ViolationSimilarToEmail, ViolationTooWeak}},
+		{name: "Close to name", policy: policy, password: "Петров!", want: []string{ViolationTooShort, ViolationSimilarToName, ViolationTooWeak}},
+		{name: "Keyboard walk", policy: policy, password: "asdfghjkl", want: []string{ViolationTooWeak}},
+		{
+			name:     "Character classes",
+			policy:   Policy{MinLength: 1, RequireLowercase: true, RequireUppercase: true, RequireDigit: true, RequireSymbol: true},
+			password: "abcdefgh",
+			want:     []string{ViolationMissingUppercase, ViolationMissingDigit, ViolationMissingSymbol},
+		},
+		{
+			name:     "Similarity disabled",
+			policy:   Policy{MinLength: 1},
+			password: "ivan.petrov",
+			want:     []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := tt.policy.Check(tt.password, inputs)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, codes(violations))
+		})
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	policy := DefaultPolicy()
+
+	require.NoError(t, policy.Validate("correct horse battery staple", UserInputs{}))
+
+	err := policy.Validate("qwerty", UserInputs{})
+	require.ErrorIs(t, err, ErrorPolicyViolation)
+	var policyErr *PolicyError
+	require.True(t, errors.As(err, &policyErr))
+	assert.Equal(t, []string{ViolationTooShort, ViolationBannedWord, ViolationTooWeak}, codes(policyErr.Violations))
+	assert.Equal(t, DefaultMinLength, policyErr.Violations[0].Limit)
+}
+
+func TestStrength(t *testing.T) {
+	tests := []struct {
+		password string
+		want     int
+	}{
+		{password: "password", want: 0},
+		{password: "P@ssw0rd", want: 0},
+		{password: "aaaaaaaa", want: 0},
+		{password: "abcd1234", want: 1},
+		{password: "k8#Lm2qZ", want: 4},
+		{password: "correcthorsebatterystaple", want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			assert.Equal(t, tt.want, Strength(tt.password))
+		})
+	}
+
+	t.Run("User inputs lower the score", func(t *testing.T) {
+		assert.Greater(t, Strength("petrovpetrov"), Strength("petrovpetrov", "petrov"))
+	})
+}
+
+func TestLoadBannedWords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banned.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# компания\nAcme\n\n  Roadrunner \n"), 0o600))
+
+	words, err := LoadBannedWords(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"acme", "roadrunner"}, words)
+
+	_, err = LoadBannedWords(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
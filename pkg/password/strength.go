@@ -0,0 +1,244 @@
+package password
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// commonWords - слова и пароли, которые первыми пробуют при переборе.
+// Используются как запрещенные слова по умолчанию и как словарь оценки стойкости.
+var commonWords = []string{
+	"password", "passw0rd", "qwerty", "123456", "12345678", "123456789", "111111",
+	"iloveyou", "letmein", "welcome", "admin", "administrator", "monkey", "dragon",
+	"master", "login", "princess", "sunshine", "football", "baseball", "shadow",
+	"superman", "trustno1", "secret", "changeme", "default", "abc123", "qazwsx",
+	"пароль", "йцукен", "привет", "любовь",
+}
+
+// keyboardRows - ряды клавиатуры: идущие подряд клавиши угадываются как последовательность.
+var keyboardRows = []string{
+	"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm",
+	"йцукенгшщзхъ", "фывапролджэ", "ячсмитьбю",
+}
+
+var leetSubstitutions = strings.NewReplacer(
+	"@", "a", "4", "a", "3", "e", "1", "i", "!", "i", "0", "o",
+	"$", "s", "5", "s", "7", "t", "+", "t",
+)
+
+func unleet(s string) string {
+	return leetSubstitutions.Replace(s)
+}
+
+// Strength оценивает стойкость пароля от 0 до 4 в духе zxcvbn: пароль
+// разбирается на фрагменты (словарные слова, повторы, последовательности,
+// ряды клавиатуры, отдельные символы), для каждого оценивается число
+// попыток угадывания, а оценка определяется по их произведению:
+// меньше 10^3 - 0, 10^6 - 1, 10^8 - 2, 10^10 - 3, иначе 4.
+// dictionary дополняет встроенный словарь, например данными пользователя.
+func Strength(password string, dictionary ...string) int {
+	guesses := estimateGuesses(password, dictionary)
+	switch {
+	case guesses < 3:
+		return 0
+	case guesses < 6:
+		return 1
+	case guesses < 8:
+		return 2
+	case guesses < 10:
+		return 3
+	}
+	return 4
+}
+
+// estimateGuesses возвращает десятичный логарифм числа попыток.
+// Фрагменты выбираются жадно: на каждой позиции берется тот, что дает
+// меньше всего попыток в пересчете на символ, иначе символ перебирается
+// по своему классу.
+func estimateGuesses(password string, dictionary []string) float64 {
+	runes := []rune(password)
+	lowered := make([]rune, len(runes))
+	for i, r := range runes {
+		lowered[i] = unicode.ToLower(r)
+	}
+	unleeted := []rune(unleet(string(lowered)))
+	words := normalizeDictionary(dictionary)
+
+	var total float64
+	segments := 0
+	for i := 0; i < len(runes); {
+		length, guesses := bestMatch(runes, lowered, unleeted, i, words)
+		total += math.Log10(guesses)
+		segments++
+		i += length
+	}
+	// Атакующему нужно еще угадать, из скольких фрагментов состоит пароль.
+	return total + math.Log10(float64(segments))
+}
+
+func normalizeDictionary(dictionary []string) []string {
+	words := append([]string{}, commonWords...)
+	for _, word := range dictionary {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if len([]rune(word)) >= 3 {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// bestMatch возвращает длину фрагмента, начинающегося с позиции i,
+// и число попыток для него.
+func bestMatch(runes, lowered, unleeted []rune, i int, words []string) (int, float64) {
+	bestLength, bestGuesses := 1, float64(cardinality(runes[i]))
+	consider := func(length int, guesses float64) {
+		if length < 2 {
+			return
+		}
+		if math.Log10(guesses)/float64(length) < math.Log10(bestGuesses)/float64(bestLength) {
+			bestLength, bestGuesses = length, guesses
+		}
+	}
+
+	for rank, word := range words {
+		w := []rune(word)
+		if hasPrefixAt(lowered, i, w) {
+			consider(len(w), dictionaryGuesses(rank, runes[i:i+len(w)], false))
+		} else if hasPrefixAt(unleeted, i, w) {
+			consider(len(w), dictionaryGuesses(rank, runes[i:i+len(w)], true))
+		}
+	}
+
+	if n := repeatLength(lowered, i); n >= 3 {
+		consider(n, float64(cardinality(runes[i]))*float64(n))
+	}
+	if n := sequenceLength(lowered, i); n >= 3 {
+		consider(n, float64(cardinality(runes[i]))*float64(n))
+	}
+	if n := keyboardLength(lowered, i); n >= 4 {
+		consider(n, 40*float64(n))
+	}
+	if isYear(runes, i) {
+		consider(4, 100)
+	}
+	return bestLength, bestGuesses
+}
+
+// dictionaryGuesses: ранг слова в словаре, умноженный на варианты
+// регистра и leet-подстановок.
+func dictionaryGuesses(rank int, word []rune, leet bool) float64 {
+	guesses := float64(rank + 1)
+	upper := 0
+	for _, r := range word {
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+	switch {
+	case upper == len(word) || (upper == 1 && unicode.IsUpper(word[0])):
+		guesses *= 2
+	case upper > 0:
+		guesses *= math.Pow(2, float64(min(upper, len(word)-upper)))
+	}
+	if leet {
+		guesses *= 4
+	}
+	return max(guesses, 10)
+}
+
+func hasPrefixAt(s []rune, i int, prefix []rune) bool {
+	if len(prefix) == 0 || i+len(prefix) > len(s) {
+		return false
+	}
+	for j, r := range prefix {
+		if s[i+j] != r {
+			return false
+		}
+	}
+	return true
+}
+
+func repeatLength(s []rune, i int) int {
+	n := 1
+	for i+n < len(s) && s[i+n] == s[i] {
+		n++
+	}
+	return n
+}
+
+// sequenceLength - длина последовательности с постоянным шагом 1 или -1 (abc, 987).
+func sequenceLength(s []rune, i int) int {
+	if i+1 >= len(s) {
+		return 1
+	}
+	step := s[i+1] - s[i]
+	if step != 1 && step != -1 {
+		return 1
+	}
+	n := 2
+	for i+n < len(s) && s[i+n]-s[i+n-1] == step {
+		n++
+	}
+	return n
+}
+
+// keyboardLength - длина фрагмента, идущего подряд по ряду клавиатуры в любую сторону.
+func keyboardLength(s []rune, i int) int {
+	best := 1
+	for _, row := range keyboardRows {
+		r := []rune(row)
+		for _, dir := range []int{1, -1} {
+			pos := indexRune(r, s[i])
+			if pos < 0 {
+				continue
+			}
+			n := 1
+			for i+n < len(s) {
+				pos += dir
+				if pos < 0 || pos >= len(r) || r[pos] != s[i+n] {
+					break
+				}
+				n++
+			}
+			best = max(best, n)
+		}
+	}
+	return best
+}
+
+func indexRune(s []rune, r rune) int {
+	for i, c := range s {
+		if c == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// isYear сообщает, что с позиции i начинается год 1900-2099: годы
+// рождения и текущий год угадываются в первую очередь.
+func isYear(s []rune, i int) bool {
+	if i+4 > len(s) {
+		return false
+	}
+	for _, r := range s[i : i+4] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	prefix := string(s[i : i+2])
+	return prefix == "19" || prefix == "20"
+}
+
+// cardinality - размер класса символа при переборе: цифры, латиница,
+// остальные буквы (в основном кириллица) и специальные символы.
+func cardinality(r rune) int {
+	switch {
+	case r >= '0' && r <= '9':
+		return 10
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		return 26
+	}
+	return 33
+}
@@ -6,12 +6,50 @@ import (
 	jwt "github.com/fire9900/auth/pkg/auth"
 )
 
+// TokenValidator проверяет access токены. Помимо подписи реализация может
+// учитывать отзыв токенов, поэтому сервер не обращается к jwt.Verifier напрямую.
+type TokenValidator interface {
+	ValidateAccessTokenFor(accessToken, audience string) (*jwt.Claims, error)
+}
+
+// AuthServer проверяет токены для других сервисов. proofs проверяет DPoP
+// доказательства, пересланные сервисом вместе с токеном, так же, как
+// AuthMiddleware проверяет их в HTTP запросах.
 type AuthServer struct {
 	auth.UnimplementedAuthServiceServer
+	validator TokenValidator
+	proofs    *jwt.DPoPVerifier
+}
+
+func NewAuthServer(validator TokenValidator, proofs *jwt.DPoPVerifier) *AuthServer {
+	return &AuthServer{validator: validator, proofs: proofs}
+}
+
+// validate проверяет токен и его привязку к ключу DPoP. Сервис, получивший
+// запрос со схемой DPoP, передает доказательство в dpop_proof.
+func (s *AuthServer) validate(req *auth.TokenRequest) (*jwt.Claims, error) {
+	claims, err := s.validator.ValidateAccessTokenFor(req.Token, req.Audience)
+	if err != nil {
+		return nil, err
+	}
+	scheme := "Bearer"
+	if req.DpopProof != "" {
+		scheme = jwt.SchemeDPoP
+	}
+	err = s.proofs.VerifyBinding(claims, req.Token, jwt.DPoPRequest{
+		Scheme: scheme,
+		Proof:  req.DpopProof,
+		Method: req.HttpMethod,
+		URL:    req.HttpUrl,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
 }
 
 func (s *AuthServer) ValidateToken(ctx context.Context, req *auth.TokenRequest) (*auth.TokenResponse, error) {
-	_, err := jwt.ValidateToken(req.Token)
+	_, err := s.validate(req)
 	if err != nil {
 		return &auth.TokenResponse{
 			Valid: false,
@@ -22,11 +60,34 @@ func (s *AuthServer) ValidateToken(ctx context.Context, req *auth.TokenRequest)
 }
 
 func (s *AuthServer) GetUserID(ctx context.Context, req *auth.TokenRequest) (*auth.UserIDResponse, error) {
-	claims, err := jwt.ValidateToken(req.Token)
+	claims, err := s.validate(req)
 	if err != nil {
 		return &auth.UserIDResponse{
 			Error: err.Error(),
 		}, nil
 	}
+	if claims.IsClient() {
+		return &auth.UserIDResponse{
+			Error: jwt.ErrorNoUser.Error(),
+		}, nil
+	}
 	return &auth.UserIDResponse{UserId: int32(claims.UserID)}, nil
 }
+
+func (s *AuthServer) GetClaims(ctx context.Context, req *auth.TokenRequest) (*auth.ClaimsResponse, error) {
+	claims, err := s.validate(req)
+	if err != nil {
+		return &auth.ClaimsResponse{
+			Error: err.Error(),
+		}, nil
+	}
+	return &auth.ClaimsResponse{
+		UserId:    int32(claims.UserID),
+		Roles:     claims.Roles,
+		Scopes:    claims.Scopes(),
+		ExpiresAt: claims.ExpiresAt.Unix(),
+		TokenId:   claims.ID,
+		Subject:   claims.Subject,
+		ClientId:  claims.ClientID,
+	}, nil
+}
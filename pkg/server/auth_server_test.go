@@ -0,0 +1,292 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	auth "github.com/fire9900/auth/pkg/api/g_rpc"
+	jwt "github.com/fire9900/auth/pkg/auth"
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testTokenConfig = jwt.TokenConfig{Issuer: "https://auth.test", Audience: "api"}
+
+func newTestServer(t *testing.T) (*AuthServer, *jwt.Signer) {
+	ring, err := jwt.LoadKeyRing(jwt.KeyConfig{Secret: "0123456789abcdef0123456789abcdef"})
+	require.NoError(t, err)
+	return NewAuthServer(jwt.NewVerifier(ring, testTokenConfig), nil), jwt.NewSigner(ring, testTokenConfig)
+}
+
+func TestAuthServer_RejectsRefreshToken(t *testing.T) {
+	s, signer := newTestServer(t)
+
+	accessToken, _, err := signer.GenerateAccessToken(jwt.TokenParams{UserID: 5})
+	require.NoError(t, err)
+	refreshToken, _, err := signer.GenerateRefreshToken(jwt.TokenParams{UserID: 5})
+	require.NoError(t, err)
+
+	resp, err := s.ValidateToken(context.Background(), &auth.TokenRequest{Token: accessToken})
+	require.NoError(t, err)
+	assert.True(t, resp.Valid)
+
+	resp, err = s.ValidateToken(context.Background(), &auth.TokenRequest{Token: refreshToken})
+	require.NoError(t, err)
+	assert.False(t, resp.Valid)
+
+	userResp, err := s.GetUserID(context.Background(), &auth.TokenRequest{Token: refreshToken})
+	require.NoError(t, err)
+	assert.Zero(t, userResp.UserId)
+	assert.NotEmpty(t, userResp.Error)
+}
+
+func TestAuthServer_Audience(t *testing.T) {
+	s, signer := newTestServer(t)
+
+	accessToken, _, err := signer.GenerateAccessToken(jwt.TokenParams{UserID: 5})
+	require.NoError(t, err)
+
+	resp, err := s.ValidateToken(context.Background(), &auth.TokenRequest{Token: accessToken, Audience: "api"})
+	require.NoError(t, err)
+	assert.True(t, resp.Valid)
+
+	resp, err = s.ValidateToken(context.Background(), &auth.TokenRequest{Token: accessToken, Audience: "billing"})
+	require.NoError(t, err)
+	assert.False(t, resp.Valid)
+}
+
+func TestAuthServer_GetClaims(t *testing.T) {
+	s, signer := newTestServer(t)
+
+	accessToken, expiresAt, err := signer.GenerateAccessToken(jwt.TokenParams{
+		UserID: 5,
+		Roles:  []string{"admin"},
+		Scopes: []string{"users:read", "users:write"},
+	})
+	require.NoError(t, err)
+
+	resp, err := s.GetClaims(context.Background(), &auth.TokenRequest{Token: accessToken})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Error)
+	assert.Equal(t, int32(5), resp.UserId)
+	assert.Equal(t, []string{"admin"}, resp.Roles)
+	assert.Equal(t, []string{"users:read", "users:write"}, resp.Scopes)
+	assert.Equal(t, expiresAt, resp.ExpiresAt)
+	assert.NotEmpty(t, resp.TokenId)
+}
+
+func TestAuthServer_ClientToken(t *testing.T) {
+	s, signer := newTestServer(t)
+
+	token, _, err := signer.GenerateAccessToken(jwt.TokenParams{ClientID: "worker", Scopes: []string{"users:read"}})
+	require.NoError(t, err)
+
+	resp, err := s.ValidateToken(context.Background(), &auth.TokenRequest{Token: token})
+	require.NoError(t, err)
+	assert.True(t, resp.Valid)
+
+	userResp, err := s.GetUserID(context.Background(), &auth.TokenRequest{Token: token})
+	require.NoError(t, err)
+	assert.Zero(t, userResp.UserId)
+	assert.Equal(t, jwt.ErrorNoUser.Error(), userResp.Error)
+
+	claims, err := s.GetClaims(context.Background(), &auth.TokenRequest{Token: token})
+	require.NoError(t, err)
+	assert.Empty(t, claims.Error)
+	assert.Zero(t, claims.UserId)
+	assert.Equal(t, "worker", claims.Subject)
+	assert.Equal(t, "worker", claims.ClientId)
+	assert.Equal(t, []string{"users:read"}, claims.Scopes)
+}
+
+// mapTokenStore - хранилище непрозрачных токенов в памяти.
+type mapTokenStore map[string]jwt.Claims
+
+func (m mapTokenStore) StoreToken(claims *jwt.Claims) (string, error) {
+	token, err := jwt.NewOpaqueToken(claims.TokenType)
+	m[token] = *claims
+	return token, err
+}
+
+func (m mapTokenStore) ResolveToken(token string) (*jwt.Claims, error) {
+	claims, ok := m[token]
+	if !ok {
+		return nil, jwt.ErrorInvalidToken
+	}
+	return &claims, nil
+}
+
+func TestAuthServer_OpaqueToken(t *testing.T) {
+	ring, err := jwt.LoadKeyRing(jwt.KeyConfig{Secret: "0123456789abcdef0123456789abcdef"})
+	require.NoError(t, err)
+	store := mapTokenStore{}
+	signer, verifier := jwt.NewSigner(ring, testTokenConfig), jwt.NewVerifier(ring, testTokenConfig)
+	signer.UseTokenStore(store)
+	verifier.UseTokenStore(store)
+	s := NewAuthServer(verifier, nil)
+
+	accessToken, _, err := signer.GenerateAccessToken(jwt.TokenParams{UserID: 5})
+	require.NoError(t, err)
+	require.True(t, jwt.IsOpaqueToken(accessToken))
+
+	resp, err := s.ValidateToken(context.Background(), &auth.TokenRequest{Token: accessToken})
+	require.NoError(t, err)
+	assert.True(t, resp.Valid)
+
+	userResp, err := s.GetUserID(context.Background(), &auth.TokenRequest{Token: accessToken})
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), userResp.UserId)
+
+	delete(store, accessToken)
+	resp, err = s.ValidateToken(context.Background(), &auth.TokenRequest{Token: accessToken})
+	require.NoError(t, err)
+	assert.False(t, resp.Valid)
+}
+
+func TestAuthServer_PasetoToken(t *testing.T) {
+	ring, err := jwt.LoadKeyRing(jwt.KeyConfig{Secret: "0123456789abcdef0123456789abcdef"})
+	require.NoError(t, err)
+	codec, err := jwt.NewPasetoCodec(jwt.TokenFormatPasetoLocal, ring)
+	require.NoError(t, err)
+	signer, verifier := jwt.NewSigner(ring, testTokenConfig), jwt.NewVerifier(ring, testTokenConfig)
+	signer.UseCodec(codec)
+	verifier.UseCodec(codec)
+	s := NewAuthServer(verifier, nil)
+
+	accessToken, _, err := signer.GenerateAccessToken(jwt.TokenParams{UserID: 5})
+	require.NoError(t, err)
+	refreshToken, _, err := signer.GenerateRefreshToken(jwt.TokenParams{UserID: 5})
+	require.NoError(t, err)
+
+	resp, err := s.ValidateToken(context.Background(), &auth.TokenRequest{Token: accessToken, Audience: "api"})
+	require.NoError(t, err)
+	assert.True(t, resp.Valid)
+
+	userResp, err := s.GetUserID(context.Background(), &auth.TokenRequest{Token: accessToken})
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), userResp.UserId)
+
+	resp, err = s.ValidateToken(context.Background(), &auth.TokenRequest{Token: accessToken, Audience: "billing"})
+	require.NoError(t, err)
+	assert.False(t, resp.Valid)
+
+	resp, err = s.ValidateToken(context.Background(), &auth.TokenRequest{Token: refreshToken})
+	require.NoError(t, err)
+	assert.False(t, resp.Valid)
+}
+
+func TestAuthServer_DPoPBoundToken(t *testing.T) {
+	ring, err := jwt.LoadKeyRing(jwt.KeyConfig{Secret: "0123456789abcdef0123456789abcdef"})
+	require.NoError(t, err)
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	publicKey := jwt.JWK{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(private.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(private.Y.FillBytes(make([]byte, 32))),
+	}
+	sum := sha256.Sum256(fmt.Appendf(nil, `{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`, publicKey.X, publicKey.Y))
+	jkt := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	accessToken, _, err := jwt.NewSigner(ring, testTokenConfig).GenerateAccessToken(jwt.TokenParams{UserID: 5, JKT: jkt})
+	require.NoError(t, err)
+	const resource = "https://orders.test/orders"
+	proof := func(method string) string {
+		ath := sha256.Sum256([]byte(accessToken))
+		token := gojwt.NewWithClaims(gojwt.SigningMethodES256, gojwt.MapClaims{
+			"htm": method, "htu": resource, "iat": time.Now().Unix(), "jti": rand.Text(),
+			"ath": base64.RawURLEncoding.EncodeToString(ath[:]),
+		})
+		token.Header["typ"] = "dpop+jwt"
+		token.Header["jwk"] = publicKey
+		signed, err := token.SignedString(private)
+		require.NoError(t, err)
+		return signed
+	}
+
+	s := NewAuthServer(jwt.NewVerifier(ring, testTokenConfig), jwt.NewDPoPVerifier(jwt.NewMemoryReplayCache(), time.Minute))
+
+	resp, err := s.ValidateToken(context.Background(), &auth.TokenRequest{Token: accessToken})
+	require.NoError(t, err)
+	assert.False(t, resp.Valid, "привязанный токен без доказательства")
+
+	resp, err = s.ValidateToken(context.Background(), &auth.TokenRequest{
+		Token: accessToken, DpopProof: proof("POST"), HttpMethod: "GET", HttpUrl: resource,
+	})
+	require.NoError(t, err)
+	assert.False(t, resp.Valid, "доказательство для другого метода")
+
+	userResp, err := s.GetUserID(context.Background(), &auth.TokenRequest{
+		Token: accessToken, DpopProof: proof("GET"), HttpMethod: "GET", HttpUrl: resource,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), userResp.UserId)
+}
+
+// versionedValidator отклоняет токены с устаревшим claim ver так же,
+// как проверка версии токенов в usecase.TokenUseCase.
+type versionedValidator struct {
+	verifier *jwt.Verifier
+	versions map[int]int
+}
+
+var errStaleVersion = errors.New("Токен отозван")
+
+func (v versionedValidator) ValidateAccessTokenFor(accessToken, audience string) (*jwt.Claims, error) {
+	claims, err := v.verifier.ValidateAccessTokenFor(accessToken, audience)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenVersion < v.versions[claims.UserID] {
+		return nil, errStaleVersion
+	}
+	return claims, nil
+}
+
+func TestAuthServer_StaleTokenVersion(t *testing.T) {
+	ring, err := jwt.LoadKeyRing(jwt.KeyConfig{Secret: "0123456789abcdef0123456789abcdef"})
+	require.NoError(t, err)
+	signer := jwt.NewSigner(ring, testTokenConfig)
+	validator := versionedValidator{verifier: jwt.NewVerifier(ring, testTokenConfig), versions: map[int]int{5: 0}}
+	s := NewAuthServer(validator, nil)
+
+	accessToken, _, err := signer.GenerateAccessToken(jwt.TokenParams{UserID: 5, TokenVersion: 0})
+	require.NoError(t, err)
+	resp, err := s.ValidateToken(context.Background(), &auth.TokenRequest{Token: accessToken})
+	require.NoError(t, err)
+	assert.True(t, resp.Valid)
+
+	// Смена пароля, блокировка или выход со всех устройств.
+	validator.versions[5] = 1
+
+	resp, err = s.ValidateToken(context.Background(), &auth.TokenRequest{Token: accessToken})
+	require.NoError(t, err)
+	assert.False(t, resp.Valid)
+	assert.Equal(t, errStaleVersion.Error(), resp.Error)
+
+	userResp, err := s.GetUserID(context.Background(), &auth.TokenRequest{Token: accessToken})
+	require.NoError(t, err)
+	assert.Zero(t, userResp.UserId)
+	assert.Equal(t, errStaleVersion.Error(), userResp.Error)
+
+	claimsResp, err := s.GetClaims(context.Background(), &auth.TokenRequest{Token: accessToken})
+	require.NoError(t, err)
+	assert.Zero(t, claimsResp.UserId)
+	assert.Equal(t, errStaleVersion.Error(), claimsResp.Error)
+
+	fresh, _, err := signer.GenerateAccessToken(jwt.TokenParams{UserID: 5, TokenVersion: 1})
+	require.NoError(t, err)
+	resp, err = s.ValidateToken(context.Background(), &auth.TokenRequest{Token: fresh})
+	require.NoError(t, err)
+	assert.True(t, resp.Valid, "токен новой версии принимается")
+}